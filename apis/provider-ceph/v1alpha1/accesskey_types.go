@@ -0,0 +1,172 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/reference"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+)
+
+// Annotations recording the rotation state of an AccessKey. Keys are created
+// and removed based on this state, so it is kept in annotations rather than
+// only in the status, whose updates may be lost. The status mirrors them.
+const (
+	// AnnotationKeyKeyCreatedAt records when the current key, whose access
+	// key ID is the external name, was created, in RFC 3339 format.
+	AnnotationKeyKeyCreatedAt = "ceph.crossplane.io/key-created-at"
+
+	// AnnotationKeyPreviousAccessKeyID records the access key ID of the key
+	// replaced by the last rotation, until it is removed from every backend.
+	AnnotationKeyPreviousAccessKeyID = "ceph.crossplane.io/previous-access-key-id"
+
+	// AnnotationKeyPreviousKeyExpiresAt records when the previous key is
+	// removed, in RFC 3339 format.
+	AnnotationKeyPreviousKeyExpiresAt = "ceph.crossplane.io/previous-key-expires-at"
+)
+
+// AccessKeyParameters are the configurable fields of an AccessKey.
+type AccessKeyParameters struct {
+	// UserID is the uid of the user the key belongs to.
+	// +crossplane:generate:reference:type=User
+	// +crossplane:generate:reference:extractor=UserUID()
+	// +optional
+	UserID string `json:"userID,omitempty"`
+
+	// UserIDRef references the User the key belongs to.
+	// +optional
+	UserIDRef *xpv1.Reference `json:"userIDRef,omitempty"`
+
+	// UserIDSelector selects the User the key belongs to.
+	// +optional
+	UserIDSelector *xpv1.Selector `json:"userIDSelector,omitempty"`
+
+	// Tenant of the user the key belongs to. It is not resolved from a
+	// referenced User and must match its tenant.
+	// +optional
+	Tenant string `json:"tenant,omitempty"`
+
+	// Subuser is the name of the subuser, without the uid prefix, the key
+	// belongs to. The key belongs to the user itself if unset.
+	// +optional
+	Subuser string `json:"subuser,omitempty"`
+
+	// Rotation rotates the key on a schedule.
+	// +optional
+	Rotation *KeyRotation `json:"rotation,omitempty"`
+}
+
+// KeyRotation configures the scheduled rotation of a key.
+type KeyRotation struct {
+	// Interval after which the key is replaced by a new one.
+	Interval metav1.Duration `json:"interval"`
+
+	// Overlap is how long the previous key stays valid after the new key
+	// has been published, giving applications time to pick it up.
+	// Defaults to 1h.
+	// +optional
+	Overlap *metav1.Duration `json:"overlap,omitempty"`
+}
+
+// AccessKeyObservation are the observable fields of an AccessKey.
+type AccessKeyObservation struct {
+	// AccessKeyID of the current key.
+	AccessKeyID string `json:"accessKeyID,omitempty"`
+
+	// CreatedAt is when the current key was created.
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+
+	// PreviousAccessKeyID of the key replaced by the last rotation, while it
+	// is still valid.
+	PreviousAccessKeyID string `json:"previousAccessKeyID,omitempty"`
+
+	// PreviousKeyExpiresAt is when the previous key is removed.
+	PreviousKeyExpiresAt *metav1.Time `json:"previousKeyExpiresAt,omitempty"`
+}
+
+// An AccessKeySpec defines the desired state of an AccessKey.
+type AccessKeySpec struct {
+	xpv1.ResourceSpec `json:",inline"`
+	ForProvider       AccessKeyParameters `json:"forProvider"`
+}
+
+// An AccessKeyStatus represents the observed state of an AccessKey.
+type AccessKeyStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          AccessKeyObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// An AccessKey is an S3 key of an RGW user or subuser. The key is published
+// as the access_key and secret_key connection details. The external name of
+// an AccessKey is the access key ID of its current key, which is replaced
+// when the key is rotated.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="ACCESS-KEY-ID",type="string",JSONPath=".status.atProvider.accessKeyID"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,managed,ceph}
+type AccessKey struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AccessKeySpec   `json:"spec"`
+	Status AccessKeyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AccessKeyList contains a list of AccessKey
+type AccessKeyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessKey `json:"items"`
+}
+
+// AccessKey type metadata.
+var (
+	AccessKeyKind             = reflect.TypeOf(AccessKey{}).Name()
+	AccessKeyGroupKind        = schema.GroupKind{Group: Group, Kind: AccessKeyKind}.String()
+	AccessKeyKindAPIVersion   = AccessKeyKind + "." + SchemeGroupVersion.String()
+	AccessKeyGroupVersionKind = SchemeGroupVersion.WithKind(AccessKeyKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&AccessKey{}, &AccessKeyList{})
+}
+
+// UserUID returns a function that extracts the uid of a referenced User.
+func UserUID() reference.ExtractValueFn {
+	return func(mg resource.Managed) string {
+		u, ok := mg.(*User)
+		if !ok {
+			return ""
+		}
+		if u.Spec.ForProvider.UID != "" {
+			return u.Spec.ForProvider.UID
+		}
+
+		return u.Name
+	}
+}
@@ -19,6 +19,7 @@ package v1alpha1
 import (
 	"reflect"
 
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
@@ -30,9 +31,24 @@ type BucketParameters struct {
 	// The canned ACL to apply to the bucket.
 	ACL *string `json:"acl,omitempty"`
 
-	// Specifies the Region where the bucket will be created.
+	// Specifies the Region where the bucket will be created. For RGW this is
+	// the zonegroup API name, optionally followed by ":placement-id".
 	LocationConstraint string `json:"locationConstraint,omitempty"`
 
+	// PlacementTarget of the bucket, e.g. "default-placement". It is
+	// combined with the zonegroup of locationConstraint, if any, into the
+	// location constraint the bucket is created with. The placement of a
+	// bucket cannot be changed after it is created, a bucket placed
+	// otherwise on a backend is reported by the Placement condition.
+	// +optional
+	PlacementTarget string `json:"placementTarget,omitempty"`
+
+	// DefaultStorageClass of objects in the bucket, e.g. "STANDARD". It
+	// must be a storage class of the placement target and requires
+	// placementTarget to be set.
+	// +optional
+	DefaultStorageClass string `json:"defaultStorageClass,omitempty"`
+
 	// Allows grantee the read, write, read ACP, and write ACP permissions on the
 	// bucket.
 	GrantFullControl *string `json:"grantFullControl,omitempty"`
@@ -70,11 +86,105 @@ type BucketParameters struct {
 	// don't specify an ACL or bucket owner full control ACLs, such as the bucket-owner-full-control
 	// canned ACL or an equivalent form of this ACL expressed in the XML format.
 	ObjectOwnership *string `json:"objectOwnership,omitempty"`
+
+	// Quota of the bucket, enforced by RGW on every backend the bucket is
+	// placed on.
+	// +optional
+	Quota *BucketQuota `json:"quota,omitempty"`
+
+	// Tenant of the bucket. Defaults to the tenant of the ProviderConfig of
+	// each backend. The name of the bucket is the external name of the
+	// Bucket, so Buckets in different tenants may share a bucket name.
+	// Buckets can only be created by credentials of the same tenant.
+	// +optional
+	Tenant string `json:"tenant,omitempty"`
+
+	// Owner is the uid of the RGW user the bucket is linked to on every
+	// backend it is placed on. Buckets are owned by the user of the
+	// ProviderConfig credentials when unset. Linking a bucket to a new
+	// owner unlinks it from the previous one.
+	// +crossplane:generate:reference:type=User
+	// +crossplane:generate:reference:extractor=UserUID()
+	// +optional
+	Owner string `json:"owner,omitempty"`
+
+	// OwnerRef references the User owning the bucket.
+	// +optional
+	OwnerRef *xpv1.Reference `json:"ownerRef,omitempty"`
+
+	// OwnerSelector selects the User owning the bucket.
+	// +optional
+	OwnerSelector *xpv1.Selector `json:"ownerSelector,omitempty"`
+}
+
+// BucketQuota is the quota of a bucket.
+type BucketQuota struct {
+	// Enabled enforces the quota. Defaults to true.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// MaxSize is the maximum total size of the objects in the bucket, e.g.
+	// "10Gi". Unlimited if unset.
+	// +optional
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+
+	// MaxObjects is the maximum number of objects in the bucket. Unlimited
+	// if unset.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxObjects *int64 `json:"maxObjects,omitempty"`
 }
 
 // BucketObservation are the observable fields of a Bucket.
 type BucketObservation struct {
 	ObservableField string `json:"observableField,omitempty"`
+
+	// Backends is the status of the bucket on each backend it is placed on.
+	// It is refreshed at most once per stats interval of the provider.
+	// +optional
+	Backends map[string]BackendBucketStatus `json:"backends,omitempty"`
+
+	// LastUpdated is when backends was last refreshed.
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+}
+
+// BackendBucketStatus is the status of a bucket on a single backend.
+type BackendBucketStatus struct {
+	// SizeBytes is the total size of the objects in the bucket.
+	SizeBytes int64 `json:"sizeBytes"`
+
+	// Objects is the number of objects in the bucket.
+	Objects int64 `json:"objects"`
+
+	// NumShards is the number of shards of the bucket index.
+	NumShards int `json:"numShards"`
+
+	// Owner is the uid of the user owning the bucket.
+	Owner string `json:"owner"`
+
+	// PlacementRule of the bucket, as "placement-id" or
+	// "placement-id/storage-class".
+	// +optional
+	PlacementRule string `json:"placementRule,omitempty"`
+
+	// Quota of the bucket on the backend.
+	// +optional
+	Quota *BucketQuotaStatus `json:"quota,omitempty"`
+}
+
+// BucketQuotaStatus is the quota of a bucket as reported by a backend.
+type BucketQuotaStatus struct {
+	// Enabled is true if the quota is enforced.
+	Enabled bool `json:"enabled"`
+
+	// MaxSizeBytes is the maximum total size of the objects in the bucket,
+	// or -1 if unlimited.
+	MaxSizeBytes int64 `json:"maxSizeBytes"`
+
+	// MaxObjects is the maximum number of objects in the bucket, or -1 if
+	// unlimited.
+	MaxObjects int64 `json:"maxObjects"`
 }
 
 // A BucketSpec defines the desired state of a Bucket.
@@ -0,0 +1,58 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// TypePlacement indicates whether a Bucket with a placement target has been
+// placed with it on every backend.
+const TypePlacement xpv1.ConditionType = "Placement"
+
+// Reasons of the Placement condition.
+const (
+	ReasonPlacementMatched  xpv1.ConditionReason = "PlacementMatched"
+	ReasonPlacementMismatch xpv1.ConditionReason = "PlacementMismatch"
+)
+
+// PlacementMatched returns a condition indicating that the bucket has been
+// placed with the requested placement target on every backend.
+func PlacementMatched() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypePlacement,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonPlacementMatched,
+	}
+}
+
+// PlacementMismatch returns a condition indicating that the bucket has been
+// placed with another placement rule on a backend. RGW cannot move an
+// existing bucket to another placement target.
+func PlacementMismatch(err error) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypePlacement,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonPlacementMismatch,
+		Message:            err.Error(),
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// OIDCProviderParameters are the configurable fields of an OIDCProvider.
+type OIDCProviderParameters struct {
+	// URL of the OpenID Connect identity provider, e.g. the service account
+	// issuer of a Kubernetes cluster. The provider is identified by its URL,
+	// so it cannot be changed.
+	// +kubebuilder:validation:Pattern=`^https://`
+	URL string `json:"url"`
+
+	// ClientIDList is the list of audiences accepted in the tokens of the
+	// identity provider.
+	// +optional
+	ClientIDList []string `json:"clientIDList,omitempty"`
+
+	// ThumbprintList is the list of SHA-1 thumbprints of the certificates
+	// of the identity provider, as hex strings.
+	// +kubebuilder:validation:MinItems=1
+	ThumbprintList []string `json:"thumbprintList"`
+}
+
+// OIDCProviderObservation are the observable fields of an OIDCProvider.
+type OIDCProviderObservation struct {
+	// ARN of the identity provider, referenced by the trust policies of
+	// roles assumed with web identity tokens.
+	ARN string `json:"arn,omitempty"`
+}
+
+// An OIDCProviderSpec defines the desired state of an OIDCProvider.
+type OIDCProviderSpec struct {
+	xpv1.ResourceSpec `json:",inline"`
+	ForProvider       OIDCProviderParameters `json:"forProvider"`
+}
+
+// An OIDCProviderStatus represents the observed state of an OIDCProvider.
+type OIDCProviderStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          OIDCProviderObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// An OIDCProvider is an OpenID Connect identity provider of RGW, whose
+// tokens can be exchanged for temporary credentials of a role through STS
+// AssumeRoleWithWebIdentity.
+//
+// Changes to the client IDs and thumbprints are applied in place. Backends
+// running RGW releases that cannot update providers, those before Squid,
+// get the provider deleted and created again instead. Tokens of the
+// provider are rejected by such a backend until it is created again.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="URL",type="string",JSONPath=".spec.forProvider.url"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,managed,ceph}
+type OIDCProvider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   OIDCProviderSpec   `json:"spec"`
+	Status OIDCProviderStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// OIDCProviderList contains a list of OIDCProvider
+type OIDCProviderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OIDCProvider `json:"items"`
+}
+
+// OIDCProvider type metadata.
+var (
+	OIDCProviderKind             = reflect.TypeOf(OIDCProvider{}).Name()
+	OIDCProviderGroupKind        = schema.GroupKind{Group: Group, Kind: OIDCProviderKind}.String()
+	OIDCProviderKindAPIVersion   = OIDCProviderKind + "." + SchemeGroupVersion.String()
+	OIDCProviderGroupVersionKind = SchemeGroupVersion.WithKind(OIDCProviderKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&OIDCProvider{}, &OIDCProviderList{})
+}
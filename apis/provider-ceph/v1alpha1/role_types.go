@@ -0,0 +1,127 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/reference"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+)
+
+// RoleParameters are the configurable fields of a Role.
+type RoleParameters struct {
+	// RoleName is the name of the role. Defaults to the name of the Role.
+	// +optional
+	RoleName string `json:"roleName,omitempty"`
+
+	// Path of the role. Defaults to "/". It cannot be changed once the role
+	// has been created.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// AssumeRolePolicyDocument is the trust policy of the role, naming the
+	// principals allowed to assume it through STS. Documents that only
+	// differ in formatting are considered equal.
+	AssumeRolePolicyDocument string `json:"assumeRolePolicyDocument"`
+
+	// MaxSessionDuration is the maximum duration in seconds of sessions of
+	// the role. Defaults to 3600 on RGW.
+	// +kubebuilder:validation:Minimum=3600
+	// +kubebuilder:validation:Maximum=43200
+	// +optional
+	MaxSessionDuration *int32 `json:"maxSessionDuration,omitempty"`
+}
+
+// RoleObservation are the observable fields of a Role.
+type RoleObservation struct {
+	// ARN of the role.
+	ARN string `json:"arn,omitempty"`
+
+	// RoleID of the role.
+	RoleID string `json:"roleID,omitempty"`
+}
+
+// A RoleSpec defines the desired state of a Role.
+type RoleSpec struct {
+	xpv1.ResourceSpec `json:",inline"`
+	ForProvider       RoleParameters `json:"forProvider"`
+}
+
+// A RoleStatus represents the observed state of a Role.
+type RoleStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          RoleObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A Role is an IAM role of RGW, assumed through STS to get temporary
+// credentials scoped by its role policies.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="ARN",type="string",JSONPath=".status.atProvider.arn"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,managed,ceph}
+type Role struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RoleSpec   `json:"spec"`
+	Status RoleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RoleList contains a list of Role
+type RoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Role `json:"items"`
+}
+
+// Role type metadata.
+var (
+	RoleKind             = reflect.TypeOf(Role{}).Name()
+	RoleGroupKind        = schema.GroupKind{Group: Group, Kind: RoleKind}.String()
+	RoleKindAPIVersion   = RoleKind + "." + SchemeGroupVersion.String()
+	RoleGroupVersionKind = SchemeGroupVersion.WithKind(RoleKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&Role{}, &RoleList{})
+}
+
+// RoleName returns a function that extracts the name of a referenced Role.
+func RoleName() reference.ExtractValueFn {
+	return func(mg resource.Managed) string {
+		r, ok := mg.(*Role)
+		if !ok {
+			return ""
+		}
+		if r.Spec.ForProvider.RoleName != "" {
+			return r.Spec.ForProvider.RoleName
+		}
+
+		return r.Name
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// RolePolicyParameters are the configurable fields of a RolePolicy.
+type RolePolicyParameters struct {
+	// RoleName is the name of the role the policy is attached to.
+	// +crossplane:generate:reference:type=Role
+	// +crossplane:generate:reference:extractor=RoleName()
+	// +optional
+	RoleName string `json:"roleName,omitempty"`
+
+	// RoleNameRef references the Role the policy is attached to.
+	// +optional
+	RoleNameRef *xpv1.Reference `json:"roleNameRef,omitempty"`
+
+	// RoleNameSelector selects the Role the policy is attached to.
+	// +optional
+	RoleNameSelector *xpv1.Selector `json:"roleNameSelector,omitempty"`
+
+	// PolicyName is the name of the policy. Defaults to the name of the
+	// RolePolicy.
+	// +optional
+	PolicyName string `json:"policyName,omitempty"`
+
+	// PolicyDocument is the permission policy granted to sessions of the
+	// role. Documents that only differ in formatting are considered equal.
+	PolicyDocument string `json:"policyDocument"`
+}
+
+// RolePolicyObservation are the observable fields of a RolePolicy.
+type RolePolicyObservation struct{}
+
+// A RolePolicySpec defines the desired state of a RolePolicy.
+type RolePolicySpec struct {
+	xpv1.ResourceSpec `json:",inline"`
+	ForProvider       RolePolicyParameters `json:"forProvider"`
+}
+
+// A RolePolicyStatus represents the observed state of a RolePolicy.
+type RolePolicyStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          RolePolicyObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A RolePolicy is an inline permission policy of an RGW IAM role.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="ROLE",type="string",JSONPath=".spec.forProvider.roleName"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,managed,ceph}
+type RolePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RolePolicySpec   `json:"spec"`
+	Status RolePolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RolePolicyList contains a list of RolePolicy
+type RolePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RolePolicy `json:"items"`
+}
+
+// RolePolicy type metadata.
+var (
+	RolePolicyKind             = reflect.TypeOf(RolePolicy{}).Name()
+	RolePolicyGroupKind        = schema.GroupKind{Group: Group, Kind: RolePolicyKind}.String()
+	RolePolicyKindAPIVersion   = RolePolicyKind + "." + SchemeGroupVersion.String()
+	RolePolicyGroupVersionKind = SchemeGroupVersion.WithKind(RolePolicyKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&RolePolicy{}, &RolePolicyList{})
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// Connection detail keys of the Swift keys published by Subusers.
+const (
+	ConnectionDetailSwiftUser      = "swift_user"
+	ConnectionDetailSwiftSecretKey = "swift_secret_key"
+)
+
+// SubuserParameters are the configurable fields of a Subuser.
+type SubuserParameters struct {
+	// UserID is the uid of the parent user of the subuser.
+	// +crossplane:generate:reference:type=User
+	// +crossplane:generate:reference:extractor=UserUID()
+	// +optional
+	UserID string `json:"userID,omitempty"`
+
+	// UserIDRef references the parent User of the subuser.
+	// +optional
+	UserIDRef *xpv1.Reference `json:"userIDRef,omitempty"`
+
+	// UserIDSelector selects the parent User of the subuser.
+	// +optional
+	UserIDSelector *xpv1.Selector `json:"userIDSelector,omitempty"`
+
+	// Tenant of the parent user. It is not resolved from a referenced User
+	// and must match its tenant.
+	// +optional
+	Tenant string `json:"tenant,omitempty"`
+
+	// Name of the subuser, without the uid prefix. Defaults to the name of
+	// the Subuser.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Access level of the subuser.
+	// +kubebuilder:validation:Enum=read;write;readwrite;full
+	Access string `json:"access"`
+
+	// KeyType of the key created for the subuser. Swift keys are published
+	// as the swift_user and swift_secret_key connection details, S3 keys as
+	// the access_key and secret_key connection details.
+	// +kubebuilder:validation:Enum=s3;swift
+	// +kubebuilder:default=swift
+	// +optional
+	KeyType string `json:"keyType,omitempty"`
+}
+
+// SubuserObservation are the observable fields of a Subuser.
+type SubuserObservation struct {
+	// ID of the subuser, in the format "uid:subuser".
+	ID string `json:"id,omitempty"`
+
+	// Permissions of the subuser as reported by RGW.
+	Permissions string `json:"permissions,omitempty"`
+}
+
+// A SubuserSpec defines the desired state of a Subuser.
+type SubuserSpec struct {
+	xpv1.ResourceSpec `json:",inline"`
+	ForProvider       SubuserParameters `json:"forProvider"`
+}
+
+// A SubuserStatus represents the observed state of a Subuser.
+type SubuserStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          SubuserObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A Subuser is a subuser of an RGW user, giving scoped access to the user's
+// buckets through Swift or S3.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="ID",type="string",JSONPath=".status.atProvider.id"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,managed,ceph}
+type Subuser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SubuserSpec   `json:"spec"`
+	Status SubuserStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SubuserList contains a list of Subuser
+type SubuserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Subuser `json:"items"`
+}
+
+// Subuser type metadata.
+var (
+	SubuserKind             = reflect.TypeOf(Subuser{}).Name()
+	SubuserGroupKind        = schema.GroupKind{Group: Group, Kind: SubuserKind}.String()
+	SubuserKindAPIVersion   = SubuserKind + "." + SchemeGroupVersion.String()
+	SubuserGroupVersionKind = SchemeGroupVersion.WithKind(SubuserKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&Subuser{}, &SubuserList{})
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// Connection detail keys of the S3 keys published by Users. They match the
+// keys read from a credentials Secret by a ProviderConfig.
+const (
+	ConnectionDetailAccessKey = "access_key"
+	ConnectionDetailSecretKey = "secret_key"
+)
+
+// AnnotationKeyAccessKeyID records the access key ID of the key pair
+// generated when a User or an S3 Subuser is created, which is the key
+// published in its connection details. Other keys of the user or subuser,
+// e.g. those of AccessKeys, are never published by the User or Subuser.
+const AnnotationKeyAccessKeyID = "ceph.crossplane.io/access-key-id"
+
+// UserParameters are the configurable fields of a User.
+type UserParameters struct {
+	// UID of the user. Defaults to the name of the User. Changing the UID
+	// or tenant of an existing User creates a new user and orphans the old
+	// one.
+	// +optional
+	UID string `json:"uid,omitempty"`
+
+	// DisplayName of the user.
+	DisplayName string `json:"displayName"`
+
+	// Email address of the user.
+	// +optional
+	Email *string `json:"email,omitempty"`
+
+	// Tenant the user belongs to. Users without a tenant belong to the
+	// default, empty tenant.
+	// +optional
+	Tenant string `json:"tenant,omitempty"`
+
+	// MaxBuckets is the maximum number of buckets the user may own. RGW
+	// defaults to 1000. Overridden by quota.maxBuckets if set.
+	// +optional
+	MaxBuckets *int `json:"maxBuckets,omitempty"`
+
+	// Suspended users cannot access the backend.
+	// +optional
+	Suspended *bool `json:"suspended,omitempty"`
+
+	// OpMask restricts the operations the user may perform, e.g.
+	// "read, write, delete".
+	// +optional
+	OpMask *string `json:"opMask,omitempty"`
+
+	// Caps are the administrative capabilities of the user.
+	// +optional
+	Caps []UserCap `json:"caps,omitempty"`
+
+	// Quota of the user, enforced by RGW across all buckets of the user on
+	// each backend.
+	// +optional
+	Quota *UserQuota `json:"quota,omitempty"`
+}
+
+// UserQuota is the quota of a user.
+type UserQuota struct {
+	// Enabled enforces the size and object limits. Defaults to true.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// MaxSize is the maximum total size of the objects of the user, e.g.
+	// "100Gi". Unlimited if unset.
+	// +optional
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+
+	// MaxObjects is the maximum number of objects of the user. Unlimited if
+	// unset.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxObjects *int64 `json:"maxObjects,omitempty"`
+
+	// MaxBuckets is the maximum number of buckets the user may own. It is
+	// always enforced, regardless of enabled.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxBuckets *int `json:"maxBuckets,omitempty"`
+}
+
+// UserCap is an administrative capability of a user.
+type UserCap struct {
+	// Type of the capability, e.g. "users" or "buckets".
+	Type string `json:"type"`
+
+	// Perm is the permission granted for the capability.
+	// +kubebuilder:validation:Enum=read;write;"*"
+	Perm string `json:"perm"`
+}
+
+// UserObservation are the observable fields of a User.
+type UserObservation struct {
+	// AccessKeyID of the key published in the connection details of the
+	// User.
+	AccessKeyID string `json:"accessKeyID,omitempty"`
+
+	// Backends is the status of the user on each backend it exists on.
+	// +optional
+	Backends map[string]BackendUserStatus `json:"backends,omitempty"`
+}
+
+// BackendUserStatus is the status of a user on a single backend.
+type BackendUserStatus struct {
+	// SizeBytes is the total size of the objects of the user.
+	SizeBytes int64 `json:"sizeBytes"`
+
+	// Objects is the number of objects of the user.
+	Objects int64 `json:"objects"`
+
+	// Quota of the user on the backend.
+	// +optional
+	Quota *UserQuotaStatus `json:"quota,omitempty"`
+}
+
+// UserQuotaStatus is the quota of a user as reported by a backend.
+type UserQuotaStatus struct {
+	// Enabled is true if the size and object limits are enforced.
+	Enabled bool `json:"enabled"`
+
+	// MaxSizeBytes is the maximum total size of the objects of the user, or
+	// -1 if unlimited.
+	MaxSizeBytes int64 `json:"maxSizeBytes"`
+
+	// MaxObjects is the maximum number of objects of the user, or -1 if
+	// unlimited.
+	MaxObjects int64 `json:"maxObjects"`
+
+	// MaxBuckets is the maximum number of buckets the user may own.
+	MaxBuckets int `json:"maxBuckets"`
+}
+
+// A UserSpec defines the desired state of a User.
+type UserSpec struct {
+	xpv1.ResourceSpec `json:",inline"`
+	ForProvider       UserParameters `json:"forProvider"`
+}
+
+// A UserStatus represents the observed state of a User.
+type UserStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          UserObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A User is an RGW user. Its access and secret keys are published as the
+// access_key and secret_key connection details.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="EXTERNAL-NAME",type="string",JSONPath=".metadata.annotations.crossplane\\.io/external-name"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,managed,ceph}
+type User struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   UserSpec   `json:"spec"`
+	Status UserStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// UserList contains a list of User
+type UserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []User `json:"items"`
+}
+
+// User type metadata.
+var (
+	UserKind             = reflect.TypeOf(User{}).Name()
+	UserGroupKind        = schema.GroupKind{Group: Group, Kind: UserKind}.String()
+	UserKindAPIVersion   = UserKind + "." + SchemeGroupVersion.String()
+	UserGroupVersionKind = SchemeGroupVersion.WithKind(UserKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&User{}, &UserList{})
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// UserPolicyParameters are the configurable fields of a UserPolicy.
+type UserPolicyParameters struct {
+	// UserName is the uid of the user the policy is attached to. The user
+	// must be in the tenant of the admin credentials of the backend.
+	// +crossplane:generate:reference:type=User
+	// +crossplane:generate:reference:extractor=UserUID()
+	// +optional
+	UserName string `json:"userName,omitempty"`
+
+	// UserNameRef references the User the policy is attached to.
+	// +optional
+	UserNameRef *xpv1.Reference `json:"userNameRef,omitempty"`
+
+	// UserNameSelector selects the User the policy is attached to.
+	// +optional
+	UserNameSelector *xpv1.Selector `json:"userNameSelector,omitempty"`
+
+	// PolicyName is the name of the policy. Defaults to the name of the
+	// UserPolicy.
+	// +optional
+	PolicyName string `json:"policyName,omitempty"`
+
+	// PolicyDocument is the permission policy granted to the user.
+	// Documents that only differ in formatting are considered equal.
+	PolicyDocument string `json:"policyDocument"`
+}
+
+// UserPolicyObservation are the observable fields of a UserPolicy.
+type UserPolicyObservation struct{}
+
+// A UserPolicySpec defines the desired state of a UserPolicy.
+type UserPolicySpec struct {
+	xpv1.ResourceSpec `json:",inline"`
+	ForProvider       UserPolicyParameters `json:"forProvider"`
+}
+
+// A UserPolicyStatus represents the observed state of a UserPolicy.
+type UserPolicyStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          UserPolicyObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A UserPolicy is an inline permission policy of an RGW user.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="USER",type="string",JSONPath=".spec.forProvider.userName"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,managed,ceph}
+type UserPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   UserPolicySpec   `json:"spec"`
+	Status UserPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// UserPolicyList contains a list of UserPolicy
+type UserPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []UserPolicy `json:"items"`
+}
+
+// UserPolicy type metadata.
+var (
+	UserPolicyKind             = reflect.TypeOf(UserPolicy{}).Name()
+	UserPolicyGroupKind        = schema.GroupKind{Group: Group, Kind: UserPolicyKind}.String()
+	UserPolicyKindAPIVersion   = UserPolicyKind + "." + SchemeGroupVersion.String()
+	UserPolicyGroupVersionKind = SchemeGroupVersion.WithKind(UserPolicyKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&UserPolicy{}, &UserPolicyList{})
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// Reasons a ProviderConfig's backend is unavailable.
+const (
+	ReasonInvalidConfig         xpv1.ConditionReason = "InvalidConfig"
+	ReasonDNSFailure            xpv1.ConditionReason = "DNSFailure"
+	ReasonTLSError              xpv1.ConditionReason = "TLSError"
+	ReasonInvalidAccessKeyID    xpv1.ConditionReason = "InvalidAccessKeyId"
+	ReasonSignatureDoesNotMatch xpv1.ConditionReason = "SignatureDoesNotMatch"
+	ReasonAccessDenied          xpv1.ConditionReason = "AccessDenied"
+	ReasonConnectionFailure     xpv1.ConditionReason = "ConnectionFailure"
+)
+
+// BackendUnavailable returns a condition indicating that the backend of a
+// ProviderConfig cannot be used, for the supplied reason.
+func BackendUnavailable(reason xpv1.ConditionReason, err error) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               xpv1.TypeReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            err.Error(),
+	}
+}
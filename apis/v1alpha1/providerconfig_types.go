@@ -30,20 +30,234 @@ type ProviderConfigSpec struct {
 	// Credentials required to authenticate to this provider.
 	Credentials ProviderCredentials `json:"credentials"`
 
-	// HostBase url specified in s3cfg.
-	HostBase string `json:"hostBase"`
+	// HostBase url specified in s3cfg. Required unless the credentials
+	// source is S3Cfg, in which case it is taken from the s3cfg file if
+	// unset.
+	// +optional
+	HostBase string `json:"hostBase,omitempty"`
 
-	// HostBucket url specified in s3cfg.
+	// HostBucket url specified in s3cfg. A template such as
+	// "%(bucket)s.rgw.example.com" enables virtual-hosted style requests,
+	// otherwise path style requests are sent to HostBase.
 	HostBucket string `json:"hostBucket,omitempty"`
 
-	// UseHTTPS ceph cluster configuration.
-	UseHTTPS bool `json:"useHttps,omitempty"`
+	// UseHTTPS ceph cluster configuration. When unset it is taken from the
+	// s3cfg file of the S3Cfg credentials source, and defaults to false.
+	// +optional
+	UseHTTPS *bool `json:"useHttps,omitempty"`
+
+	// Region requests to the backend are signed for. For RGW this is the
+	// zonegroup name. Defaults to us-east-1.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Retry configures how failed requests to the backend are retried.
+	// +optional
+	Retry *RetryConfig `json:"retry,omitempty"`
+
+	// RequestTimeout is the timeout of a single attempt of a request to the
+	// backend. No timeout is applied if unset.
+	// +optional
+	RequestTimeout *metav1.Duration `json:"requestTimeout,omitempty"`
+
+	// DisablePayloadSigning sends requests with an UNSIGNED-PAYLOAD body hash
+	// instead of signing the request body.
+	// +optional
+	DisablePayloadSigning bool `json:"disablePayloadSigning,omitempty"`
+
+	// HTTP configures the HTTP transport used to reach the backend.
+	// +optional
+	HTTP *HTTPConfig `json:"http,omitempty"`
+
+	// TLS configures the TLS connection to the backend.
+	// +optional
+	TLS *TLSConfig `json:"tls,omitempty"`
+
+	// Mode of the backend. In Maintenance mode no new buckets are created on
+	// and no bucket is updated on the backend, and errors from it do not fail
+	// reconciles. Drain mode additionally places new buckets on the remaining
+	// backends as though this backend did not exist. Drain only affects new
+	// buckets, buckets and objects already on the backend are not moved.
+	// Buckets are still deleted from backends in Maintenance and Drain mode,
+	// and a Bucket is only removed once every backend confirmed the deletion.
+	// +kubebuilder:validation:Enum=Active;Maintenance;Drain
+	// +kubebuilder:default=Active
+	// +optional
+	Mode BackendMode `json:"mode,omitempty"`
+
+	// Admin configures access to the RGW Admin Ops API of the backend, used
+	// to manage users, quotas and bucket ownership. The Admin Ops API is only
+	// used when this is set, "admin: {}" uses the defaults.
+	// +optional
+	Admin *AdminConfig `json:"admin,omitempty"`
+
+	// Tenant of the RGW user of the credentials. Buckets without a tenant
+	// are placed in this tenant. RGW only lets users create buckets in
+	// their own tenant, buckets of other tenants are addressed as
+	// "tenant:bucket".
+	// +optional
+	Tenant string `json:"tenant,omitempty"`
+
+	// AssumeRole configures the provider to use temporary credentials of an
+	// RGW role, obtained from the STS API of the backend, instead of the
+	// credentials of the ProviderConfig.
+	// +optional
+	AssumeRole *AssumeRoleConfig `json:"assumeRole,omitempty"`
+}
+
+// AssumeRoleConfig configures the role the provider assumes through the STS
+// API of a backend. The credentials are cached per backend and refreshed
+// before they expire.
+type AssumeRoleConfig struct {
+	// RoleARN is the ARN of the role to assume, e.g.
+	// "arn:aws:iam:::role/provider-ceph".
+	RoleARN string `json:"roleARN"`
+
+	// RoleSessionName is the name of the role sessions of the provider.
+	// Defaults to "provider-ceph".
+	// +optional
+	RoleSessionName string `json:"roleSessionName,omitempty"`
+
+	// Duration of the role sessions. Defaults to 1h.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// WebIdentityTokenFile is the path of a web identity token, e.g. a
+	// projected service account token, the role is assumed with through
+	// AssumeRoleWithWebIdentity. The credentials of the ProviderConfig are
+	// used with AssumeRole if unset, so the credentials source should be
+	// None when a token is used.
+	// +optional
+	WebIdentityTokenFile string `json:"webIdentityTokenFile,omitempty"`
+}
+
+// AdminConfig configures access to the RGW Admin Ops API of a backend.
+type AdminConfig struct {
+	// Endpoint of the Admin Ops API, e.g. "https://rgw.example.com/admin".
+	// Defaults to HostBase with the "admin" path.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// CredentialsSecretRef references a Secret holding the access_key and
+	// secret_key of an RGW user with admin caps. Defaults to the
+	// credentials of the ProviderConfig.
+	// +optional
+	CredentialsSecretRef *xpv1.SecretReference `json:"credentialsSecretRef,omitempty"`
+}
+
+// RetryConfig configures how failed requests to a backend are retried.
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of attempts of a request, including
+	// the first one. Defaults to 3.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxAttempts *int `json:"maxAttempts,omitempty"`
+
+	// MaxBackoff is the maximum delay between two attempts of a request.
+	// Defaults to 20s.
+	// +optional
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
 }
 
+// HTTPConfig configures the HTTP transport used to reach a backend.
+type HTTPConfig struct {
+	// ProxyURL of the HTTP proxy requests to the backend are sent through.
+	// The HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are
+	// used if unset.
+	// +optional
+	ProxyURL string `json:"proxyURL,omitempty"`
+
+	// ProxyCredentialsSecretRef references a Secret holding the username
+	// and password keys used to authenticate to the proxy.
+	// +optional
+	ProxyCredentialsSecretRef *xpv1.SecretReference `json:"proxyCredentialsSecretRef,omitempty"`
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept
+	// open to the backend. Defaults to 100.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxIdleConnsPerHost *int `json:"maxIdleConnsPerHost,omitempty"`
+
+	// IdleConnTimeout is how long an idle connection is kept open.
+	// Defaults to 90s.
+	// +optional
+	IdleConnTimeout *metav1.Duration `json:"idleConnTimeout,omitempty"`
+
+	// DialTimeout is the timeout of establishing a connection to the
+	// backend. Defaults to 30s.
+	// +optional
+	DialTimeout *metav1.Duration `json:"dialTimeout,omitempty"`
+
+	// KeepAlive is the interval of TCP keep-alive probes on connections to
+	// the backend. Defaults to 30s.
+	// +optional
+	KeepAlive *metav1.Duration `json:"keepAlive,omitempty"`
+}
+
+// TLSConfig configures the TLS connection to a backend.
+type TLSConfig struct {
+	// CABundleSecretRef selects a Secret key holding PEM encoded CA
+	// certificates used to verify the backend, in addition to the system
+	// CA certificates.
+	// +optional
+	CABundleSecretRef *xpv1.SecretKeySelector `json:"caBundleSecretRef,omitempty"`
+
+	// CABundleConfigMapRef selects a ConfigMap key holding PEM encoded CA
+	// certificates used to verify the backend, in addition to the system
+	// CA certificates. Unlike referenced Secrets, the ConfigMap is not
+	// watched, changes to it are picked up on the next poll.
+	// +optional
+	CABundleConfigMapRef *ConfigMapKeySelector `json:"caBundleConfigMapRef,omitempty"`
+
+	// ClientCertSecretRef references a kubernetes.io/tls Secret holding the
+	// client certificate (tls.crt) and key (tls.key) used for mutual TLS.
+	// +optional
+	ClientCertSecretRef *xpv1.SecretReference `json:"clientCertSecretRef,omitempty"`
+
+	// MinVersion is the minimum TLS version accepted from the backend.
+	// +kubebuilder:validation:Enum="1.0";"1.1";"1.2";"1.3"
+	// +optional
+	MinVersion string `json:"minVersion,omitempty"`
+
+	// InsecureSkipVerify disables verification of the backend certificate.
+	// This should only be used for lab setups.
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// A ConfigMapKeySelector is a reference to a ConfigMap key in an arbitrary
+// namespace.
+type ConfigMapKeySelector struct {
+	// Name of the ConfigMap.
+	Name string `json:"name"`
+
+	// Namespace of the ConfigMap.
+	Namespace string `json:"namespace"`
+
+	// The key to select.
+	Key string `json:"key"`
+}
+
+// BackendMode is the operating mode of a backend.
+type BackendMode string
+
+// Backend modes.
+const (
+	BackendModeActive      BackendMode = "Active"
+	BackendModeMaintenance BackendMode = "Maintenance"
+	BackendModeDrain       BackendMode = "Drain"
+)
+
+// CredentialsSourceS3Cfg reads an s3cfg file from the selected Secret key and
+// derives both the credentials and the host settings of the backend from it.
+const CredentialsSourceS3Cfg xpv1.CredentialsSource = "S3Cfg"
+
 // ProviderCredentials required to authenticate.
 type ProviderCredentials struct {
-	// Source of the provider credentials.
-	// +kubebuilder:validation:Enum=None;Secret;InjectedIdentity;Environment;Filesystem
+	// Source of the provider credentials. The S3Cfg source reads an s3cfg
+	// file from the selected Secret key and also takes hostBase, hostBucket,
+	// useHttps and region from it where they are not set explicitly.
+	// +kubebuilder:validation:Enum=None;Secret;InjectedIdentity;Environment;Filesystem;S3Cfg
 	Source xpv1.CredentialsSource `json:"source"`
 
 	xpv1.CommonCredentialSelectors `json:",inline"`
@@ -58,6 +272,8 @@ type ProviderConfigStatus struct {
 
 // A ProviderConfig configures a Ceph provider.
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="MODE",type="string",JSONPath=".spec.mode"
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:printcolumn:name="SECRET-NAME",type="string",JSONPath=".spec.credentials.secretRef.name",priority=1
 // +kubebuilder:resource:scope=Cluster
@@ -64,6 +64,12 @@ func main() {
 
 		namespace                  = app.Flag("namespace", "Namespace used to set as default scope in default secret store config.").Default("crossplane-system").Envar("POD_NAMESPACE").String()
 		enableExternalSecretStores = app.Flag("enable-external-secret-stores", "Enable support for ExternalSecretStores.").Default("false").Envar("ENABLE_EXTERNAL_SECRET_STORES").Bool()
+
+		healthCheckInterval  = app.Flag("backend-health-check-interval", "How often the health of each s3 backend is checked. Set to 0 to disable health checks.").Default("30s").Duration()
+		healthCheckTimeout   = app.Flag("backend-health-check-timeout", "Timeout of a single s3 backend health check.").Default("5s").Duration()
+		unreachableThreshold = app.Flag("backend-unreachable-threshold", "Number of consecutive failed health checks after which an s3 backend is considered unreachable.").Default("3").Int()
+		bucketStatsInterval  = app.Flag("bucket-stats-interval", "How often the per-backend size, object count, shards and owner of each bucket are refreshed in its status. Set to 0 to refresh them on every poll.").Default("10m").Duration()
+		unhealthyPolicy      = app.Flag("unhealthy-backend-policy", "How controllers treat unreachable s3 backends. Ignore: use them as normal. Skip: leave them out of operations until they recover.").Default(string(backendstore.UnhealthyPolicyIgnore)).Enum(string(backendstore.UnhealthyPolicyIgnore), string(backendstore.UnhealthyPolicySkip))
 	)
 
 	var zo zap.Options
@@ -179,7 +185,14 @@ func main() {
 		})), "cannot create default store config")
 	}
 
-	backendStore := backendstore.NewBackendStore()
-	kingpin.FatalIfError(ceph.Setup(mgr, o, backendStore), "Cannot setup Ceph controllers")
+	backendStore := backendstore.NewBackendStore(backendstore.WithUnhealthyPolicy(backendstore.UnhealthyPolicy(*unhealthyPolicy)))
+	if *healthCheckInterval > 0 {
+		kingpin.FatalIfError(mgr.Add(backendstore.NewHealthChecker(backendStore, log.WithValues("component", "backend-health-checker"),
+			backendstore.WithHealthCheckInterval(*healthCheckInterval),
+			backendstore.WithHealthCheckTimeout(*healthCheckTimeout),
+			backendstore.WithUnreachableThreshold(*unreachableThreshold),
+		)), "Cannot add backend health checker")
+	}
+	kingpin.FatalIfError(ceph.Setup(mgr, o, backendStore, *bucketStatsInterval), "Cannot setup Ceph controllers")
 	kingpin.FatalIfError(mgr.Start(ctrl.SetupSignalHandler()), "Cannot start controller manager")
 }
@@ -2,61 +2,318 @@ package backendstore
 
 import (
 	"sync"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
 )
 
 // s3Backends is a map of S3 backend name (eg ceph cluster name) to S3 client.
 type s3Backends map[string]*s3.Client
 
+// adminClients is a map of S3 backend name to its Admin Ops API client.
+type adminClients map[string]*rgwadmin.Client
+
+// iamClients is a map of S3 backend name to its IAM API client.
+type iamClients map[string]*iam.Client
+
+// backend is a stored S3 backend along with the state tracked for it.
+type backend struct {
+	s3Client    *s3.Client
+	adminClient *rgwadmin.Client
+	iamClient   *iam.Client
+	health      Health
+	mode        apisv1alpha1.BackendMode
+	tenant      string
+}
+
 // BackendStore stores the active s3 backends.
 type BackendStore struct {
-	mu         sync.RWMutex
-	s3Backends s3Backends
+	mu              sync.RWMutex
+	backends        map[string]*backend
+	unhealthyPolicy UnhealthyPolicy
 }
 
-func NewBackendStore() *BackendStore {
-	return &BackendStore{
-		s3Backends: make(s3Backends),
+// A StoreOption configures a BackendStore.
+type StoreOption func(*BackendStore)
+
+// WithUnhealthyPolicy sets the policy used to decide whether unreachable
+// backends take part in operations.
+func WithUnhealthyPolicy(p UnhealthyPolicy) StoreOption {
+	return func(b *BackendStore) {
+		b.unhealthyPolicy = p
 	}
 }
 
+func NewBackendStore(o ...StoreOption) *BackendStore {
+	b := &BackendStore{
+		backends:        make(map[string]*backend),
+		unhealthyPolicy: UnhealthyPolicyIgnore,
+	}
+
+	for _, opt := range o {
+		opt(b)
+	}
+
+	return b
+}
+
 func (b *BackendStore) DeleteBackend(backendName string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	delete(b.s3Backends, backendName)
+	delete(b.backends, backendName)
 }
 
 func (b *BackendStore) AddOrUpdateBackend(backendName string, backendClient *s3.Client) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.s3Backends[backendName] = backendClient
+	// Keep the mode of an existing backend, it is refreshed separately. Its
+	// health was recorded for the replaced client, so it is unknown until
+	// the new client is checked.
+	if existing, ok := b.backends[backendName]; ok {
+		if existing.s3Client != backendClient {
+			existing.s3Client = backendClient
+			existing.health = Health{Status: HealthStatusUnknown}
+		}
+
+		return
+	}
+
+	b.backends[backendName] = &backend{
+		s3Client: backendClient,
+		health:   Health{Status: HealthStatusUnknown},
+		mode:     apisv1alpha1.BackendModeActive,
+	}
+}
+
+// SetBackendMode sets the operating mode of the named backend. An empty mode
+// is treated as Active.
+func (b *BackendStore) SetBackendMode(backendName string, mode apisv1alpha1.BackendMode) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if mode == "" {
+		mode = apisv1alpha1.BackendModeActive
+	}
+
+	if backend, ok := b.backends[backendName]; ok {
+		backend.mode = mode
+	}
+}
+
+// GetBackendMode returns the operating mode of the named backend.
+func (b *BackendStore) GetBackendMode(backendName string) apisv1alpha1.BackendMode {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if backend, ok := b.backends[backendName]; ok {
+		return backend.mode
+	}
+
+	return apisv1alpha1.BackendModeActive
+}
+
+// SetBackendTenant sets the tenant of the credentials of the named backend.
+func (b *BackendStore) SetBackendTenant(backendName, tenant string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if backend, ok := b.backends[backendName]; ok {
+		backend.tenant = tenant
+	}
+}
+
+// GetBackendTenant returns the tenant of the credentials of the named
+// backend.
+func (b *BackendStore) GetBackendTenant(backendName string) string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if backend, ok := b.backends[backendName]; ok {
+		return backend.tenant
+	}
+
+	return ""
 }
 
 func (b *BackendStore) GetBackend(backendName string) *s3.Client {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	if backend, ok := b.s3Backends[backendName]; ok {
-		return backend
+	if backend, ok := b.backends[backendName]; ok {
+		return backend.s3Client
 	}
 
 	return nil
 }
 
-func (b *BackendStore) GetAllBackends() s3Backends {
+// SetAdminClient sets the Admin Ops API client of the named backend. It is a
+// no-op if the backend is not stored.
+func (b *BackendStore) SetAdminClient(backendName string, adminClient *rgwadmin.Client) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+
+	if backend, ok := b.backends[backendName]; ok {
+		backend.adminClient = adminClient
+	}
+}
+
+// GetAdminClient returns the Admin Ops API client of the named backend.
+func (b *BackendStore) GetAdminClient(backendName string) *rgwadmin.Client {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if backend, ok := b.backends[backendName]; ok {
+		return backend.adminClient
+	}
+
+	return nil
+}
+
+// GetActiveAdminClients returns the Admin Ops API clients of the backends
+// returned by GetActiveBackends for the supplied modes. Backends without an
+// admin client are omitted.
+func (b *BackendStore) GetActiveAdminClients(modes ...apisv1alpha1.BackendMode) adminClients {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	clients := make(adminClients, len(b.backends))
+	for k, v := range b.backends {
+		if v.adminClient == nil || !b.isActive(v) || !InModes(v.mode, modes) {
+			continue
+		}
+		clients[k] = v.adminClient
+	}
+
+	return clients
+}
+
+// SetIAMClient sets the IAM API client of the named backend. It is a no-op if
+// the backend is not stored.
+func (b *BackendStore) SetIAMClient(backendName string, iamClient *iam.Client) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if backend, ok := b.backends[backendName]; ok {
+		backend.iamClient = iamClient
+	}
+}
+
+// GetIAMClient returns the IAM API client of the named backend.
+func (b *BackendStore) GetIAMClient(backendName string) *iam.Client {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if backend, ok := b.backends[backendName]; ok {
+		return backend.iamClient
+	}
+
+	return nil
+}
+
+// GetActiveIAMClients returns the IAM API clients of the backends returned by
+// GetActiveBackends for the supplied modes. Backends without an IAM client
+// are omitted.
+func (b *BackendStore) GetActiveIAMClients(modes ...apisv1alpha1.BackendMode) iamClients {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	clients := make(iamClients, len(b.backends))
+	for k, v := range b.backends {
+		if v.iamClient == nil || !b.isActive(v) || !InModes(v.mode, modes) {
+			continue
+		}
+		clients[k] = v.iamClient
+	}
+
+	return clients
+}
+
+func (b *BackendStore) GetAllBackends() s3Backends {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 	// Create a new s3Backends to hold a copy of the backends
-	backends := make(s3Backends, len(b.s3Backends))
-	for k, v := range b.s3Backends {
-		backends[k] = v
+	backends := make(s3Backends, len(b.backends))
+	for k, v := range b.backends {
+		backends[k] = v.s3Client
 	}
 
 	return backends
 }
 
+// GetActiveBackends returns a copy of the backends that should take part in
+// operations spanning all backends, according to the unhealthy policy. If
+// any modes are supplied, only backends in one of those modes are returned.
+func (b *BackendStore) GetActiveBackends(modes ...apisv1alpha1.BackendMode) s3Backends {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	backends := make(s3Backends, len(b.backends))
+	for k, v := range b.backends {
+		if !b.isActive(v) || !InModes(v.mode, modes) {
+			continue
+		}
+		backends[k] = v.s3Client
+	}
+
+	return backends
+}
+
+// InModes returns true if the supplied mode is one of the supplied modes, or
+// if no modes are supplied.
+func InModes(mode apisv1alpha1.BackendMode, modes []apisv1alpha1.BackendMode) bool {
+	if len(modes) == 0 {
+		return true
+	}
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsBackendActive returns true if the named backend is stored and should
+// take part in operations, according to the unhealthy policy.
+func (b *BackendStore) IsBackendActive(backendName string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if backend, ok := b.backends[backendName]; ok {
+		return b.isActive(backend)
+	}
+
+	return false
+}
+
+func (b *BackendStore) isActive(be *backend) bool {
+	return b.unhealthyPolicy != UnhealthyPolicySkip || be.health.Status != HealthStatusUnreachable
+}
+
+// recordHealthCheck updates the health of the named backend with the outcome
+// of a health check and returns its previous and new health. The update is
+// atomic, so that concurrent checks do not lose consecutive failures. It is
+// a no-op if the backend has been deleted in the meantime.
+func (b *BackendStore) recordHealthCheck(backendName string, checked time.Time, latency time.Duration, err error, unreachableThreshold int) (Health, Health) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	backend, ok := b.backends[backendName]
+	if !ok {
+		return Health{Status: HealthStatusUnknown}, Health{Status: HealthStatusUnknown}
+	}
+
+	prev := backend.health
+	backend.health = nextHealth(prev, checked, latency, err, unreachableThreshold)
+
+	return prev, backend.health
+}
+
 func (b *BackendStore) GetBackendStore() *BackendStore {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -68,5 +325,5 @@ func (b *BackendStore) BackendsAreStored() bool {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	return len(b.s3Backends) != 0
+	return len(b.backends) != 0
 }
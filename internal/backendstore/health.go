@@ -0,0 +1,187 @@
+package backendstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+const (
+	defaultHealthCheckInterval  = 30 * time.Second
+	defaultHealthCheckTimeout   = 5 * time.Second
+	defaultUnreachableThreshold = 3
+
+	// maxConcurrentHealthChecks is the maximum number of backends checked
+	// at the same time.
+	maxConcurrentHealthChecks = 10
+)
+
+// HealthStatus is the observed health of an S3 backend.
+type HealthStatus string
+
+const (
+	// HealthStatusUnknown means the backend has not been checked yet.
+	HealthStatusUnknown HealthStatus = "Unknown"
+	// HealthStatusHealthy means the last health check succeeded.
+	HealthStatusHealthy HealthStatus = "Healthy"
+	// HealthStatusDegraded means recent health checks failed, but not
+	// enough of them in a row to consider the backend unreachable.
+	HealthStatusDegraded HealthStatus = "Degraded"
+	// HealthStatusUnreachable means the backend failed the configured
+	// number of consecutive health checks.
+	HealthStatusUnreachable HealthStatus = "Unreachable"
+)
+
+// UnhealthyPolicy determines how unreachable backends are treated.
+type UnhealthyPolicy string
+
+const (
+	// UnhealthyPolicyIgnore treats unreachable backends like any other.
+	UnhealthyPolicyIgnore UnhealthyPolicy = "Ignore"
+	// UnhealthyPolicySkip leaves unreachable backends out of operations
+	// until they pass a health check again.
+	UnhealthyPolicySkip UnhealthyPolicy = "Skip"
+)
+
+// Health is the result of the most recent health checks of an S3 backend.
+type Health struct {
+	Status              HealthStatus
+	LastChecked         time.Time
+	Latency             time.Duration
+	ConsecutiveFailures int
+	LastError           error
+}
+
+// nextHealth returns the health of a backend given its previous health and
+// the outcome of a new health check.
+func nextHealth(prev Health, checked time.Time, latency time.Duration, err error, unreachableThreshold int) Health {
+	h := Health{
+		LastChecked: checked,
+		Latency:     latency,
+		LastError:   err,
+		Status:      HealthStatusHealthy,
+	}
+	if err == nil {
+		return h
+	}
+
+	h.ConsecutiveFailures = prev.ConsecutiveFailures + 1
+	h.Status = HealthStatusDegraded
+	if h.ConsecutiveFailures >= unreachableThreshold {
+		h.Status = HealthStatusUnreachable
+	}
+
+	return h
+}
+
+// A HealthCheckerOption configures a HealthChecker.
+type HealthCheckerOption func(*HealthChecker)
+
+// WithHealthCheckInterval sets how often backends are checked.
+func WithHealthCheckInterval(d time.Duration) HealthCheckerOption {
+	return func(h *HealthChecker) {
+		h.interval = d
+	}
+}
+
+// WithHealthCheckTimeout sets the timeout of a single health check.
+func WithHealthCheckTimeout(d time.Duration) HealthCheckerOption {
+	return func(h *HealthChecker) {
+		h.timeout = d
+	}
+}
+
+// WithUnreachableThreshold sets the number of consecutive failed health
+// checks after which a backend is considered unreachable.
+func WithUnreachableThreshold(n int) HealthCheckerOption {
+	return func(h *HealthChecker) {
+		h.unreachableThreshold = n
+	}
+}
+
+// HealthChecker periodically probes every stored backend and records its
+// health in the BackendStore.
+type HealthChecker struct {
+	store                *BackendStore
+	log                  logging.Logger
+	interval             time.Duration
+	timeout              time.Duration
+	unreachableThreshold int
+}
+
+// NewHealthChecker returns a HealthChecker for the backends of the supplied
+// BackendStore.
+func NewHealthChecker(s *BackendStore, log logging.Logger, o ...HealthCheckerOption) *HealthChecker {
+	h := &HealthChecker{
+		store:                s,
+		log:                  log,
+		interval:             defaultHealthCheckInterval,
+		timeout:              defaultHealthCheckTimeout,
+		unreachableThreshold: defaultUnreachableThreshold,
+	}
+
+	for _, opt := range o {
+		opt(h)
+	}
+
+	return h
+}
+
+// Start checks the health of all backends every interval until the supplied
+// context is done. A new round of checks only starts once the previous one
+// has finished, ticks missed in the meantime are dropped. It satisfies the
+// controller-runtime manager.Runnable interface.
+func (h *HealthChecker) Start(ctx context.Context) error {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		h.checkAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkAll checks all backends, at most maxConcurrentHealthChecks at a time,
+// and returns once every check has finished.
+func (h *HealthChecker) checkAll(ctx context.Context) {
+	g := new(errgroup.Group)
+	g.SetLimit(maxConcurrentHealthChecks)
+	for name, client := range h.store.GetAllBackends() {
+		name, client := name, client
+		g.Go(func() error {
+			h.check(ctx, name, client)
+
+			return nil
+		})
+	}
+	_ = g.Wait()
+}
+
+func (h *HealthChecker) check(ctx context.Context, backendName string, client *s3.Client) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.ListBuckets(ctx, &s3.ListBucketsInput{})
+	latency := time.Since(start)
+
+	prev, health := h.store.recordHealthCheck(backendName, start, latency, err, h.unreachableThreshold)
+
+	if health.Status != prev.Status {
+		if err != nil {
+			h.log.Info("S3 backend health changed", "backend name", backendName, "status", health.Status, "consecutive failures", health.ConsecutiveFailures, "error", err.Error())
+
+			return
+		}
+		h.log.Info("S3 backend health changed", "backend name", backendName, "status", health.Status, "latency", latency.String())
+	}
+}
@@ -0,0 +1,162 @@
+package backendstore
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+)
+
+func TestNextHealth(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	errBoom := errors.New("boom")
+
+	type args struct {
+		prev Health
+		err  error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   Health
+	}{
+		"SuccessfulCheck": {
+			reason: "A successful check should mark the backend healthy and reset failures.",
+			args: args{
+				prev: Health{Status: HealthStatusDegraded, ConsecutiveFailures: 2},
+			},
+			want: Health{Status: HealthStatusHealthy, LastChecked: now, Latency: time.Second},
+		},
+		"FirstFailure": {
+			reason: "A failed check below the threshold should mark the backend degraded.",
+			args: args{
+				prev: Health{Status: HealthStatusHealthy},
+				err:  errBoom,
+			},
+			want: Health{Status: HealthStatusDegraded, LastChecked: now, Latency: time.Second, ConsecutiveFailures: 1, LastError: errBoom},
+		},
+		"ThresholdReached": {
+			reason: "Reaching the threshold of consecutive failures should mark the backend unreachable.",
+			args: args{
+				prev: Health{Status: HealthStatusDegraded, ConsecutiveFailures: 2},
+				err:  errBoom,
+			},
+			want: Health{Status: HealthStatusUnreachable, LastChecked: now, Latency: time.Second, ConsecutiveFailures: 3, LastError: errBoom},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := nextHealth(tc.args.prev, now, time.Second, tc.args.err, 3)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nnextHealth(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRecordHealthCheckConcurrent(t *testing.T) {
+	t.Parallel()
+
+	const checks = 50
+
+	s := NewBackendStore()
+	s.AddOrUpdateBackend("backend", &s3.Client{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < checks; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.recordHealthCheck("backend", time.Now(), time.Second, errors.New("boom"), 3)
+		}()
+	}
+	wg.Wait()
+
+	if diff := cmp.Diff(checks, s.backends["backend"].health.ConsecutiveFailures); diff != "" {
+		t.Errorf("\nConcurrent failed checks should all be counted.\nrecordHealthCheck(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestAddOrUpdateBackendResetsHealth(t *testing.T) {
+	t.Parallel()
+
+	s := NewBackendStore(WithUnhealthyPolicy(UnhealthyPolicySkip))
+	client := &s3.Client{}
+	s.AddOrUpdateBackend("backend", client)
+	s.recordHealthCheck("backend", time.Now(), time.Second, errors.New("boom"), 1)
+
+	s.AddOrUpdateBackend("backend", client)
+	if s.IsBackendActive("backend") {
+		t.Errorf("AddOrUpdateBackend(...): the health of a backend should be kept if its client is not replaced")
+	}
+
+	s.AddOrUpdateBackend("backend", &s3.Client{})
+	if diff := cmp.Diff(HealthStatusUnknown, s.backends["backend"].health.Status); diff != "" {
+		t.Errorf("AddOrUpdateBackend(...): the health of a replaced client should be reset: -want, +got:\n%s", diff)
+	}
+}
+
+func TestGetActiveBackends(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		reason string
+		policy UnhealthyPolicy
+		modes  []apisv1alpha1.BackendMode
+		want   []string
+	}{
+		"PolicyIgnore": {
+			reason: "Unreachable backends should be returned when the policy is Ignore.",
+			policy: UnhealthyPolicyIgnore,
+			want:   []string{"healthy", "unreachable", "maintenance"},
+		},
+		"PolicySkip": {
+			reason: "Unreachable backends should be left out when the policy is Skip.",
+			policy: UnhealthyPolicySkip,
+			want:   []string{"healthy", "maintenance"},
+		},
+		"ActiveModeOnly": {
+			reason: "Backends in maintenance should be left out when only Active backends are requested.",
+			policy: UnhealthyPolicyIgnore,
+			modes:  []apisv1alpha1.BackendMode{apisv1alpha1.BackendModeActive},
+			want:   []string{"healthy", "unreachable"},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := NewBackendStore(WithUnhealthyPolicy(tc.policy))
+			s.AddOrUpdateBackend("healthy", &s3.Client{})
+			s.AddOrUpdateBackend("unreachable", &s3.Client{})
+			s.recordHealthCheck("healthy", time.Now(), time.Second, nil, 1)
+			s.recordHealthCheck("unreachable", time.Now(), time.Second, errors.New("boom"), 1)
+			s.AddOrUpdateBackend("maintenance", &s3.Client{})
+			s.SetBackendMode("maintenance", apisv1alpha1.BackendModeMaintenance)
+
+			got := []string{}
+			for _, n := range []string{"healthy", "unreachable", "maintenance"} {
+				if _, ok := s.GetActiveBackends(tc.modes...)[n]; ok {
+					got = append(got, n)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nGetActiveBackends(): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,529 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package accesskey
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/controller/adminops"
+	"github.com/crossplane/provider-ceph/internal/controller/features"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
+)
+
+const (
+	errNotAccessKey = "managed resource is not an AccessKey custom resource"
+	errTrackPCUsage = "cannot track ProviderConfig usage"
+	errNoUserID     = "no userID specified or resolved"
+	errCreateKey    = "cannot create key"
+	errRotateKey    = "cannot rotate key"
+	errRemoveKey    = "cannot remove key"
+	errSyncKey      = "cannot create key on all backends"
+	errNoSecretKey  = "cannot find secret key of current key on any backend"
+	errSaveState    = "cannot save key rotation state"
+
+	defaultKeyOverlap = time.Hour
+)
+
+// Setup adds a controller that reconciles AccessKey managed resources.
+func Setup(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore) error {
+	name := managed.ControllerName(v1alpha1.AccessKeyGroupKind)
+
+	cps := []managed.ConnectionPublisher{managed.NewAPISecretPublisher(mgr.GetClient(), mgr.GetScheme())}
+	if o.Features.Enabled(features.EnableAlphaExternalSecretStores) {
+		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
+	}
+
+	r := managed.NewReconciler(mgr,
+		resource.ManagedKind(v1alpha1.AccessKeyGroupVersionKind),
+		managed.WithExternalConnecter(&connector{
+			kube:         mgr.GetClient(),
+			usage:        resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+			backendStore: s,
+			log:          o.Logger.WithValues("controller", name),
+		}),
+		// The external name is the access key ID of the created key, so it
+		// must not default to the name of the AccessKey.
+		managed.WithInitializers(),
+		managed.WithReferenceResolver(managed.NewAPISimpleReferenceResolver(mgr.GetClient())),
+		managed.WithLogger(o.Logger.WithValues("controller", name)),
+		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		managed.WithConnectionPublishers(cps...))
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.AccessKey{}).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// A connector is expected to produce an ExternalClient when its Connect method
+// is called.
+type connector struct {
+	kube         client.Client
+	usage        resource.Tracker
+	backendStore *backendstore.BackendStore
+	log          logging.Logger
+}
+
+// Connect tracks that the managed resource is using a ProviderConfig and
+// returns an ExternalClient using the admin ops clients of the backend store.
+func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
+	if err := c.usage.Track(ctx, mg); err != nil {
+		return nil, errors.Wrap(err, errTrackPCUsage)
+	}
+
+	return &external{kube: c.kube, backendStore: c.backendStore.GetBackendStore(), log: c.log, now: time.Now}, nil
+}
+
+// An ExternalClient observes, then either creates, updates, or deletes an
+// external resource to ensure it reflects the managed resource's desired state.
+// An AccessKey is managed on the backends selected by adminops.Clients, with
+// the same key on every backend.
+//
+// The external name of an AccessKey is the access key ID of its current key.
+// It and the rotation state are recorded in annotations before keys are
+// created or removed, so that a failed create or lost status update never
+// leaks keys. The managed reconciler persists the annotations set by Create,
+// Update saves them itself.
+type external struct {
+	kube         client.Client
+	backendStore *backendstore.BackendStore
+	log          logging.Logger
+	now          func() time.Time
+}
+
+func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	cr, ok := mg.(*v1alpha1.AccessKey)
+	if !ok {
+		return managed.ExternalObservation{}, errors.New(errNotAccessKey)
+	}
+
+	current := meta.GetExternalName(cr)
+	if current == "" {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+	if cr.Spec.ForProvider.UserID == "" {
+		return managed.ExternalObservation{}, errors.New(errNoUserID)
+	}
+
+	clients, err := adminops.Clients(c.backendStore, cr, apisv1alpha1.BackendModeActive, apisv1alpha1.BackendModeMaintenance)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+
+	users, failed, err := c.getUsers(ctx, cr, clients)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if len(users) == 0 && len(failed) == len(clients) && adminops.IsSingleBackend(cr) {
+		// Errors from a backend in maintenance are expected, leave the
+		// key alone until the backend is back.
+		return managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}, nil
+	}
+
+	key, found := findKey(users, keySpec(cr).KeyUser(), current)
+	if !found {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+
+	now := c.now()
+	// Keys imported by setting the external name have no creation time,
+	// their rotation interval starts when they are first observed.
+	lateInitialized := false
+	if timeAnnotation(cr, v1alpha1.AnnotationKeyKeyCreatedAt) == nil {
+		setTimeAnnotation(cr, v1alpha1.AnnotationKeyKeyCreatedAt, now)
+		lateInitialized = true
+	}
+	setStatus(cr)
+	cr.Status.SetConditions(xpv1.Available())
+
+	upToDate := !rotationDue(cr, now) && !previousKeyExpired(cr, now)
+	for backendName := range clients {
+		if failed[backendName] || c.backendStore.GetBackendMode(backendName) != apisv1alpha1.BackendModeActive {
+			continue
+		}
+		if _, ok := findKey(map[string]*rgwadmin.User{backendName: users[backendName]}, key.User, current); !ok {
+			upToDate = false
+		}
+	}
+
+	return managed.ExternalObservation{
+		ResourceExists:          true,
+		ResourceUpToDate:        upToDate,
+		ResourceLateInitialized: lateInitialized,
+		ConnectionDetails:       connectionDetails(key),
+	}, nil
+}
+
+func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	cr, ok := mg.(*v1alpha1.AccessKey)
+	if !ok {
+		return managed.ExternalCreation{}, errors.New(errNotAccessKey)
+	}
+
+	cr.Status.SetConditions(xpv1.Creating())
+
+	if cr.Spec.ForProvider.UserID == "" {
+		return managed.ExternalCreation{}, errors.New(errNoUserID)
+	}
+
+	// New keys are only created on backends that are not in maintenance.
+	clients, err := adminops.Clients(c.backendStore, cr, apisv1alpha1.BackendModeActive)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+
+	key, err := newKey(cr)
+	if err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errCreateKey)
+	}
+
+	// The external name is recorded before the key is created, as it is
+	// persisted even if creating the key fails on some backends. The key
+	// is then found on the others and created on the rest by Update,
+	// rather than a new key being generated by the next Create.
+	meta.SetExternalName(cr, key.AccessKey)
+	setTimeAnnotation(cr, v1alpha1.AnnotationKeyKeyCreatedAt, c.now())
+
+	if err := c.createKey(ctx, cr, clients, key); err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errCreateKey)
+	}
+
+	return managed.ExternalCreation{ConnectionDetails: connectionDetails(key)}, nil
+}
+
+func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	cr, ok := mg.(*v1alpha1.AccessKey)
+	if !ok {
+		return managed.ExternalUpdate{}, errors.New(errNotAccessKey)
+	}
+
+	clients, err := adminops.Clients(c.backendStore, cr, apisv1alpha1.BackendModeActive)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+
+	users, failed, err := c.getUsers(ctx, cr, clients)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	for backendName := range failed {
+		delete(clients, backendName)
+	}
+
+	now := c.now()
+	if previousKeyExpired(cr, now) {
+		if err := c.removePreviousKey(ctx, cr); err != nil {
+			return managed.ExternalUpdate{}, err
+		}
+	}
+
+	current := meta.GetExternalName(cr)
+	key, found := findKey(users, keySpec(cr).KeyUser(), current)
+	if !found {
+		return managed.ExternalUpdate{}, errors.New(errNoSecretKey)
+	}
+
+	if rotationDue(cr, now) {
+		key, err = c.rotateKey(ctx, cr, clients, key, now)
+
+		return managed.ExternalUpdate{ConnectionDetails: connectionDetails(key)}, errors.Wrap(err, errRotateKey)
+	}
+
+	// Create the key on backends it is missing from, e.g. backends added
+	// after the key was created.
+	g := new(errgroup.Group)
+	for backendName, cl := range clients {
+		if _, ok := findKey(map[string]*rgwadmin.User{backendName: users[backendName]}, key.User, current); ok {
+			continue
+		}
+		cl := cl
+		g.Go(func() error {
+			spec := keySpec(cr)
+			spec.AccessKey, spec.SecretKey = key.AccessKey, key.SecretKey
+			_, err := cl.CreateKey(ctx, spec)
+
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errSyncKey)
+	}
+
+	return managed.ExternalUpdate{ConnectionDetails: connectionDetails(key)}, nil
+}
+
+func (c *external) Delete(ctx context.Context, mg resource.Managed) error {
+	cr, ok := mg.(*v1alpha1.AccessKey)
+	if !ok {
+		return errors.New(errNotAccessKey)
+	}
+
+	cr.Status.SetConditions(xpv1.Deleting())
+
+	// Keys are deleted from backends in maintenance too.
+	clients, err := adminops.Clients(c.backendStore, cr)
+	if err != nil {
+		return err
+	}
+
+	for _, accessKeyID := range []string{meta.GetExternalName(cr), cr.GetAnnotations()[v1alpha1.AnnotationKeyPreviousAccessKeyID]} {
+		if accessKeyID == "" {
+			continue
+		}
+		if err := c.removeKey(ctx, cr, clients, accessKeyID); err != nil {
+			return errors.Wrap(err, errRemoveKey)
+		}
+	}
+
+	return nil
+}
+
+// rotateKey creates a new key on the supplied backends and makes it the
+// current key. The replaced key stays valid until the overlap window has
+// passed. A key replaced earlier whose overlap window has not passed yet is
+// removed right away, so that at most two keys are valid at any time.
+func (c *external) rotateKey(ctx context.Context, cr *v1alpha1.AccessKey, clients map[string]*rgwadmin.Client, current rgwadmin.UserKey, now time.Time) (rgwadmin.UserKey, error) {
+	if err := c.removePreviousKey(ctx, cr); err != nil {
+		return current, err
+	}
+
+	key, err := newKey(cr)
+	if err != nil {
+		return current, err
+	}
+
+	overlap := defaultKeyOverlap
+	if o := cr.Spec.ForProvider.Rotation.Overlap; o != nil {
+		overlap = o.Duration
+	}
+
+	// Like Create, the new key is recorded before it is created. Should
+	// creating it fail everywhere, Create replaces it while the replaced
+	// key stays recorded as the previous key until it expires.
+	meta.SetExternalName(cr, key.AccessKey)
+	setTimeAnnotation(cr, v1alpha1.AnnotationKeyKeyCreatedAt, now)
+	meta.AddAnnotations(cr, map[string]string{v1alpha1.AnnotationKeyPreviousAccessKeyID: current.AccessKey})
+	setTimeAnnotation(cr, v1alpha1.AnnotationKeyPreviousKeyExpiresAt, now.Add(overlap))
+	if err := c.saveState(ctx, cr); err != nil {
+		return current, err
+	}
+
+	if err := c.createKey(ctx, cr, clients, key); err != nil {
+		return current, err
+	}
+
+	c.log.Info("Rotated key", "name", cr.Name, "access key id", key.AccessKey, "previous access key id", current.AccessKey)
+
+	return key, nil
+}
+
+// removePreviousKey removes the key replaced by the last rotation, if any,
+// from every backend, including those in maintenance, as Delete does. It is
+// only forgotten once every backend removed it, so that no rotated out key
+// stays valid.
+func (c *external) removePreviousKey(ctx context.Context, cr *v1alpha1.AccessKey) error {
+	prev := cr.GetAnnotations()[v1alpha1.AnnotationKeyPreviousAccessKeyID]
+	if prev == "" {
+		return nil
+	}
+
+	clients, err := adminops.Clients(c.backendStore, cr)
+	if err != nil {
+		return err
+	}
+	if err := c.removeKey(ctx, cr, clients, prev); err != nil {
+		return errors.Wrap(err, errRemoveKey)
+	}
+
+	meta.RemoveAnnotations(cr, v1alpha1.AnnotationKeyPreviousAccessKeyID, v1alpha1.AnnotationKeyPreviousKeyExpiresAt)
+
+	return c.saveState(ctx, cr)
+}
+
+// saveState persists the annotations recording the key rotation state, which
+// the managed reconciler does not persist after Update. The status, which is
+// persisted after Update, is kept and mirrors the annotations.
+func (c *external) saveState(ctx context.Context, cr *v1alpha1.AccessKey) error {
+	status := cr.Status.DeepCopy()
+	err := c.kube.Update(ctx, cr)
+	cr.Status = *status
+	setStatus(cr)
+
+	return errors.Wrap(err, errSaveState)
+}
+
+// newKey generates a key pair of the user or subuser of the AccessKey. The
+// key pair is generated here rather than by RGW so that the key is the same
+// on every backend.
+func newKey(cr *v1alpha1.AccessKey) (rgwadmin.UserKey, error) {
+	accessKey, secretKey, err := rgwadmin.GenerateKeyPair()
+	if err != nil {
+		return rgwadmin.UserKey{}, err
+	}
+
+	return rgwadmin.UserKey{User: keySpec(cr).KeyUser(), AccessKey: accessKey, SecretKey: secretKey}, nil
+}
+
+// createKey creates the supplied key on all supplied backends.
+func (c *external) createKey(ctx context.Context, cr *v1alpha1.AccessKey, clients map[string]*rgwadmin.Client, key rgwadmin.UserKey) error {
+	spec := keySpec(cr)
+	spec.AccessKey, spec.SecretKey = key.AccessKey, key.SecretKey
+
+	g := new(errgroup.Group)
+	for _, cl := range clients {
+		cl := cl
+		g.Go(func() error {
+			_, err := cl.CreateKey(ctx, spec)
+
+			return err
+		})
+	}
+
+	return g.Wait()
+}
+
+// removeKey removes a key from all supplied backends, ignoring backends it
+// does not exist on.
+func (c *external) removeKey(ctx context.Context, cr *v1alpha1.AccessKey, clients map[string]*rgwadmin.Client, accessKeyID string) error {
+	spec := keySpec(cr)
+	spec.AccessKey = accessKeyID
+
+	g := new(errgroup.Group)
+	for _, cl := range clients {
+		cl := cl
+		g.Go(func() error {
+			err := cl.RemoveKey(ctx, spec)
+			if rgwadmin.IsNotFound(err) {
+				return nil
+			}
+
+			return err
+		})
+	}
+
+	return g.Wait()
+}
+
+func (c *external) getUsers(ctx context.Context, cr *v1alpha1.AccessKey, clients map[string]*rgwadmin.Client) (map[string]*rgwadmin.User, map[string]bool, error) {
+	return adminops.GetUsers(ctx, c.backendStore, c.log, cr, clients, cr.Spec.ForProvider.Tenant, cr.Spec.ForProvider.UserID, false)
+}
+
+// setStatus mirrors the key rotation state recorded in the annotations of
+// the AccessKey in its status.
+func setStatus(cr *v1alpha1.AccessKey) {
+	cr.Status.AtProvider = v1alpha1.AccessKeyObservation{
+		AccessKeyID:          meta.GetExternalName(cr),
+		CreatedAt:            timeAnnotation(cr, v1alpha1.AnnotationKeyKeyCreatedAt),
+		PreviousAccessKeyID:  cr.GetAnnotations()[v1alpha1.AnnotationKeyPreviousAccessKeyID],
+		PreviousKeyExpiresAt: timeAnnotation(cr, v1alpha1.AnnotationKeyPreviousKeyExpiresAt),
+	}
+}
+
+// timeAnnotation returns the time recorded in the supplied annotation, or nil
+// if it is not set or cannot be parsed.
+func timeAnnotation(cr *v1alpha1.AccessKey, key string) *metav1.Time {
+	t, err := time.Parse(time.RFC3339, cr.GetAnnotations()[key])
+	if err != nil {
+		return nil
+	}
+
+	return &metav1.Time{Time: t}
+}
+
+func setTimeAnnotation(cr *v1alpha1.AccessKey, key string, t time.Time) {
+	meta.AddAnnotations(cr, map[string]string{key: t.UTC().Format(time.RFC3339)})
+}
+
+func keySpec(cr *v1alpha1.AccessKey) rgwadmin.KeySpec {
+	return rgwadmin.KeySpec{
+		Tenant:  cr.Spec.ForProvider.Tenant,
+		UserID:  cr.Spec.ForProvider.UserID,
+		Subuser: cr.Spec.ForProvider.Subuser,
+		KeyType: rgwadmin.KeyTypeS3,
+	}
+}
+
+// findKey returns the key of the supplied user or subuser with the supplied
+// access key ID, looking at the users of the backends in name order.
+func findKey(users map[string]*rgwadmin.User, keyUser, accessKeyID string) (rgwadmin.UserKey, bool) {
+	backendNames := make([]string, 0, len(users))
+	for name, u := range users {
+		if u != nil {
+			backendNames = append(backendNames, name)
+		}
+	}
+	sort.Strings(backendNames)
+
+	for _, name := range backendNames {
+		for _, k := range users[name].Keys {
+			if k.User == keyUser && k.AccessKey == accessKeyID {
+				return k, true
+			}
+		}
+	}
+
+	return rgwadmin.UserKey{}, false
+}
+
+// rotationDue returns true if the current key should be rotated.
+func rotationDue(cr *v1alpha1.AccessKey, now time.Time) bool {
+	r := cr.Spec.ForProvider.Rotation
+	createdAt := timeAnnotation(cr, v1alpha1.AnnotationKeyKeyCreatedAt)
+	if r == nil || r.Interval.Duration <= 0 || createdAt == nil {
+		return false
+	}
+
+	return !now.Before(createdAt.Add(r.Interval.Duration))
+}
+
+// previousKeyExpired returns true if the key replaced by the last rotation
+// should be removed.
+func previousKeyExpired(cr *v1alpha1.AccessKey, now time.Time) bool {
+	expiresAt := timeAnnotation(cr, v1alpha1.AnnotationKeyPreviousKeyExpiresAt)
+
+	return cr.GetAnnotations()[v1alpha1.AnnotationKeyPreviousAccessKeyID] != "" && (expiresAt == nil || !now.Before(expiresAt.Time))
+}
+
+func connectionDetails(key rgwadmin.UserKey) managed.ConnectionDetails {
+	return managed.ConnectionDetails{
+		v1alpha1.ConnectionDetailAccessKey: []byte(key.AccessKey),
+		v1alpha1.ConnectionDetailSecretKey: []byte(key.SecretKey),
+	}
+}
@@ -0,0 +1,315 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package accesskey
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin/fake"
+)
+
+func TestRotation(t *testing.T) {
+	t.Parallel()
+
+	srv := fake.NewServer()
+	defer srv.Close()
+
+	s := backendstore.NewBackendStore()
+	s.AddOrUpdateBackend("s3-backend-1", s3.New(s3.Options{}))
+	s.SetAdminClient("s3-backend-1", srv.NewClient())
+
+	ctx := context.Background()
+	if _, err := srv.NewClient().CreateUser(ctx, rgwadmin.UserSpec{UserID: "alice", DisplayName: "Alice", AccessKey: "USERKEY", SecretKey: "USERSECRET"}); err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := external{kube: &test.MockClient{MockUpdate: test.NewMockUpdateFn(nil)}, backendStore: s, log: logging.NewNopLogger(), now: func() time.Time { return now }}
+
+	cr := &v1alpha1.AccessKey{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice-key"},
+		Spec: v1alpha1.AccessKeySpec{ForProvider: v1alpha1.AccessKeyParameters{
+			UserID: "alice",
+			Rotation: &v1alpha1.KeyRotation{
+				Interval: metav1.Duration{Duration: 24 * time.Hour},
+				Overlap:  &metav1.Duration{Duration: time.Hour},
+			},
+		}},
+	}
+	cr.SetProviderConfigReference(&xpv1.Reference{Name: "s3-backend-1"})
+
+	// accessKeys returns the keys of the user, except the one it was
+	// created with.
+	accessKeys := func() []string {
+		u, _ := srv.GetUser("alice")
+		keys := []string{}
+		for _, k := range u.Keys {
+			if k.AccessKey != "USERKEY" {
+				keys = append(keys, k.AccessKey)
+			}
+		}
+
+		return keys
+	}
+
+	// Create the key. Only the annotations survive creation.
+	created, err := e.Create(ctx, cr)
+	if err != nil {
+		t.Fatalf("e.Create(...): %v", err)
+	}
+	cr.Status = v1alpha1.AccessKeyStatus{}
+	first := string(created.ConnectionDetails[v1alpha1.ConnectionDetailAccessKey])
+	if diff := cmp.Diff(first, meta.GetExternalName(cr)); diff != "" {
+		t.Errorf("e.Create(...): -want external name, +got external name:\n%s", diff)
+	}
+
+	o, err := e.Observe(ctx, cr)
+	if err != nil {
+		t.Fatalf("e.Observe(...): %v", err)
+	}
+	if !o.ResourceExists || !o.ResourceUpToDate {
+		t.Errorf("e.Observe(...): want new key to exist and be up to date, got %+v", o)
+	}
+
+	// Rotate once the interval has passed, keeping the previous key.
+	now = now.Add(25 * time.Hour)
+	if o, _ := e.Observe(ctx, cr); o.ResourceUpToDate {
+		t.Errorf("e.Observe(...): want rotation to be due")
+	}
+	updated, err := e.Update(ctx, cr)
+	if err != nil {
+		t.Fatalf("e.Update(...): %v", err)
+	}
+	second := string(updated.ConnectionDetails[v1alpha1.ConnectionDetailAccessKey])
+	if diff := cmp.Diff(first, cr.Status.AtProvider.PreviousAccessKeyID); diff != "" {
+		t.Errorf("e.Update(...): -want previous access key ID, +got:\n%s", diff)
+	}
+	// The rotation must not rely on the status being persisted.
+	cr.Status = v1alpha1.AccessKeyStatus{}
+	if diff := cmp.Diff([]string{first, second}, accessKeys()); diff != "" {
+		t.Errorf("e.Update(...): -want keys during overlap, +got keys:\n%s", diff)
+	}
+	if o, err := e.Observe(ctx, cr); err != nil || !o.ResourceUpToDate {
+		t.Errorf("e.Observe(...): want rotated key to be up to date, got %+v, %v", o, err)
+	}
+	if diff := cmp.Diff(second, cr.Status.AtProvider.AccessKeyID); diff != "" {
+		t.Errorf("e.Observe(...): -want access key ID, +got:\n%s", diff)
+	}
+
+	// Remove the previous key once the overlap window has passed.
+	now = now.Add(2 * time.Hour)
+	if o, _ := e.Observe(ctx, cr); o.ResourceUpToDate {
+		t.Errorf("e.Observe(...): want previous key removal to be due")
+	}
+	if _, err := e.Update(ctx, cr); err != nil {
+		t.Fatalf("e.Update(...): %v", err)
+	}
+	if diff := cmp.Diff([]string{second}, accessKeys()); diff != "" {
+		t.Errorf("e.Update(...): -want keys after overlap, +got keys:\n%s", diff)
+	}
+
+	if err := e.Delete(ctx, cr); err != nil {
+		t.Fatalf("e.Delete(...): %v", err)
+	}
+	if diff := cmp.Diff([]string{}, accessKeys()); diff != "" {
+		t.Errorf("e.Delete(...): -want keys, +got keys:\n%s", diff)
+	}
+}
+
+func TestCreatePartialFailure(t *testing.T) {
+	t.Parallel()
+
+	servers := map[string]*fake.Server{"s3-backend-1": fake.NewServer(), "s3-backend-2": fake.NewServer()}
+	s := backendstore.NewBackendStore()
+	for name, srv := range servers {
+		defer srv.Close()
+		s.AddOrUpdateBackend(name, s3.New(s3.Options{}))
+		s.SetAdminClient(name, srv.NewClient())
+	}
+
+	// The user is missing from the second backend, so creating the key
+	// fails there.
+	ctx := context.Background()
+	if _, err := servers["s3-backend-1"].NewClient().CreateUser(ctx, rgwadmin.UserSpec{UserID: "alice", DisplayName: "Alice", AccessKey: "USERKEY", SecretKey: "USERSECRET"}); err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+
+	e := external{kube: &test.MockClient{MockUpdate: test.NewMockUpdateFn(nil)}, backendStore: s, log: logging.NewNopLogger(), now: time.Now}
+	cr := &v1alpha1.AccessKey{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice-key"},
+		Spec:       v1alpha1.AccessKeySpec{ForProvider: v1alpha1.AccessKeyParameters{UserID: "alice"}},
+	}
+	if _, err := e.Create(ctx, cr); err == nil {
+		t.Fatalf("e.Create(...): want error creating key on s3-backend-2, got nil")
+	}
+	if meta.GetExternalName(cr) == "" {
+		t.Fatalf("e.Create(...): want external name recorded despite the error")
+	}
+
+	// Once the user exists, the key created on the first backend is
+	// observed and created on the second rather than a new one generated.
+	if _, err := servers["s3-backend-2"].NewClient().CreateUser(ctx, rgwadmin.UserSpec{UserID: "alice", DisplayName: "Alice", AccessKey: "USERKEY", SecretKey: "USERSECRET"}); err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+	o, err := e.Observe(ctx, cr)
+	if err != nil {
+		t.Fatalf("e.Observe(...): %v", err)
+	}
+	if !o.ResourceExists || o.ResourceUpToDate {
+		t.Fatalf("e.Observe(...): want key to exist but not be up to date, got %+v", o)
+	}
+	if _, err := e.Update(ctx, cr); err != nil {
+		t.Fatalf("e.Update(...): %v", err)
+	}
+
+	for name, srv := range servers {
+		u, _ := srv.GetUser("alice")
+		keys := []string{}
+		for _, k := range u.Keys {
+			if k.AccessKey != "USERKEY" {
+				keys = append(keys, k.AccessKey)
+			}
+		}
+		if diff := cmp.Diff([]string{meta.GetExternalName(cr)}, keys); diff != "" {
+			t.Errorf("%s: -want keys, +got keys:\n%s", name, diff)
+		}
+	}
+}
+
+func TestRemovePreviousKeyMaintenance(t *testing.T) {
+	t.Parallel()
+
+	servers := map[string]*fake.Server{"s3-backend-1": fake.NewServer(), "s3-backend-2": fake.NewServer()}
+	s := backendstore.NewBackendStore()
+	ctx := context.Background()
+	for name, srv := range servers {
+		defer srv.Close()
+		s.AddOrUpdateBackend(name, s3.New(s3.Options{}))
+		s.SetAdminClient(name, srv.NewClient())
+		if _, err := srv.NewClient().CreateUser(ctx, rgwadmin.UserSpec{UserID: "alice", DisplayName: "Alice", AccessKey: "USERKEY", SecretKey: "USERSECRET"}); err != nil {
+			t.Fatalf("CreateUser(...): %v", err)
+		}
+	}
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := external{kube: &test.MockClient{MockUpdate: test.NewMockUpdateFn(nil)}, backendStore: s, log: logging.NewNopLogger(), now: func() time.Time { return now }}
+	cr := &v1alpha1.AccessKey{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice-key"},
+		Spec: v1alpha1.AccessKeySpec{ForProvider: v1alpha1.AccessKeyParameters{
+			UserID: "alice",
+			Rotation: &v1alpha1.KeyRotation{
+				Interval: metav1.Duration{Duration: 24 * time.Hour},
+				Overlap:  &metav1.Duration{Duration: time.Hour},
+			},
+		}},
+	}
+
+	if _, err := e.Create(ctx, cr); err != nil {
+		t.Fatalf("e.Create(...): %v", err)
+	}
+	now = now.Add(25 * time.Hour)
+	updated, err := e.Update(ctx, cr)
+	if err != nil {
+		t.Fatalf("e.Update(...): %v", err)
+	}
+	second := string(updated.ConnectionDetails[v1alpha1.ConnectionDetailAccessKey])
+
+	// The previous key is removed from the backend in maintenance too,
+	// rather than staying valid there after it is forgotten.
+	s.SetBackendMode("s3-backend-2", apisv1alpha1.BackendModeMaintenance)
+	now = now.Add(2 * time.Hour)
+	if _, err := e.Update(ctx, cr); err != nil {
+		t.Fatalf("e.Update(...): %v", err)
+	}
+	if prev := cr.GetAnnotations()[v1alpha1.AnnotationKeyPreviousAccessKeyID]; prev != "" {
+		t.Errorf("e.Update(...): want previous key forgotten, got %q", prev)
+	}
+
+	for name, srv := range servers {
+		u, _ := srv.GetUser("alice")
+		keys := []string{}
+		for _, k := range u.Keys {
+			if k.AccessKey != "USERKEY" {
+				keys = append(keys, k.AccessKey)
+			}
+		}
+		if diff := cmp.Diff([]string{second}, keys); diff != "" {
+			t.Errorf("%s: -want keys, +got keys:\n%s", name, diff)
+		}
+	}
+}
+
+func TestRotationDue(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		reason   string
+		rotation *v1alpha1.KeyRotation
+		now      time.Time
+		want     bool
+	}{
+		"NoRotation": {
+			reason: "Keys without a rotation should never be rotated.",
+			now:    createdAt.Add(1000 * time.Hour),
+			want:   false,
+		},
+		"BeforeInterval": {
+			reason:   "Keys should not be rotated before the interval has passed.",
+			rotation: &v1alpha1.KeyRotation{Interval: metav1.Duration{Duration: time.Hour}},
+			now:      createdAt.Add(59 * time.Minute),
+			want:     false,
+		},
+		"AfterInterval": {
+			reason:   "Keys should be rotated once the interval has passed.",
+			rotation: &v1alpha1.KeyRotation{Interval: metav1.Duration{Duration: time.Hour}},
+			now:      createdAt.Add(time.Hour),
+			want:     true,
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cr := &v1alpha1.AccessKey{}
+			cr.Spec.ForProvider.Rotation = tc.rotation
+			setTimeAnnotation(cr, v1alpha1.AnnotationKeyKeyCreatedAt, createdAt)
+
+			got := rotationDue(cr, tc.now)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nrotationDue(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
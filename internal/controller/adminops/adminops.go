@@ -0,0 +1,204 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package adminops selects the RGW admin ops and IAM clients managed resources
+// are reconciled with.
+package adminops
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
+)
+
+const (
+	errBackendNotStored     = "s3 backend is not stored"
+	errNoAdminClient        = "s3 backend has no admin ops client"
+	errNoIAMClient          = "s3 backend has no IAM client"
+	errBackendUnreachable   = "s3 backend is unreachable"
+	errBackendInMaintenance = "s3 backend is in maintenance"
+	errNoS3BackendsStored   = "no s3 backends stored"
+	errNoActiveS3Backends   = "no active s3 backends"
+	errGetUser              = "cannot get user"
+	errNoBackendQueried     = "cannot query any s3 backend"
+
+	defaultPC = "default"
+)
+
+// IsSingleBackend returns true if the managed resource is managed on the
+// single backend it references, rather than on all backends. Like Buckets,
+// resources referencing the "default" ProviderConfig are managed on all
+// backends.
+func IsSingleBackend(mg resource.Managed) bool {
+	ref := mg.GetProviderConfigReference()
+
+	return ref != nil && ref.Name != defaultPC
+}
+
+// Clients returns the admin ops clients of the backends in one of the
+// supplied modes the managed resource is managed on, or of all backends if no
+// modes are supplied. A single referenced backend is returned in any mode,
+// unless only Active backends are requested.
+func Clients(s *backendstore.BackendStore, mg resource.Managed, modes ...apisv1alpha1.BackendMode) (map[string]*rgwadmin.Client, error) {
+	active := func(modes ...apisv1alpha1.BackendMode) map[string]*rgwadmin.Client {
+		return s.GetActiveAdminClients(modes...)
+	}
+
+	return selectClients(s, mg, s.GetAdminClient, active, errNoAdminClient, modes)
+}
+
+// IAMClients returns the IAM clients of the backends the managed resource is
+// managed on, like Clients does for admin ops clients.
+func IAMClients(s *backendstore.BackendStore, mg resource.Managed, modes ...apisv1alpha1.BackendMode) (map[string]*iam.Client, error) {
+	active := func(modes ...apisv1alpha1.BackendMode) map[string]*iam.Client {
+		return s.GetActiveIAMClients(modes...)
+	}
+
+	return selectClients(s, mg, s.GetIAMClient, active, errNoIAMClient, modes)
+}
+
+func selectClients[T any](s *backendstore.BackendStore, mg resource.Managed, get func(string) *T, active func(...apisv1alpha1.BackendMode) map[string]*T, errNoClient string, modes []apisv1alpha1.BackendMode) (map[string]*T, error) {
+	if IsSingleBackend(mg) {
+		backendName := mg.GetProviderConfigReference().Name
+		if err := checkStoredBackend(s, backendName); err != nil {
+			return nil, err
+		}
+		cl := get(backendName)
+		if cl == nil {
+			return nil, errors.New(errNoClient)
+		}
+		activeOnly := len(modes) == 1 && modes[0] == apisv1alpha1.BackendModeActive
+		if activeOnly && s.GetBackendMode(backendName) != apisv1alpha1.BackendModeActive {
+			return nil, errors.New(errBackendInMaintenance)
+		}
+
+		return map[string]*T{backendName: cl}, nil
+	}
+
+	if !s.BackendsAreStored() {
+		return nil, errors.New(errNoS3BackendsStored)
+	}
+
+	clients := active(modes...)
+	if len(clients) == 0 {
+		return nil, errors.New(errNoActiveS3Backends)
+	}
+
+	return clients, nil
+}
+
+func checkStoredBackend(s *backendstore.BackendStore, backendName string) error {
+	if s.GetBackend(backendName) == nil {
+		return errors.New(errBackendNotStored)
+	}
+
+	if !s.IsBackendActive(backendName) {
+		return errors.New(errBackendUnreachable)
+	}
+
+	return nil
+}
+
+// GetUsers returns the user with the supplied tenant and uid found on each of
+// the supplied backends, including its storage stats if requested, along with
+// the backends that could not be queried.
+// An error is returned if an Active backend cannot be queried, if none of the
+// backends of a resource managed on all backends can be queried, or if the
+// managed resource is being deleted and any backend cannot be queried, so
+// that it is only reported as gone once every backend confirmed it. Errors
+// from backends in other modes are logged and the backend skipped, like
+// Buckets do; callers leave a resource on a single such backend alone.
+func GetUsers(ctx context.Context, s *backendstore.BackendStore, log logging.Logger, mg resource.Managed, clients map[string]*rgwadmin.Client, tenant, uid string, stats bool) (map[string]*rgwadmin.User, map[string]bool, error) {
+	return Get(ctx, s, log, mg, clients, errGetUser, func(ctx context.Context, cl *rgwadmin.Client) (*rgwadmin.User, error) {
+		u, err := cl.GetUser(ctx, tenant, uid, stats)
+		if rgwadmin.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return u, err
+	})
+}
+
+// Get calls get with the client of each of the supplied backends and returns
+// the values found, along with the backends that could not be queried. get
+// returns nil and no error if the value does not exist on a backend.
+// Errors are handled like GetUsers does, and wrapped with errGet.
+func Get[C, T any](ctx context.Context, s *backendstore.BackendStore, log logging.Logger, mg resource.Managed, clients map[string]*C, errGet string, get func(context.Context, *C) (*T, error)) (map[string]*T, map[string]bool, error) {
+	var mu sync.Mutex
+	found := make(map[string]*T, len(clients))
+	failed := map[string]bool{}
+
+	g := new(errgroup.Group)
+	for backendName, cl := range clients {
+		backendName, cl := backendName, cl
+		g.Go(func() error {
+			v, err := get(ctx, cl)
+			switch {
+			case err != nil && (meta.WasDeleted(mg) || s.GetBackendMode(backendName) == apisv1alpha1.BackendModeActive):
+				return errors.Wrap(err, errGet)
+			case err != nil:
+				log.Info(errors.Wrap(err, errGet).Error(), "backend name", backendName)
+				mu.Lock()
+				defer mu.Unlock()
+				failed[backendName] = true
+
+				return nil
+			case v == nil:
+				return nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			found[backendName] = v
+
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return found, failed, err
+	}
+	if !IsSingleBackend(mg) && len(clients) > 0 && len(failed) == len(clients) {
+		return found, failed, errors.Wrap(errors.New(errNoBackendQueried), errGet)
+	}
+
+	return found, failed, nil
+}
+
+// FirstName returns the first backend name of the supplied map in name order,
+// so that the status of a resource managed on several backends is reported
+// from the same backend on every reconcile.
+func FirstName[T any](m map[string]T) string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names[0]
+}
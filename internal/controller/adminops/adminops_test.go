@@ -0,0 +1,252 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adminops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
+)
+
+func TestClients(t *testing.T) {
+	t.Parallel()
+
+	active := &rgwadmin.Client{}
+	maintenance := &rgwadmin.Client{}
+
+	newStore := func() *backendstore.BackendStore {
+		s := backendstore.NewBackendStore()
+		s.AddOrUpdateBackend("active", s3.New(s3.Options{}))
+		s.SetAdminClient("active", active)
+		s.AddOrUpdateBackend("maintenance", s3.New(s3.Options{}))
+		s.SetAdminClient("maintenance", maintenance)
+		s.SetBackendMode("maintenance", apisv1alpha1.BackendModeMaintenance)
+		s.AddOrUpdateBackend("no-admin", s3.New(s3.Options{}))
+
+		return s
+	}
+
+	withPC := func(name string) *v1alpha1.User {
+		cr := &v1alpha1.User{}
+		if name != "" {
+			cr.SetProviderConfigReference(&xpv1.Reference{Name: name})
+		}
+
+		return cr
+	}
+
+	type want struct {
+		clients map[string]*rgwadmin.Client
+		err     error
+	}
+
+	cases := map[string]struct {
+		reason string
+		store  *backendstore.BackendStore
+		cr     *v1alpha1.User
+		modes  []apisv1alpha1.BackendMode
+		want   want
+	}{
+		"NoBackendsStored": {
+			reason: "An error should be returned if no backends are stored.",
+			store:  backendstore.NewBackendStore(),
+			cr:     withPC(""),
+			want:   want{err: errors.New(errNoS3BackendsStored)},
+		},
+		"BackendNotStored": {
+			reason: "An error should be returned if the referenced backend is not stored.",
+			store:  newStore(),
+			cr:     withPC("missing"),
+			want:   want{err: errors.New(errBackendNotStored)},
+		},
+		"NoAdminClient": {
+			reason: "An error should be returned if the referenced backend has no admin client.",
+			store:  newStore(),
+			cr:     withPC("no-admin"),
+			want:   want{err: errors.New(errNoAdminClient)},
+		},
+		"SingleBackendInMaintenance": {
+			reason: "An error should be returned if an Active backend is required but the referenced one is in maintenance.",
+			store:  newStore(),
+			cr:     withPC("maintenance"),
+			modes:  []apisv1alpha1.BackendMode{apisv1alpha1.BackendModeActive},
+			want:   want{err: errors.New(errBackendInMaintenance)},
+		},
+		"SingleBackend": {
+			reason: "The referenced backend should be returned in any mode if not only Active backends are requested.",
+			store:  newStore(),
+			cr:     withPC("maintenance"),
+			modes:  []apisv1alpha1.BackendMode{apisv1alpha1.BackendModeActive, apisv1alpha1.BackendModeMaintenance},
+			want:   want{clients: map[string]*rgwadmin.Client{"maintenance": maintenance}},
+		},
+		"AllBackendsByMode": {
+			reason: "Only backends in the requested modes with an admin client should be returned.",
+			store:  newStore(),
+			cr:     withPC("default"),
+			modes:  []apisv1alpha1.BackendMode{apisv1alpha1.BackendModeActive},
+			want:   want{clients: map[string]*rgwadmin.Client{"active": active}},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Clients(tc.store, tc.cr, tc.modes...)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nClients(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.clients, got, cmp.Comparer(func(a, b *rgwadmin.Client) bool { return a == b })); diff != "" {
+				t.Errorf("\n%s\nClients(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	s := backendstore.NewBackendStore()
+	for _, name := range []string{"active", "maintenance"} {
+		s.AddOrUpdateBackend(name, s3.New(s3.Options{}))
+	}
+	s.SetBackendMode("maintenance", apisv1alpha1.BackendModeMaintenance)
+
+	// The clients are the names of their backends, get fails for backends
+	// listed in failing and finds the value on the others.
+	clients := func(names ...string) map[string]*string {
+		c := map[string]*string{}
+		for _, name := range names {
+			name := name
+			c[name] = &name
+		}
+
+		return c
+	}
+	deleted := func() *v1alpha1.User {
+		cr := &v1alpha1.User{}
+		now := metav1.Now()
+		cr.SetDeletionTimestamp(&now)
+
+		return cr
+	}
+	single := &v1alpha1.User{}
+	single.SetProviderConfigReference(&xpv1.Reference{Name: "maintenance"})
+	boom := errors.New("boom")
+
+	type want struct {
+		found  []string
+		failed map[string]bool
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason  string
+		cr      *v1alpha1.User
+		clients map[string]*string
+		failing map[string]bool
+		want    want
+	}{
+		"ActiveBackendFails": {
+			reason:  "An error should be returned if an Active backend cannot be queried.",
+			cr:      &v1alpha1.User{},
+			clients: clients("active", "maintenance"),
+			failing: map[string]bool{"active": true},
+			want:    want{err: errors.Wrap(boom, errGetUser)},
+		},
+		"BackendInMaintenanceFails": {
+			reason:  "Errors from backends in maintenance should be skipped.",
+			cr:      &v1alpha1.User{},
+			clients: clients("active", "maintenance"),
+			failing: map[string]bool{"maintenance": true},
+			want:    want{found: []string{"active"}, failed: map[string]bool{"maintenance": true}},
+		},
+		"AllBackendsFail": {
+			reason:  "An error should be returned if no backend can be queried.",
+			cr:      &v1alpha1.User{},
+			clients: clients("maintenance"),
+			failing: map[string]bool{"maintenance": true},
+			want:    want{err: errors.Wrap(errors.New(errNoBackendQueried), errGetUser)},
+		},
+		"SingleBackendInMaintenanceFails": {
+			reason:  "Errors from a single backend in maintenance should be left to the caller.",
+			cr:      single,
+			clients: clients("maintenance"),
+			failing: map[string]bool{"maintenance": true},
+			want:    want{found: []string{}, failed: map[string]bool{"maintenance": true}},
+		},
+		"DeletedBackendInMaintenanceFails": {
+			reason:  "A deleted resource should only be reported as gone once every backend confirmed it.",
+			cr:      deleted(),
+			clients: clients("active", "maintenance"),
+			failing: map[string]bool{"maintenance": true},
+			want:    want{err: errors.Wrap(boom, errGetUser)},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			found, failed, err := Get(context.Background(), s, logging.NewNopLogger(), tc.cr, tc.clients, errGetUser, func(_ context.Context, backendName *string) (*string, error) {
+				if tc.failing[*backendName] {
+					return nil, boom
+				}
+
+				return backendName, nil
+			})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nGet(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+			got := []string{}
+			for name := range found {
+				got = append(got, name)
+			}
+			if diff := cmp.Diff(tc.want.found, got); diff != "" {
+				t.Errorf("\n%s\nGet(...): -want found, +got found:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.failed, failed); diff != "" {
+				t.Errorf("\n%s\nGet(...): -want failed, +got failed:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestFirstName(t *testing.T) {
+	t.Parallel()
+
+	got := FirstName(map[string]bool{"s3-backend-2": true, "s3-backend-1": true, "s3-backend-3": true})
+	if diff := cmp.Diff("s3-backend-1", got); diff != "" {
+		t.Errorf("FirstName(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bucket
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
+	s3internal "github.com/crossplane/provider-ceph/internal/s3"
+)
+
+const (
+	errGetBucketInfo  = "cannot get bucket info"
+	errSetBucketQuota = "cannot set bucket quota"
+	errLinkBucket     = "cannot link bucket to owner"
+	errPlacement      = "bucket has placement rule %q on s3 backend %q instead of %q, the placement of a bucket cannot be changed after it is created"
+
+	defaultStorageClass = "STANDARD"
+)
+
+// adminClients returns the admin ops clients of the backends in one of the
+// supplied modes the bucket is managed on. Unlike other admin resources,
+// buckets do not require admin clients, backends without one are left out,
+// as are referenced backends in other modes.
+func (c *external) adminClients(bucket *v1alpha1.Bucket, modes ...apisv1alpha1.BackendMode) map[string]*rgwadmin.Client {
+	if bucket.GetProviderConfigReference() != nil && bucket.GetProviderConfigReference().Name != defaultPC {
+		backendName := bucket.GetProviderConfigReference().Name
+		cl := c.backendStore.GetAdminClient(backendName)
+		if cl == nil || !backendstore.InModes(c.backendStore.GetBackendMode(backendName), modes) {
+			return map[string]*rgwadmin.Client{}
+		}
+
+		return map[string]*rgwadmin.Client{backendName: cl}
+	}
+
+	return c.backendStore.GetActiveAdminClients(modes...)
+}
+
+// observeBackends records the stats and quota of the bucket on each backend
+// it is placed on in the status of the Bucket. Bucket stats are expensive to
+// gather on large buckets, so they are refreshed at most once per stats
+// interval. Backends that cannot be queried are left out.
+func (c *external) observeBackends(ctx context.Context, bucket *v1alpha1.Bucket) {
+	now := c.now()
+	if !statsDue(bucket, c.statsInterval, now) {
+		return
+	}
+
+	clients := c.adminClients(bucket, apisv1alpha1.BackendModeActive, apisv1alpha1.BackendModeMaintenance)
+	if len(clients) == 0 {
+		return
+	}
+
+	var mu sync.Mutex
+	backends := make(map[string]v1alpha1.BackendBucketStatus, len(clients))
+
+	var wg sync.WaitGroup
+	for backendName, cl := range clients {
+		wg.Add(1)
+		go func(backendName string, cl *rgwadmin.Client) {
+			defer wg.Done()
+
+			info, err := cl.GetBucketInfo(ctx, c.tenant(bucket, backendName), bucketName(bucket), true)
+			if err != nil {
+				if !rgwadmin.IsNotFound(err) {
+					c.log.Info(errors.Wrap(err, errGetBucketInfo).Error(), "bucket name", bucketName(bucket), "backend name", backendName)
+				}
+
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			backends[backendName] = backendStatus(info)
+		}(backendName, cl)
+	}
+	wg.Wait()
+
+	bucket.Status.AtProvider.Backends = backends
+	bucket.Status.AtProvider.LastUpdated = &metav1.Time{Time: now}
+}
+
+// statsDue returns true if the backends in the status of the bucket have not
+// been refreshed within the supplied interval.
+func statsDue(bucket *v1alpha1.Bucket, interval time.Duration, now time.Time) bool {
+	last := bucket.Status.AtProvider.LastUpdated
+
+	return last == nil || !now.Before(last.Add(interval))
+}
+
+// updateBackends links the bucket to its owner and sets its quota on every
+// active backend it is placed on, where they differ from the desired ones.
+// Backends in maintenance are left alone, like Observe ignores their errors.
+func (c *external) updateBackends(ctx context.Context, bucket *v1alpha1.Bucket) error {
+	p := bucket.Spec.ForProvider
+	if p.Owner == "" && p.Quota == nil {
+		return nil
+	}
+
+	var mu sync.Mutex
+	g := new(errgroup.Group)
+	for backendName, cl := range c.adminClients(bucket, apisv1alpha1.BackendModeActive) {
+		cl, tenant := cl, c.tenant(bucket, backendName)
+		g.Go(func() error {
+			info, err := cl.GetBucketInfo(ctx, tenant, bucketName(bucket), false)
+			if rgwadmin.IsNotFound(err) {
+				return nil
+			}
+			if err != nil {
+				return errors.Wrap(err, errGetBucketInfo)
+			}
+
+			changed, err := c.updateBackend(ctx, cl, bucket, tenant, info)
+			if err != nil || !changed {
+				return err
+			}
+
+			// Refresh the status on the next observation rather than
+			// waiting for the stats interval.
+			mu.Lock()
+			defer mu.Unlock()
+			bucket.Status.AtProvider.LastUpdated = nil
+
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+// updateBackend links the bucket to its owner and sets its quota on a single
+// backend, where the bucket belongs to the supplied tenant. It returns true if
+// either was changed.
+func (c *external) updateBackend(ctx context.Context, cl *rgwadmin.Client, bucket *v1alpha1.Bucket, tenant string, info *rgwadmin.BucketInfo) (bool, error) {
+	p := bucket.Spec.ForProvider
+	changed := false
+
+	// The owner of a bucket is a user of the same tenant.
+	if owner := rgwadmin.QualifiedUID(tenant, p.Owner); p.Owner != "" && owner != info.Owner {
+		// Linking the bucket to the new owner also unlinks it from the
+		// previous one.
+		if err := cl.LinkBucket(ctx, tenant, bucketName(bucket), info.ID, p.Owner); err != nil {
+			return false, errors.Wrap(err, errLinkBucket)
+		}
+		info.Owner = owner
+		changed = true
+	}
+
+	if p.Quota == nil {
+		return changed, nil
+	}
+	desired := desiredQuota(p.Quota)
+	if desired.Equal(info.BucketQuota) {
+		return changed, nil
+	}
+	if err := cl.SetBucketQuota(ctx, tenant, bucketName(bucket), info.Owner, desired); err != nil {
+		return false, errors.Wrap(err, errSetBucketQuota)
+	}
+
+	return true, nil
+}
+
+// desiredQuota returns the RGW quota for the supplied bucket quota. Unset
+// limits are unlimited.
+func desiredQuota(q *v1alpha1.BucketQuota) rgwadmin.Quota {
+	out := rgwadmin.Quota{
+		Enabled:    true,
+		MaxSize:    rgwadmin.QuotaUnlimited,
+		MaxObjects: rgwadmin.QuotaUnlimited,
+	}
+	if q.Enabled != nil {
+		out.Enabled = *q.Enabled
+	}
+	if q.MaxSize != nil {
+		out.MaxSize = q.MaxSize.Value()
+	}
+	if q.MaxObjects != nil {
+		out.MaxObjects = *q.MaxObjects
+	}
+
+	return out
+}
+
+// checkPlacement returns an error if the bucket has been placed on a backend
+// with a placement rule other than the requested one, as observed by
+// observeBackends.
+func checkPlacement(bucket *v1alpha1.Bucket) error {
+	p := bucket.Spec.ForProvider
+	if p.PlacementTarget == "" {
+		return nil
+	}
+
+	want := s3internal.PlacementRule(p)
+	for backendName, st := range bucket.Status.AtProvider.Backends {
+		if st.PlacementRule != "" && !placementMatches(want, st.PlacementRule) {
+			return errors.Errorf(errPlacement, st.PlacementRule, backendName, want)
+		}
+	}
+
+	return nil
+}
+
+// reportPlacement sets the Placement condition of a bucket with a placement
+// target. A mismatch is reported rather than failing the observation, so that
+// the owner and quota of the bucket are still updated.
+func reportPlacement(bucket *v1alpha1.Bucket) {
+	if bucket.Spec.ForProvider.PlacementTarget == "" {
+		return
+	}
+	if err := checkPlacement(bucket); err != nil {
+		bucket.Status.SetConditions(v1alpha1.PlacementMismatch(err))
+
+		return
+	}
+	bucket.Status.SetConditions(v1alpha1.PlacementMatched())
+}
+
+// placementMatches returns true if both placement rules are equal. RGW omits
+// the STANDARD storage class from placement rules.
+func placementMatches(a, b string) bool {
+	normalize := func(rule string) string {
+		if strings.Contains(rule, "/") {
+			return rule
+		}
+
+		return rule + "/" + defaultStorageClass
+	}
+
+	return normalize(a) == normalize(b)
+}
+
+func backendStatus(info *rgwadmin.BucketInfo) v1alpha1.BackendBucketStatus {
+	usage := info.Usage[rgwadmin.MainUsageCategory]
+
+	return v1alpha1.BackendBucketStatus{
+		SizeBytes:     usage.Size,
+		Objects:       usage.NumObjects,
+		NumShards:     info.NumShards,
+		Owner:         info.Owner,
+		PlacementRule: info.PlacementRule,
+		Quota: &v1alpha1.BucketQuotaStatus{
+			Enabled:      info.BucketQuota.Enabled,
+			MaxSizeBytes: info.BucketQuota.MaxSize,
+			MaxObjects:   info.BucketQuota.MaxObjects,
+		},
+	}
+}
@@ -0,0 +1,313 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bucket
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin/fake"
+)
+
+func TestUpdateBackends(t *testing.T) {
+	t.Parallel()
+
+	servers := map[string]*fake.Server{"s3-backend-1": fake.NewServer(), "s3-backend-2": fake.NewServer()}
+	s := backendstore.NewBackendStore()
+	for name, srv := range servers {
+		defer srv.Close()
+		s.AddOrUpdateBackend(name, s3.New(s3.Options{}))
+		s.SetAdminClient(name, srv.NewClient())
+	}
+	// The bucket has only been placed on one of the backends so far.
+	servers["s3-backend-1"].AddBucket(rgwadmin.BucketInfo{
+		Bucket:    "bucket",
+		Owner:     "alice",
+		NumShards: 11,
+		Usage:     map[string]rgwadmin.UsageStats{rgwadmin.MainUsageCategory: {Size: 2048, NumObjects: 2}},
+	})
+	if _, err := servers["s3-backend-1"].NewClient().CreateUser(context.Background(), rgwadmin.UserSpec{UserID: "bob", DisplayName: "Bob"}); err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+
+	now := time.Now()
+	e := external{backendStore: s, statsInterval: time.Hour, log: logging.NewNopLogger(), now: func() time.Time { return now }}
+	cr := &v1alpha1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucket"},
+		Spec: v1alpha1.BucketSpec{ForProvider: v1alpha1.BucketParameters{
+			// The quota is set for the new owner after linking the bucket.
+			Owner: "bob",
+			Quota: &v1alpha1.BucketQuota{
+				MaxSize:    resource.NewQuantity(1<<20, resource.BinarySI),
+				MaxObjects: pointer.Int64(100),
+			},
+		}},
+	}
+
+	ctx := context.Background()
+	if _, err := e.Update(ctx, cr); err != nil {
+		t.Fatalf("e.Update(...): %v", err)
+	}
+	e.observeBackends(ctx, cr)
+
+	want := map[string]v1alpha1.BackendBucketStatus{
+		"s3-backend-1": {
+			SizeBytes: 2048,
+			Objects:   2,
+			NumShards: 11,
+			Owner:     "bob",
+			Quota:     &v1alpha1.BucketQuotaStatus{Enabled: true, MaxSizeBytes: 1 << 20, MaxObjects: 100},
+		},
+	}
+	if diff := cmp.Diff(want, cr.Status.AtProvider.Backends); diff != "" {
+		t.Errorf("e.observeBackends(...): -want backends, +got backends:\n%s", diff)
+	}
+	if diff := cmp.Diff(&metav1.Time{Time: now}, cr.Status.AtProvider.LastUpdated); diff != "" {
+		t.Errorf("e.observeBackends(...): -want last updated, +got last updated:\n%s", diff)
+	}
+}
+
+func TestUpdateBackendsMaintenance(t *testing.T) {
+	t.Parallel()
+
+	srv := fake.NewServer()
+	cl := srv.NewClient()
+	// The backend fails every request.
+	srv.Close()
+
+	cases := map[string]struct {
+		reason string
+		mode   apisv1alpha1.BackendMode
+		want   error
+	}{
+		"Active": {
+			reason: "Errors from an Active backend should fail the update.",
+			mode:   apisv1alpha1.BackendModeActive,
+			want:   errors.New(errGetBucketInfo),
+		},
+		"Maintenance": {
+			reason: "A backend in maintenance should be left alone, like Observe ignores its errors.",
+			mode:   apisv1alpha1.BackendModeMaintenance,
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := backendstore.NewBackendStore()
+			s.AddOrUpdateBackend("s3-backend-1", s3.New(s3.Options{}))
+			s.SetAdminClient("s3-backend-1", cl)
+			s.SetBackendMode("s3-backend-1", tc.mode)
+
+			e := external{backendStore: s, statsInterval: time.Hour, log: logging.NewNopLogger(), now: time.Now}
+			cr := newerBucket("")
+			cr.Spec.ForProvider.Owner = "bob"
+
+			_, err := e.Update(context.Background(), cr)
+			if tc.want == nil && err != nil {
+				t.Errorf("\n%s\ne.Update(...): unexpected error: %v\n", tc.reason, err)
+			}
+			if tc.want != nil && (err == nil || !strings.Contains(err.Error(), tc.want.Error())) {
+				t.Errorf("\n%s\ne.Update(...): want error containing %q, got: %v\n", tc.reason, tc.want, err)
+			}
+		})
+	}
+}
+
+func TestStatsDue(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	cases := map[string]struct {
+		reason      string
+		lastUpdated *metav1.Time
+		interval    time.Duration
+		want        bool
+	}{
+		"NeverUpdated": {
+			reason: "Stats that were never gathered should be due.",
+			want:   true,
+		},
+		"WithinInterval": {
+			reason:      "Stats gathered within the interval should not be due.",
+			lastUpdated: &metav1.Time{Time: now.Add(-time.Minute)},
+			interval:    10 * time.Minute,
+			want:        false,
+		},
+		"IntervalElapsed": {
+			reason:      "Stats gathered longer than the interval ago should be due.",
+			lastUpdated: &metav1.Time{Time: now.Add(-time.Hour)},
+			interval:    10 * time.Minute,
+			want:        true,
+		},
+		"ZeroInterval": {
+			reason:      "Stats should be due on every observation with a zero interval.",
+			lastUpdated: &metav1.Time{Time: now},
+			want:        true,
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cr := &v1alpha1.Bucket{Status: v1alpha1.BucketStatus{AtProvider: v1alpha1.BucketObservation{LastUpdated: tc.lastUpdated}}}
+			got := statsDue(cr, tc.interval, now)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nstatsDue(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestQuotaUpToDate(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		quota  *v1alpha1.BucketQuota
+		actual rgwadmin.Quota
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   bool
+	}{
+		"UnlimitedDefaults": {
+			reason: "Unset limits should match an enabled quota RGW reports as unlimited",
+			args: args{
+				quota:  &v1alpha1.BucketQuota{},
+				actual: rgwadmin.Quota{Enabled: true, MaxSize: 0, MaxObjects: rgwadmin.QuotaUnlimited},
+			},
+			want: true,
+		},
+		"Disabled": {
+			reason: "A disabled quota should not match an enabled one",
+			args: args{
+				quota:  &v1alpha1.BucketQuota{Enabled: pointer.Bool(false)},
+				actual: rgwadmin.Quota{Enabled: true, MaxSize: rgwadmin.QuotaUnlimited, MaxObjects: rgwadmin.QuotaUnlimited},
+			},
+			want: false,
+		},
+		"MaxSizeChanged": {
+			reason: "A different size limit should not match",
+			args: args{
+				quota:  &v1alpha1.BucketQuota{MaxSize: resource.NewQuantity(1<<30, resource.BinarySI)},
+				actual: rgwadmin.Quota{Enabled: true, MaxSize: 1 << 20, MaxObjects: rgwadmin.QuotaUnlimited},
+			},
+			want: false,
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := desiredQuota(tc.args.quota).Equal(tc.args.actual)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ndesiredQuota(...).Equal(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCheckPlacement(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		p        v1alpha1.BucketParameters
+		backends map[string]v1alpha1.BackendBucketStatus
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   error
+	}{
+		"NoPlacementTarget": {
+			reason: "Any placement should be accepted without a placement target.",
+			args: args{
+				backends: map[string]v1alpha1.BackendBucketStatus{"s3-backend-1": {PlacementRule: "default-placement"}},
+			},
+		},
+		"StandardStorageClass": {
+			reason: "RGW omitting the STANDARD storage class should match.",
+			args: args{
+				p:        v1alpha1.BucketParameters{PlacementTarget: "fast-placement", DefaultStorageClass: "STANDARD"},
+				backends: map[string]v1alpha1.BackendBucketStatus{"s3-backend-1": {PlacementRule: "fast-placement"}},
+			},
+		},
+		"Mismatch": {
+			reason: "A bucket in another placement should be reported.",
+			args: args{
+				p:        v1alpha1.BucketParameters{PlacementTarget: "fast-placement"},
+				backends: map[string]v1alpha1.BackendBucketStatus{"s3-backend-1": {PlacementRule: "default-placement"}},
+			},
+			want: errors.Errorf(errPlacement, "default-placement", "s3-backend-1", "fast-placement"),
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cr := &v1alpha1.Bucket{
+				Spec:   v1alpha1.BucketSpec{ForProvider: tc.args.p},
+				Status: v1alpha1.BucketStatus{AtProvider: v1alpha1.BucketObservation{Backends: tc.args.backends}},
+			}
+			err := checkPlacement(cr)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ncheckPlacement(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+
+			// Mismatches are reported through the Placement condition.
+			wantStatus := corev1.ConditionUnknown
+			switch {
+			case tc.args.p.PlacementTarget == "":
+			case tc.want != nil:
+				wantStatus = corev1.ConditionFalse
+			default:
+				wantStatus = corev1.ConditionTrue
+			}
+			reportPlacement(cr)
+			if diff := cmp.Diff(wantStatus, cr.Status.GetCondition(v1alpha1.TypePlacement).Status); diff != "" {
+				t.Errorf("\n%s\nreportPlacement(...): -want condition status, +got condition status:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
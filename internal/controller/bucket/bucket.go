@@ -18,6 +18,8 @@ package bucket
 
 import (
 	"context"
+	"sort"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
@@ -34,6 +36,7 @@ import (
 	"github.com/crossplane/crossplane-runtime/pkg/controller"
 	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
 	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
@@ -52,13 +55,19 @@ const (
 	errListPC               = "cannot list ProviderConfigs"
 	errGetBucket            = "cannot get Bucket"
 	errListBuckets          = "cannot list Buckets"
+	errIndexBucketNames     = "cannot index Buckets by bucket name"
 	errCreateBucket         = "cannot create Bucket"
 	errDeleteBucket         = "cannot delete Bucket"
 	errGetCreds             = "cannot get credentials"
 	errBackendNotStored     = "s3 backend is not stored"
+	errBackendUnreachable   = "s3 backend is unreachable"
+	errBackendsUnreachable  = "cannot delete bucket from unreachable s3 backends %v"
+	errBackendInMaintenance = "s3 backend is in maintenance"
 	errNoS3BackendsStored   = "no s3 backends stored"
+	errNoActiveS3Backends   = "no active s3 backends"
 	errCodeBucketNotFound   = "NotFound"
 	errFailedToCreateClient = "failed to create s3 client"
+	errStorageClassNoTarget = "defaultStorageClass requires placementTarget to be set"
 
 	defaultPC = "default"
 )
@@ -70,10 +79,18 @@ var (
 	newNoOpService = func(_ []byte) (interface{}, error) { return &NoOpService{}, nil }
 )
 
-// Setup adds a controller that reconciles Bucket managed resources.
-func Setup(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore) error {
+// Setup adds a controller that reconciles Bucket managed resources. The
+// per-backend stats of each Bucket are refreshed at most once per
+// statsInterval.
+func Setup(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore, statsInterval time.Duration) error {
 	name := managed.ControllerName(v1alpha1.BucketGroupKind)
 
+	// Buckets sharing the name of their bucket are looked up on every
+	// observation.
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.Bucket{}, bucketNameField, bucketNames); err != nil {
+		return errors.Wrap(err, errIndexBucketNames)
+	}
+
 	cps := []managed.ConnectionPublisher{managed.NewAPISecretPublisher(mgr.GetClient(), mgr.GetScheme())}
 	if o.Features.Enabled(features.EnableAlphaExternalSecretStores) {
 		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
@@ -82,12 +99,14 @@ func Setup(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore)
 	r := managed.NewReconciler(mgr,
 		resource.ManagedKind(v1alpha1.BucketGroupVersionKind),
 		managed.WithExternalConnecter(&connector{
-			kube:         mgr.GetClient(),
-			usage:        resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
-			newServiceFn: newNoOpService,
-			backendStore: s,
-			log:          o.Logger.WithValues("controller", name),
+			kube:          mgr.GetClient(),
+			usage:         resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+			newServiceFn:  newNoOpService,
+			backendStore:  s,
+			statsInterval: statsInterval,
+			log:           o.Logger.WithValues("controller", name),
 		}),
+		managed.WithReferenceResolver(managed.NewAPISimpleReferenceResolver(mgr.GetClient())),
 		managed.WithLogger(o.Logger.WithValues("controller", name)),
 		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
 		managed.WithConnectionPublishers(cps...))
@@ -102,11 +121,12 @@ func Setup(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore)
 // A connector is expected to produce an ExternalClient when its Connect method
 // is called.
 type connector struct {
-	kube         client.Client
-	usage        resource.Tracker
-	newServiceFn func(creds []byte) (interface{}, error)
-	backendStore *backendstore.BackendStore
-	log          logging.Logger
+	kube          client.Client
+	usage         resource.Tracker
+	newServiceFn  func(creds []byte) (interface{}, error)
+	backendStore  *backendstore.BackendStore
+	statsInterval time.Duration
+	log           logging.Logger
 }
 
 // Connect typically produces an ExternalClient by:
@@ -119,14 +139,17 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 		return nil, errors.Wrap(err, errTrackPCUsage)
 	}
 
-	return &external{backendStore: c.backendStore.GetBackendStore(), log: c.log}, nil
+	return &external{kube: c.kube, backendStore: c.backendStore.GetBackendStore(), statsInterval: c.statsInterval, log: c.log, now: time.Now}, nil
 }
 
 // An ExternalClient observes, then either creates, updates, or deletes an
 // external resource to ensure it reflects the managed resource's desired state.
 type external struct {
-	backendStore *backendstore.BackendStore
-	log          logging.Logger
+	kube          client.Client
+	backendStore  *backendstore.BackendStore
+	statsInterval time.Duration
+	log           logging.Logger
+	now           func() time.Time
 }
 
 //nolint:cyclop,gocyclo //TODO: modularise func
@@ -135,15 +158,44 @@ func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 	if !ok {
 		return managed.ExternalObservation{}, errors.New(errNotBucket)
 	}
+
+	// Two Buckets managing the same bucket would delete it from under each
+	// other, only the older one manages it.
+	conflict, err := c.nameConflict(ctx, cr)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if conflict != nil {
+		if meta.WasDeleted(cr) {
+			// Release the Bucket without deleting the bucket.
+			return managed.ExternalObservation{ResourceExists: false}, nil
+		}
+
+		return managed.ExternalObservation{}, errors.Errorf(errNameConflict, conflict.Name)
+	}
 	// Where a bucket has a ProviderConfigReference Name, we can infer that this bucket is to be
 	// observed only on this S3 Backend. An empty config reference name will be automatically set
 	// to "default".
 	if cr.GetProviderConfigReference() != nil && cr.GetProviderConfigReference().Name != defaultPC {
-		bucketExists, err := c.bucketExists(ctx, cr.GetProviderConfigReference().Name, cr.Name)
+		backendName := cr.GetProviderConfigReference().Name
+		bucketExists, err := c.bucketExists(ctx, backendName, cr)
 		if err != nil {
-			return managed.ExternalObservation{}, err
+			// A deleted bucket is only gone once the backend confirms
+			// it, whatever its mode.
+			if meta.WasDeleted(cr) || c.backendStore.GetBackendMode(backendName) == apisv1alpha1.BackendModeActive {
+				return managed.ExternalObservation{}, err
+			}
+			// Errors from a backend in maintenance are expected, leave
+			// the bucket alone until the backend is back. Changes made
+			// to the bucket out of band meanwhile are only noticed then.
+			c.log.Info("Ignoring error from s3 backend in maintenance", "bucket name", cr.Name, "backend name", backendName, "error", err.Error())
+
+			return managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}, nil
 		}
 		if bucketExists {
+			c.observeBackends(ctx, cr)
+			reportPlacement(cr)
+
 			return managed.ExternalObservation{
 				// Return false when the external resource does not exist. This lets
 				// the managed resource reconciler know that it needs to call Create to
@@ -175,50 +227,79 @@ func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 		return managed.ExternalObservation{}, errors.New(errNoS3BackendsStored)
 	}
 
+	// Check for the bucket on each backend in a separate go routine. Backends
+	// being drained are left out so that new buckets are only created on the
+	// remaining backends. A deleted bucket is looked for on every backend, as
+	// it is deleted from all of them.
+	modes := []apisv1alpha1.BackendMode{apisv1alpha1.BackendModeActive, apisv1alpha1.BackendModeMaintenance}
+	if meta.WasDeleted(cr) {
+		modes = nil
+	}
+	allBackends := c.backendStore.GetActiveBackends(modes...)
+	if len(allBackends) == 0 {
+		return managed.ExternalObservation{}, errors.New(errNoActiveS3Backends)
+	}
+
 	type bucketExistsResult struct {
+		backendName  string
 		bucketExists bool
 		err          error
 	}
 
-	bucketExistsResults := make(chan bucketExistsResult)
-
-	// Check for the bucket on each backend in a separate go routine
-	allBackends := c.backendStore.GetAllBackends()
+	bucketExistsResults := make(chan bucketExistsResult, len(allBackends))
 	for s3BackendName := range allBackends {
-		go func(backendName, bucketName string) {
-			bucketExists, err := c.bucketExists(context.Background(), backendName, bucketName)
-			bucketExistsResults <- bucketExistsResult{bucketExists, err}
-		}(s3BackendName, cr.Name)
+		go func(backendName string) {
+			bucketExists, err := c.bucketExists(ctx, backendName, cr)
+			bucketExistsResults <- bucketExistsResult{backendName, bucketExists, err}
+		}(s3BackendName)
 	}
 
-	// Wait for any go routine to finish, if the bucket exists anywhere
-	// return 'ResourceExists: true' as resulting calls to Create or Delete
-	// are idempotent.
+	// If the bucket exists anywhere it is reported as existing, as resulting
+	// calls to Create or Delete are idempotent. Errors from backends in
+	// maintenance are logged and ignored like in Update, errors from Active
+	// backends fail the observation unless the bucket was found elsewhere.
+	// A deleted bucket is only reported as gone if no backend failed.
+	found := false
+	var observeErr error
 	for i := 0; i < len(allBackends); i++ {
 		result := <-bucketExistsResults
-		if result.err != nil {
-			c.log.Info(errors.Wrap(result.err, errGetBucket).Error())
-
-			continue
+		switch {
+		case result.err != nil && (meta.WasDeleted(cr) || c.backendStore.GetBackendMode(result.backendName) == apisv1alpha1.BackendModeActive):
+			observeErr = errors.Wrap(result.err, errGetBucket)
+		case result.err != nil:
+			c.log.Info(errors.Wrap(result.err, errGetBucket).Error(), "bucket name", cr.Name, "backend name", result.backendName)
+		case result.bucketExists:
+			found = true
 		}
+	}
 
-		if result.bucketExists {
-			return managed.ExternalObservation{
-				// Return false when the external resource does not exist. This lets
-				// the managed resource reconciler know that it needs to call Create to
-				// (re)create the resource, or that it has successfully been deleted.
-				ResourceExists: true,
+	if found {
+		c.observeBackends(ctx, cr)
+		reportPlacement(cr)
 
-				// Return false when the external resource exists, but it not up to date
-				// with the desired managed resource state. This lets the managed
-				// resource reconciler know that it needs to call Update.
-				ResourceUpToDate: false,
+		return managed.ExternalObservation{
+			// Return false when the external resource does not exist. This lets
+			// the managed resource reconciler know that it needs to call Create to
+			// (re)create the resource, or that it has successfully been deleted.
+			ResourceExists: true,
 
-				// Return any details that may be required to connect to the external
-				// resource. These will be stored as the connection secret.
-				ConnectionDetails: managed.ConnectionDetails{},
-			}, nil
-		}
+			// Return false when the external resource exists, but it not up to date
+			// with the desired managed resource state. This lets the managed
+			// resource reconciler know that it needs to call Update.
+			ResourceUpToDate: false,
+
+			// Return any details that may be required to connect to the external
+			// resource. These will be stored as the connection secret.
+			ConnectionDetails: managed.ConnectionDetails{},
+		}, nil
+	}
+	if observeErr != nil {
+		return managed.ExternalObservation{}, observeErr
+	}
+
+	// A deleted bucket is only gone once every backend could be checked.
+	if unreachable := c.unreachableBackends(); meta.WasDeleted(cr) && len(unreachable) > 0 {
+		return managed.ExternalObservation{}, errors.Errorf(errBackendsUnreachable, unreachable)
 	}
 
 	// bucket not found anywhere.
@@ -236,6 +317,10 @@ func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalCreation{}, errors.New(errNotBucket)
 	}
 
+	if bucket.Spec.ForProvider.DefaultStorageClass != "" && bucket.Spec.ForProvider.PlacementTarget == "" {
+		return managed.ExternalCreation{}, errors.New(errStorageClassNoTarget)
+	}
+
 	bucket.Status.SetConditions(xpv1.Creating())
 	// Where a bucket has a ProviderConfigReference Name, we can infer that this bucket is to be
 	// created only on this S3 Backend. An empty config reference name will be automatically set
@@ -255,8 +340,16 @@ func (c *external) create(ctx context.Context, bucket *v1alpha1.Bucket) (managed
 		return managed.ExternalCreation{}, err
 	}
 
-	c.log.Info("Creating bucket on single s3 backend", "bucket name", bucket.Name, "backend name", bucket.GetProviderConfigReference().Name)
-	_, err = s3Backend.CreateBucket(ctx, s3internal.BucketToCreateBucketInput(bucket))
+	backendName := bucket.GetProviderConfigReference().Name
+	if c.backendStore.GetBackendMode(backendName) != apisv1alpha1.BackendModeActive {
+		return managed.ExternalCreation{}, errors.New(errBackendInMaintenance)
+	}
+	if err := c.checkCreateTenant(bucket, backendName); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+
+	c.log.Info("Creating bucket on single s3 backend", "bucket name", bucketName(bucket), "backend name", backendName)
+	_, err = s3Backend.CreateBucket(ctx, s3internal.BucketToCreateBucketInput(bucket, c.s3BucketName(bucket, backendName)))
 	if err != nil {
 		return managed.ExternalCreation{}, errors.Wrap(err, errCreateBucket)
 	}
@@ -269,13 +362,25 @@ func (c *external) createAll(ctx context.Context, bucket *v1alpha1.Bucket) (mana
 		return managed.ExternalCreation{}, errors.New(errNoS3BackendsStored)
 	}
 
-	c.log.Info("Creating bucket on all available s3 backends", "bucket name", bucket.Name)
+	// New buckets are only created on backends that are not in maintenance.
+	activeBackends := c.backendStore.GetActiveBackends(apisv1alpha1.BackendModeActive)
+	if len(activeBackends) == 0 {
+		return managed.ExternalCreation{}, errors.New(errNoActiveS3Backends)
+	}
+
+	for backendName := range activeBackends {
+		if err := c.checkCreateTenant(bucket, backendName); err != nil {
+			return managed.ExternalCreation{}, err
+		}
+	}
+
+	c.log.Info("Creating bucket on all available s3 backends", "bucket name", bucketName(bucket))
 
 	g := new(errgroup.Group)
-	for _, client := range c.backendStore.GetAllBackends() {
-		cl := client
+	for backendName, client := range activeBackends {
+		cl, input := client, s3internal.BucketToCreateBucketInput(bucket, c.s3BucketName(bucket, backendName))
 		g.Go(func() error {
-			_, err := cl.CreateBucket(ctx, s3internal.BucketToCreateBucketInput(bucket))
+			_, err := cl.CreateBucket(ctx, input)
 
 			return err
 		})
@@ -292,6 +397,11 @@ func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 	if !ok {
 		return managed.ExternalUpdate{}, errors.New(errNotBucket)
 	}
+
+	if err := c.updateBackends(ctx, bucket); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+
 	bucket.Status.SetConditions(xpv1.Available())
 
 	return managed.ExternalUpdate{
@@ -318,18 +428,18 @@ func (c *external) Delete(ctx context.Context, mg resource.Managed) error {
 			return err
 		}
 
-		c.log.Info("Deleting bucket on single s3 backend", "bucket name", bucket.Name, "backend name", backendName)
+		c.log.Info("Deleting bucket on single s3 backend", "bucket name", bucketName(bucket), "backend name", backendName)
 
-		return c.delete(ctx, bucket.Name, s3Backend)
+		return c.delete(ctx, c.s3BucketName(bucket, backendName), s3Backend, c.s3Options(bucket, backendName)...)
 	}
 
 	// No ProviderConfigReference Name specified for bucket, we can infer that his bucket is to
 	// be deleted from all S3 Backends.
-	return c.deleteAll(ctx, bucket.Name)
+	return c.deleteAll(ctx, bucket)
 }
 
-func (c *external) delete(ctx context.Context, bucketName string, s3Backend *s3.Client) error {
-	_, err := s3Backend.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucketName)})
+func (c *external) delete(ctx context.Context, bucketName string, s3Backend *s3.Client, optFns ...func(*s3.Options)) error {
+	_, err := s3Backend.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucketName)}, optFns...)
 	if err != nil {
 		var noSuchBucketErr *s3types.NotFound
 		if errors.As(err, &noSuchBucketErr) {
@@ -340,33 +450,54 @@ func (c *external) delete(ctx context.Context, bucketName string, s3Backend *s3.
 	return err
 }
 
-func (c *external) deleteAll(ctx context.Context, bucketName string) error {
+func (c *external) deleteAll(ctx context.Context, bucket *v1alpha1.Bucket) error {
 	if !c.backendStore.BackendsAreStored() {
 		return errors.New(errNoS3BackendsStored)
 	}
 
-	c.log.Info("Deleting bucket on all available s3 backends", "bucket name", bucketName)
+	c.log.Info("Deleting bucket on all available s3 backends", "bucket name", bucketName(bucket))
 
+	// The bucket may exist on any stored backend, including unreachable
+	// ones skipped by the unhealthy policy. Those fail the deletion so that
+	// it is retried, rather than leaving the bucket behind on them.
+	unreachable := c.unreachableBackends()
 	g := new(errgroup.Group)
-	for _, client := range c.backendStore.GetAllBackends() {
-		cl := client
+	for backendName, client := range c.backendStore.GetActiveBackends() {
+		cl, name, opts := client, c.s3BucketName(bucket, backendName), c.s3Options(bucket, backendName)
 		g.Go(func() error {
-			return c.delete(ctx, bucketName, cl)
+			return c.delete(ctx, name, cl, opts...)
 		})
 	}
 	if err := g.Wait(); err != nil {
-		return errors.Wrap(err, errCreateBucket)
+		return errors.Wrap(err, errDeleteBucket)
+	}
+	if len(unreachable) > 0 {
+		return errors.Errorf(errBackendsUnreachable, unreachable)
 	}
 
 	return nil
 }
 
-func (c *external) bucketExists(ctx context.Context, s3BackendName, bucketName string) (bool, error) {
+// unreachableBackends returns the sorted names of the stored backends that
+// are left out of operations by the unhealthy policy.
+func (c *external) unreachableBackends() []string {
+	names := []string{}
+	for backendName := range c.backendStore.GetAllBackends() {
+		if !c.backendStore.IsBackendActive(backendName) {
+			names = append(names, backendName)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (c *external) bucketExists(ctx context.Context, s3BackendName string, bucket *v1alpha1.Bucket) (bool, error) {
 	s3Backend, err := c.getStoredBackend(s3BackendName)
 	if err != nil {
 		return false, err
 	}
-	_, err = s3Backend.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucketName)})
+	_, err = s3Backend.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(c.s3BucketName(bucket, s3BackendName))}, c.s3Options(bucket, s3BackendName)...)
 	if err != nil {
 		var notFoundErr *s3types.NotFound
 		if errors.As(err, &notFoundErr) {
@@ -383,9 +514,13 @@ func (c *external) bucketExists(ctx context.Context, s3BackendName, bucketName s
 
 func (c *external) getStoredBackend(s3BackendName string) (*s3.Client, error) {
 	s3Backend := c.backendStore.GetBackend(s3BackendName)
-	if s3Backend != nil {
-		return s3Backend, nil
+	if s3Backend == nil {
+		return nil, errors.New(errBackendNotStored)
+	}
+
+	if !c.backendStore.IsBackendActive(s3BackendName) {
+		return nil, errors.New(errBackendUnreachable)
 	}
 
-	return nil, errors.New(errBackendNotStored)
+	return s3Backend, nil
 }
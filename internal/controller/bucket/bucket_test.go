@@ -18,16 +18,25 @@ package bucket
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
 	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
 	"github.com/crossplane/provider-ceph/internal/backendstore"
 )
 
@@ -41,12 +50,72 @@ import (
 
 var (
 	unexpectedItem resource.Managed
+
+	olderBucket = v1alpha1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "older",
+			UID:               "older-uid",
+			CreationTimestamp: metav1.Unix(1, 0),
+			Annotations:       map[string]string{meta.AnnotationKeyExternalName: "shared"},
+		},
+	}
 )
 
+// newerBucket returns a Bucket on s3-backend-1 sharing the external name of
+// olderBucket.
+func newerBucket(tenant string) *v1alpha1.Bucket {
+	return &v1alpha1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "newer",
+			UID:               "newer-uid",
+			CreationTimestamp: metav1.Unix(2, 0),
+			Annotations:       map[string]string{meta.AnnotationKeyExternalName: "shared"},
+		},
+		Spec: v1alpha1.BucketSpec{
+			ResourceSpec: v1.ResourceSpec{ProviderConfigReference: &v1.Reference{Name: "s3-backend-1"}},
+			ForProvider:  v1alpha1.BucketParameters{Tenant: tenant},
+		},
+	}
+}
+
+// storeWithBackend returns a BackendStore with the unreachable backend
+// s3-backend-1, which is skipped by operations.
+func storeWithBackend() *backendstore.BackendStore {
+	s := backendstore.NewBackendStore(backendstore.WithUnhealthyPolicy(backendstore.UnhealthyPolicySkip))
+	s.AddOrUpdateBackend("s3-backend-1", s3.New(s3.Options{}))
+
+	// A single failed health check makes the backend unreachable. The
+	// check fails right away, as the context is already done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = backendstore.NewHealthChecker(s, logging.NewNopLogger(), backendstore.WithUnreachableThreshold(1)).Start(ctx)
+
+	return s
+}
+
+// storeWithBackendInMode returns storeWithBackend with s3-backend-1 in the
+// supplied mode.
+func storeWithBackendInMode(mode apisv1alpha1.BackendMode) *backendstore.BackendStore {
+	s := storeWithBackend()
+	s.SetBackendMode("s3-backend-1", mode)
+
+	return s
+}
+
+// deleted returns the Bucket marked for deletion.
+func deleted(b *v1alpha1.Bucket) *v1alpha1.Bucket {
+	now := metav1.Now()
+	b.SetDeletionTimestamp(&now)
+	meta.AddFinalizer(b, "finalizer")
+
+	return b
+}
+
 func TestObserve(t *testing.T) {
 	t.Parallel()
 
 	type fields struct {
+		kube         client.Client
 		backendStore *backendstore.BackendStore
 	}
 
@@ -67,6 +136,7 @@ func TestObserve(t *testing.T) {
 	}{
 		"Invalid managed resource": {
 			fields: fields{
+				kube:         &test.MockClient{MockList: test.NewMockListFn(nil)},
 				backendStore: backendstore.NewBackendStore(),
 			},
 			args: args{
@@ -78,6 +148,7 @@ func TestObserve(t *testing.T) {
 		},
 		"S3 backend reference does not exist": {
 			fields: fields{
+				kube:         &test.MockClient{MockList: test.NewMockListFn(nil)},
 				backendStore: backendstore.NewBackendStore(),
 			},
 			args: args{
@@ -97,6 +168,7 @@ func TestObserve(t *testing.T) {
 		},
 		"S3 backend not referenced and none exist": {
 			fields: fields{
+				kube:         &test.MockClient{MockList: test.NewMockListFn(nil)},
 				backendStore: backendstore.NewBackendStore(),
 			},
 			args: args{
@@ -106,13 +178,71 @@ func TestObserve(t *testing.T) {
 				err: errors.New(errNoS3BackendsStored),
 			},
 		},
+		"Bucket name used by an older Bucket": {
+			reason: "A Bucket should not manage a bucket already managed by an older Bucket in the same tenant.",
+			fields: fields{
+				kube: &test.MockClient{MockList: test.NewMockListFn(nil, func(obj client.ObjectList) error {
+					obj.(*v1alpha1.BucketList).Items = []v1alpha1.Bucket{olderBucket}
+					return nil
+				})},
+				backendStore: storeWithBackend(),
+			},
+			args: args{
+				mg: newerBucket(""),
+			},
+			want: want{
+				err: errors.Errorf(errNameConflict, olderBucket.Name),
+			},
+		},
+		"Bucket name used by an older Bucket in another tenant": {
+			reason: "Buckets with the same name in different tenants should not conflict.",
+			fields: fields{
+				kube: &test.MockClient{MockList: test.NewMockListFn(nil, func(obj client.ObjectList) error {
+					obj.(*v1alpha1.BucketList).Items = []v1alpha1.Bucket{olderBucket}
+					return nil
+				})},
+				backendStore: storeWithBackend(),
+			},
+			args: args{
+				mg: newerBucket("tenant-b"),
+			},
+			want: want{
+				err: errors.New(errBackendUnreachable),
+			},
+		},
+		"Referenced backend in maintenance fails": {
+			reason: "Errors from a backend in maintenance should leave the bucket alone.",
+			fields: fields{
+				kube:         &test.MockClient{MockList: test.NewMockListFn(nil)},
+				backendStore: storeWithBackendInMode(apisv1alpha1.BackendModeMaintenance),
+			},
+			args: args{
+				mg: newerBucket(""),
+			},
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true},
+			},
+		},
+		"Deleted Bucket on referenced backend in maintenance that fails": {
+			reason: "A deleted bucket should only be reported as gone once the backend confirmed it.",
+			fields: fields{
+				kube:         &test.MockClient{MockList: test.NewMockListFn(nil)},
+				backendStore: storeWithBackendInMode(apisv1alpha1.BackendModeMaintenance),
+			},
+			args: args{
+				mg: deleted(newerBucket("")),
+			},
+			want: want{
+				err: errors.New(errBackendUnreachable),
+			},
+		},
 	}
 	for name, tc := range cases {
 		tc := tc
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			e := external{backendStore: tc.fields.backendStore}
+			e := external{kube: tc.fields.kube, backendStore: tc.fields.backendStore, log: logging.NewNopLogger()}
 			got, err := e.Observe(context.Background(), tc.args.mg)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
@@ -124,6 +254,87 @@ func TestObserve(t *testing.T) {
 	}
 }
 
+// failingBackend returns an S3 client of a backend failing every request.
+func failingBackend(t *testing.T) *s3.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	return s3.New(s3.Options{
+		Region:           "us-east-1",
+		Credentials:      aws.AnonymousCredentials{},
+		EndpointResolver: s3.EndpointResolverFromURL(srv.URL),
+		UsePathStyle:     true,
+		Retryer:          aws.NopRetryer{},
+	})
+}
+
+func TestObserveBackendModes(t *testing.T) {
+	t.Parallel()
+
+	type want struct {
+		o   managed.ExternalObservation
+		err bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		mode   apisv1alpha1.BackendMode
+		mg     *v1alpha1.Bucket
+		want   want
+	}{
+		"Active backend fails": {
+			reason: "Errors from Active backends should fail the observation.",
+			mode:   apisv1alpha1.BackendModeActive,
+			mg:     &v1alpha1.Bucket{},
+			want:   want{err: true},
+		},
+		"Backend in maintenance fails": {
+			reason: "Errors from backends in maintenance should be ignored like in Update.",
+			mode:   apisv1alpha1.BackendModeMaintenance,
+			mg:     &v1alpha1.Bucket{},
+			want:   want{o: managed.ExternalObservation{ResourceExists: false}},
+		},
+		"Deleted Bucket on backend in maintenance that fails": {
+			reason: "A deleted bucket should only be reported as gone once every backend confirmed it.",
+			mode:   apisv1alpha1.BackendModeMaintenance,
+			mg:     deleted(&v1alpha1.Bucket{}),
+			want:   want{err: true},
+		},
+		"Deleted Bucket on backend being drained that fails": {
+			reason: "A deleted bucket should be looked for on backends in every mode.",
+			mode:   apisv1alpha1.BackendModeDrain,
+			mg:     deleted(&v1alpha1.Bucket{}),
+			want:   want{err: true},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := backendstore.NewBackendStore()
+			s.AddOrUpdateBackend("s3-backend-1", failingBackend(t))
+			s.SetBackendMode("s3-backend-1", tc.mode)
+			// Backends being drained are not looked at for new buckets.
+			s.AddOrUpdateBackend("s3-backend-2", failingBackend(t))
+			s.SetBackendMode("s3-backend-2", apisv1alpha1.BackendModeDrain)
+
+			e := external{kube: &test.MockClient{MockList: test.NewMockListFn(nil)}, backendStore: s, log: logging.NewNopLogger()}
+			got, err := e.Observe(context.Background(), tc.mg)
+			if (err != nil) != tc.want.err {
+				t.Errorf("\n%s\ne.Observe(...): want error %t, got: %v\n", tc.reason, tc.want.err, err)
+			}
+			if diff := cmp.Diff(tc.want.o, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
 func TestCreate(t *testing.T) {
 	t.Parallel()
 
@@ -267,13 +478,25 @@ func TestDelete(t *testing.T) {
 				err: errors.New(errNoS3BackendsStored),
 			},
 		},
+		"S3 backend unreachable": {
+			reason: "Deleting a bucket should fail while a backend that may hold it is unreachable.",
+			fields: fields{
+				backendStore: storeWithBackend(),
+			},
+			args: args{
+				mg: &v1alpha1.Bucket{},
+			},
+			want: want{
+				err: errors.Errorf(errBackendsUnreachable, []string{"s3-backend-1"}),
+			},
+		},
 	}
 	for name, tc := range cases {
 		tc := tc
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			e := external{backendStore: tc.fields.backendStore}
+			e := external{backendStore: tc.fields.backendStore, log: logging.NewNopLogger()}
 			err := e.Delete(context.Background(), tc.args.mg)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("\n%s\ne.Delete(...): -want error, +got error:\n%s\n", tc.reason, diff)
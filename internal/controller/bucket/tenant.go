@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bucket
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+)
+
+const (
+	errNameConflict = "bucket name is already used in the same tenant by Bucket %q"
+	errCrossTenant  = "cannot create bucket in tenant %q with the credentials of tenant %q of s3 backend %q"
+	tenantBucketSep = ":"
+
+	// bucketNameField indexes Buckets by the name of their bucket.
+	bucketNameField = "bucketName"
+)
+
+// bucketName returns the name of the bucket, which is the external name of
+// the Bucket.
+func bucketName(bucket *v1alpha1.Bucket) string {
+	if name := meta.GetExternalName(bucket); name != "" {
+		return name
+	}
+
+	return bucket.Name
+}
+
+// bucketNames returns the name of the bucket of the supplied Bucket, the
+// value it is indexed by.
+func bucketNames(obj client.Object) []string {
+	bucket, ok := obj.(*v1alpha1.Bucket)
+	if !ok {
+		return nil
+	}
+
+	return []string{bucketName(bucket)}
+}
+
+// tenant returns the tenant of the bucket on the named backend, defaulting
+// to the tenant of the credentials of the backend.
+func (c *external) tenant(bucket *v1alpha1.Bucket, backendName string) string {
+	if bucket.Spec.ForProvider.Tenant != "" {
+		return bucket.Spec.ForProvider.Tenant
+	}
+
+	return c.backendStore.GetBackendTenant(backendName)
+}
+
+// s3BucketName returns the name the bucket is addressed by in S3 requests to
+// the named backend. Buckets of a tenant other than that of the credentials
+// of the backend are addressed as "tenant:bucket".
+func (c *external) s3BucketName(bucket *v1alpha1.Bucket, backendName string) string {
+	tenant := c.tenant(bucket, backendName)
+	if tenant == c.backendStore.GetBackendTenant(backendName) {
+		return bucketName(bucket)
+	}
+
+	return tenant + tenantBucketSep + bucketName(bucket)
+}
+
+// s3Options returns the options of S3 requests for the bucket on the named
+// backend. Buckets addressed as "tenant:bucket" are always addressed in the
+// path, as the name cannot be part of the host name of virtual-hosted style
+// requests.
+func (c *external) s3Options(bucket *v1alpha1.Bucket, backendName string) []func(*s3.Options) {
+	if !strings.Contains(c.s3BucketName(bucket, backendName), tenantBucketSep) {
+		return nil
+	}
+
+	return []func(*s3.Options){func(o *s3.Options) { o.UsePathStyle = true }}
+}
+
+// checkCreateTenant returns an error if the bucket cannot be created on the
+// named backend because it belongs to a tenant other than that of the
+// credentials of the backend.
+func (c *external) checkCreateTenant(bucket *v1alpha1.Bucket, backendName string) error {
+	if tenant, own := c.tenant(bucket, backendName), c.backendStore.GetBackendTenant(backendName); tenant != own {
+		return errors.Errorf(errCrossTenant, tenant, own, backendName)
+	}
+
+	return nil
+}
+
+// nameConflict returns an older Bucket managing a bucket with the same name
+// in the same tenant on any of the backends of the supplied Bucket, if there
+// is one. Cluster scoped Buckets cannot share a name, but Buckets with
+// different names may share an external name.
+func (c *external) nameConflict(ctx context.Context, bucket *v1alpha1.Bucket) (*v1alpha1.Bucket, error) {
+	l := &v1alpha1.BucketList{}
+	if err := c.kube.List(ctx, l, client.MatchingFields{bucketNameField: bucketName(bucket)}); err != nil {
+		return nil, errors.Wrap(err, errListBuckets)
+	}
+
+	for i := range l.Items {
+		other := &l.Items[i]
+		if other.UID == bucket.UID || bucketName(other) != bucketName(bucket) || !olderThan(other, bucket) {
+			continue
+		}
+		for _, backendName := range c.sharedBackends(bucket, other) {
+			if c.tenant(bucket, backendName) == c.tenant(other, backendName) {
+				return other, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// sharedBackends returns the names of the stored backends both Buckets are
+// placed on.
+func (c *external) sharedBackends(a, b *v1alpha1.Bucket) []string {
+	out := []string{}
+	for backendName := range c.backendStore.GetAllBackends() {
+		if placedOn(a, backendName) && placedOn(b, backendName) {
+			out = append(out, backendName)
+		}
+	}
+
+	return out
+}
+
+// placedOn returns true if the Bucket is placed on the named backend.
+func placedOn(bucket *v1alpha1.Bucket, backendName string) bool {
+	ref := bucket.GetProviderConfigReference()
+
+	return ref == nil || ref.Name == defaultPC || ref.Name == backendName
+}
+
+// olderThan returns true if Bucket a was created before Bucket b. Buckets
+// created within the same second are ordered by name.
+func olderThan(a, b *v1alpha1.Bucket) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+
+	return a.Name < b.Name
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bucket
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+)
+
+func TestS3BucketName(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		backendTenant string
+		bucket        *v1alpha1.Bucket
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   string
+		// wantPathStyle is true if requests must be path style.
+		wantPathStyle bool
+	}{
+		"NoTenants": {
+			reason: "Buckets without a tenant should be addressed by the name of the Bucket.",
+			args: args{
+				bucket: &v1alpha1.Bucket{ObjectMeta: metav1.ObjectMeta{Name: "bucket"}},
+			},
+			want: "bucket",
+		},
+		"ExternalName": {
+			reason: "Buckets should be addressed by their external name.",
+			args: args{
+				bucket: &v1alpha1.Bucket{ObjectMeta: metav1.ObjectMeta{
+					Name:        "team-a-data",
+					Annotations: map[string]string{meta.AnnotationKeyExternalName: "data"},
+				}},
+			},
+			want: "data",
+		},
+		"BackendTenant": {
+			reason: "Buckets in the tenant of the credentials should be addressed without the tenant.",
+			args: args{
+				backendTenant: "team-a",
+				bucket: &v1alpha1.Bucket{
+					ObjectMeta: metav1.ObjectMeta{Name: "bucket"},
+					Spec:       v1alpha1.BucketSpec{ForProvider: v1alpha1.BucketParameters{Tenant: "team-a"}},
+				},
+			},
+			want: "bucket",
+		},
+		"OtherTenant": {
+			reason: "Buckets in another tenant should be addressed as tenant:bucket in the path.",
+			args: args{
+				backendTenant: "team-a",
+				bucket: &v1alpha1.Bucket{
+					ObjectMeta: metav1.ObjectMeta{Name: "bucket"},
+					Spec:       v1alpha1.BucketSpec{ForProvider: v1alpha1.BucketParameters{Tenant: "team-b"}},
+				},
+			},
+			want:          "team-b:bucket",
+			wantPathStyle: true,
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := backendstore.NewBackendStore()
+			s.AddOrUpdateBackend("s3-backend-1", s3.New(s3.Options{}))
+			s.SetBackendTenant("s3-backend-1", tc.args.backendTenant)
+			e := external{backendStore: s}
+
+			got := e.s3BucketName(tc.args.bucket, "s3-backend-1")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ne.s3BucketName(...): -want, +got:\n%s", tc.reason, diff)
+			}
+
+			o := s3.Options{}
+			for _, fn := range e.s3Options(tc.args.bucket, "s3-backend-1") {
+				fn(&o)
+			}
+			if diff := cmp.Diff(tc.wantPathStyle, o.UsePathStyle); diff != "" {
+				t.Errorf("\n%s\ne.s3Options(...): -want path style, +got path style:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
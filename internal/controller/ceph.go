@@ -17,20 +17,39 @@ limitations under the License.
 package controller
 
 import (
+	"time"
+
 	"github.com/crossplane/crossplane-runtime/pkg/controller"
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/controller/accesskey"
 	"github.com/crossplane/provider-ceph/internal/controller/bucket"
 	"github.com/crossplane/provider-ceph/internal/controller/config"
+	"github.com/crossplane/provider-ceph/internal/controller/oidcprovider"
+	"github.com/crossplane/provider-ceph/internal/controller/role"
+	"github.com/crossplane/provider-ceph/internal/controller/rolepolicy"
+	"github.com/crossplane/provider-ceph/internal/controller/subuser"
+	"github.com/crossplane/provider-ceph/internal/controller/user"
+	"github.com/crossplane/provider-ceph/internal/controller/userpolicy"
 )
 
 // Setup creates all Ceph controllers with the supplied logger and adds them to
-// the supplied manager.
-func Setup(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore) error {
+// the supplied manager. The stats of Buckets are refreshed at most once per
+// bucketStatsInterval.
+func Setup(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore, bucketStatsInterval time.Duration) error {
 	for _, setup := range []func(ctrl.Manager, controller.Options, *backendstore.BackendStore) error{
 		config.Setup,
-		bucket.Setup,
+		func(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore) error {
+			return bucket.Setup(mgr, o, s, bucketStatsInterval)
+		},
+		user.Setup,
+		accesskey.Setup,
+		subuser.Setup,
+		role.Setup,
+		rolepolicy.Setup,
+		userpolicy.Setup,
+		oidcprovider.Setup,
 	} {
 		if err := setup(mgr, o, s); err != nil {
 			return err
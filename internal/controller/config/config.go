@@ -18,16 +18,32 @@ package config
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	"github.com/crossplane/crossplane-runtime/pkg/controller"
 	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
@@ -37,12 +53,30 @@ import (
 
 	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
 	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
 	s3internal "github.com/crossplane/provider-ceph/internal/s3"
 )
 
 const (
-	errCreateClient = "cannot create s3 client"
-	errGetSecret    = "cannot get Secret"
+	errCreateClient      = "cannot create s3 client"
+	errCreateAdminClient = "cannot create admin ops client"
+	errCreateIAMClient   = "cannot create IAM client"
+	errGetCreds          = "cannot get credentials"
+	errGetTLSConfig      = "cannot get TLS configuration"
+	errGetProxyURL       = "cannot get proxy URL"
+	errReadS3Cfg         = "cannot read s3cfg"
+	errNoHostBase        = "no hostBase specified"
+	errUpdateStatus      = "cannot update ProviderConfig status"
+	errListPCs           = "cannot list ProviderConfigs"
+	errValidateCreds     = "cannot list buckets with configured credentials"
+	errGetSecret         = "cannot get Secret %s"
+	errGetConfigMap      = "cannot get ConfigMap %s"
+
+	errCodeInvalidAccessKeyID    = "InvalidAccessKeyId"
+	errCodeSignatureDoesNotMatch = "SignatureDoesNotMatch"
+	errCodeAccessDenied          = "AccessDenied"
+
+	connectivityCheckTimeout = 10 * time.Second
 )
 
 // Setup adds a controller that reconciles ProviderConfigs by accounting for
@@ -57,7 +91,10 @@ func Setup(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore)
 
 	// Add an 'internal' controller to the manager for the ProviderConfig.
 	// This will be used, initially, to manage the backendstore of s3 clients.
-	if err := newReconciler(mgr.GetClient(), o, s).setupWithManager(mgr); err != nil {
+	// Referenced Secrets and ConfigMaps are read from the API server, as
+	// caching them would cache every Secret and ConfigMap of the cluster.
+	kube := &uncachedSecrets{Client: mgr.GetClient(), reader: mgr.GetAPIReader()}
+	if err := newReconciler(kube, o, s).setupWithManager(mgr); err != nil {
 		return err
 	}
 
@@ -77,6 +114,9 @@ func newReconciler(k client.Client, o controller.Options, s *backendstore.Backen
 	return &Reconciler{
 		kube:         k,
 		backendStore: s,
+		roleCreds:    newRoleCredentials(),
+		built:        map[string]builtClients{},
+		pollInterval: o.PollInterval,
 		log:          o.Logger.WithValues("internal-controller", providerconfig.ControllerName(apisv1alpha1.ProviderConfigGroupKind)),
 	}
 }
@@ -84,7 +124,22 @@ func newReconciler(k client.Client, o controller.Options, s *backendstore.Backen
 type Reconciler struct {
 	kube         client.Client
 	backendStore *backendstore.BackendStore
+	roleCreds    *roleCredentials
+	secrets      *secretWatches
+	pollInterval time.Duration
 	log          logging.Logger
+
+	mu    sync.Mutex
+	built map[string]builtClients
+}
+
+// builtClients records what the stored clients of a backend were built from.
+type builtClients struct {
+	// version is the configVersion of the ProviderConfig the clients were
+	// built from.
+	version string
+	// http is the HTTP client shared by the clients.
+	http *http.Client
 }
 
 func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -94,8 +149,10 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		if kerrors.IsNotFound(err) {
 			r.log.Info("Deleting s3 backend from backend store", "name", req.Name)
 			r.backendStore.DeleteBackend(req.Name)
+			r.roleCreds.delete(req.Name)
+			r.forgetClients(req.Name)
 
-			return ctrl.Result{}, nil
+			return ctrl.Result{}, r.syncSecretWatches(ctx)
 		}
 
 		return ctrl.Result{}, err
@@ -104,37 +161,376 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	// update its backend in the backend store.
 	r.log.Info("Adding s3 backend to backend store", "name", req.Name)
 
-	return ctrl.Result{}, r.addOrUpdateBackend(ctx, providerConfig)
+	if err := r.syncSecretWatches(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	version, err := r.configVersion(ctx, providerConfig)
+	if err != nil {
+		return ctrl.Result{}, r.setReadyCondition(ctx, providerConfig, apisv1alpha1.BackendUnavailable(apisv1alpha1.ReasonInvalidConfig, err), err)
+	}
+
+	// Validate connectivity and credentials now and then periodically, so
+	// that a misconfigured backend is reported on the ProviderConfig rather
+	// than by the resources using it. The stored clients are validated again
+	// unless the configuration they were built from changed.
+	if s3client := r.backendStore.GetBackend(req.Name); s3client != nil && r.builtVersion(req.Name) == version {
+		cond := r.validate(ctx, req.Name, s3client)
+
+		return ctrl.Result{RequeueAfter: r.pollInterval}, r.setReadyCondition(ctx, providerConfig, cond, nil)
+	}
+
+	clients, err := r.newBackendClients(ctx, providerConfig)
+	if err != nil {
+		return ctrl.Result{}, r.setReadyCondition(ctx, providerConfig, apisv1alpha1.BackendUnavailable(apisv1alpha1.ReasonInvalidConfig, err), err)
+	}
+	clients.version = version
+
+	cond := r.validate(ctx, req.Name, clients.s3)
+	r.addOrUpdateBackend(providerConfig, clients, cond.Status == corev1.ConditionTrue)
+
+	return ctrl.Result{RequeueAfter: r.pollInterval}, r.setReadyCondition(ctx, providerConfig, cond, nil)
 }
 
-func (r *Reconciler) addOrUpdateBackend(ctx context.Context, pc *apisv1alpha1.ProviderConfig) error {
-	secret, err := r.getProviderConfigSecret(ctx, pc.Spec.Credentials.SecretRef.Namespace, pc.Spec.Credentials.SecretRef.Name)
+// backendClients are the API clients of a backend.
+type backendClients struct {
+	builtClients
+
+	s3    *s3.Client
+	admin *rgwadmin.Client
+	iam   *iam.Client
+}
+
+// newBackendClients returns the S3, Admin Ops and IAM API clients of the
+// backend described by the ProviderConfig.
+func (r *Reconciler) newBackendClients(ctx context.Context, pc *apisv1alpha1.ProviderConfig) (*backendClients, error) {
+	spec, s3cfg, err := r.resolveSpec(ctx, pc)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	s3client, err := s3internal.NewClient(ctx, secret.Data, &pc.Spec)
+	creds, err := r.credentials(ctx, pc, s3cfg)
 	if err != nil {
-		return errors.Wrap(err, errCreateClient)
+		return nil, errors.Wrap(err, errGetCreds)
 	}
 
-	r.backendStore.AddOrUpdateBackend(pc.Name, s3client)
+	tlsConfig, err := s3internal.GetTLSConfig(ctx, r.kube, pc.Spec.TLS)
+	if err != nil {
+		return nil, errors.Wrap(err, errGetTLSConfig)
+	}
 
-	return nil
+	proxyURL, err := s3internal.GetProxyURL(ctx, r.kube, pc.Spec.HTTP)
+	if err != nil {
+		return nil, errors.Wrap(err, errGetProxyURL)
+	}
+
+	// The clients of the backend share one HTTP client, whose idle
+	// connections are closed once they are replaced.
+	httpClient := s3internal.NewHTTPClient(spec, s3internal.WithTLSConfig(tlsConfig), s3internal.WithProxyURL(proxyURL))
+	opts := []s3internal.ClientOption{s3internal.WithHTTPClient(httpClient)}
+
+	// The role is assumed with the credentials of the ProviderConfig, its
+	// credentials are then used for all clients of the backend.
+	creds, err = r.roleCreds.get(ctx, pc.Name, creds, spec, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s3client, err := s3internal.NewClient(ctx, creds, spec, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, errCreateClient)
+	}
+
+	adminCreds, err := s3internal.GetAdminCredentialsProvider(ctx, r.kube, spec.Admin, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	// The Admin Ops API is only used where it is configured, resources
+	// managed through it report the missing client otherwise.
+	var adminClient *rgwadmin.Client
+	if spec.Admin != nil {
+		adminClient, err = s3internal.NewAdminClient(ctx, adminCreds, spec, opts...)
+		if err != nil {
+			return nil, errors.Wrap(err, errCreateAdminClient)
+		}
+	}
+
+	// The IAM API is served with the admin credentials, as managing roles
+	// and policies requires caps the regular credentials usually lack.
+	iamClient, err := s3internal.NewIAMClient(ctx, adminCreds, spec, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, errCreateIAMClient)
+	}
+
+	return &backendClients{builtClients: builtClients{http: httpClient}, s3: s3client, admin: adminClient, iam: iamClient}, nil
 }
 
-func (r *Reconciler) getProviderConfigSecret(ctx context.Context, secretNamespace, secretName string) (*corev1.Secret, error) {
-	secret := &corev1.Secret{}
-	ns := types.NamespacedName{Namespace: secretNamespace, Name: secretName}
-	if err := r.kube.Get(ctx, ns, secret); err != nil {
-		return nil, errors.Wrap(err, "cannot get provider secret")
+// configVersion returns a version of the configuration the clients of the
+// backend described by the ProviderConfig are built from, made up of the
+// generation of the ProviderConfig and the resource versions of the Secrets
+// and ConfigMap it references. Clients are only rebuilt when it changes.
+func (r *Reconciler) configVersion(ctx context.Context, pc *apisv1alpha1.ProviderConfig) (string, error) {
+	versions := []string{strconv.FormatInt(pc.GetGeneration(), 10)}
+	for _, ref := range referencedSecrets(pc) {
+		secret := &corev1.Secret{}
+		if err := r.kube.Get(ctx, ref, secret); err != nil {
+			return "", errors.Wrapf(err, errGetSecret, ref)
+		}
+		versions = append(versions, secret.GetResourceVersion())
+	}
+	if tlsConfig := pc.Spec.TLS; tlsConfig != nil && tlsConfig.CABundleConfigMapRef != nil {
+		ref := types.NamespacedName{Namespace: tlsConfig.CABundleConfigMapRef.Namespace, Name: tlsConfig.CABundleConfigMapRef.Name}
+		cm := &corev1.ConfigMap{}
+		if err := r.kube.Get(ctx, ref, cm); err != nil {
+			return "", errors.Wrapf(err, errGetConfigMap, ref)
+		}
+		versions = append(versions, cm.GetResourceVersion())
 	}
 
-	return secret, nil
+	return strings.Join(versions, "/"), nil
+}
+
+// validate validates the supplied S3 client of a backend and returns the
+// resulting Ready condition of its ProviderConfig.
+func (r *Reconciler) validate(ctx context.Context, name string, s3client *s3.Client) xpv1.Condition {
+	if err := validateBackend(ctx, s3client); err != nil {
+		r.log.Info("S3 backend is unavailable", "name", name, "error", err.Error())
+
+		return apisv1alpha1.BackendUnavailable(unavailableReason(err), err)
+	}
+
+	return xpv1.Available()
+}
+
+// resolveSpec returns the effective spec of the ProviderConfig, taking host
+// settings from the s3cfg file when the S3Cfg credentials source is used. The
+// s3cfg file is returned as well, so that its credentials are used without
+// reading the Secret again.
+func (r *Reconciler) resolveSpec(ctx context.Context, pc *apisv1alpha1.ProviderConfig) (*apisv1alpha1.ProviderConfigSpec, *s3internal.S3Cfg, error) {
+	if pc.Spec.Credentials.Source != apisv1alpha1.CredentialsSourceS3Cfg {
+		if pc.Spec.HostBase == "" {
+			return nil, nil, errors.New(errNoHostBase)
+		}
+
+		return &pc.Spec, nil, nil
+	}
+
+	cfg, err := s3internal.ReadS3Cfg(ctx, r.kube, pc.Spec.Credentials.SecretRef)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errReadS3Cfg)
+	}
+
+	spec, err := s3internal.ApplyS3Cfg(&pc.Spec, cfg)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errReadS3Cfg)
+	}
+
+	return spec, cfg, nil
+}
+
+// credentials returns the credentials of the ProviderConfig, taking them from
+// the s3cfg file resolveSpec read, if any.
+func (r *Reconciler) credentials(ctx context.Context, pc *apisv1alpha1.ProviderConfig, s3cfg *s3internal.S3Cfg) (aws.CredentialsProvider, error) {
+	if s3cfg != nil {
+		return s3internal.S3CfgCredentialsProvider(s3cfg)
+	}
+
+	return s3internal.GetCredentialsProvider(ctx, r.kube, pc.Spec.Credentials)
+}
+
+// addOrUpdateBackend stores the clients of a new backend. The clients of an
+// already stored backend are only swapped once the new client has been
+// validated, so that e.g. a credential rotation that has not propagated to
+// the backend yet does not break a working backend.
+func (r *Reconciler) addOrUpdateBackend(pc *apisv1alpha1.ProviderConfig, clients *backendClients, validated bool) {
+	if validated || r.backendStore.GetBackend(pc.Name) == nil {
+		r.backendStore.AddOrUpdateBackend(pc.Name, clients.s3)
+		r.backendStore.SetAdminClient(pc.Name, clients.admin)
+		r.backendStore.SetIAMClient(pc.Name, clients.iam)
+		r.setBuilt(pc.Name, clients.builtClients)
+	} else {
+		// The stored clients are kept with the version they were built
+		// from, so that new clients are built and validated again on the
+		// next poll.
+		r.log.Info("Keeping previous s3 client of backend until the new one can be validated", "name", pc.Name)
+		clients.http.CloseIdleConnections()
+	}
+	r.backendStore.SetBackendMode(pc.Name, pc.Spec.Mode)
+	r.backendStore.SetBackendTenant(pc.Name, pc.Spec.Tenant)
+}
+
+// builtVersion returns the configVersion the stored clients of a backend
+// were built from.
+func (r *Reconciler) builtVersion(name string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.built[name].version
+}
+
+// setBuilt records what the stored clients of a backend were built from,
+// closing the idle connections of the clients they replace.
+func (r *Reconciler) setBuilt(name string, b builtClients) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if old, ok := r.built[name]; ok && old.http != b.http {
+		old.http.CloseIdleConnections()
+	}
+	r.built[name] = b
+}
+
+// forgetClients closes the idle connections of the clients of a deleted
+// backend.
+func (r *Reconciler) forgetClients(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if old, ok := r.built[name]; ok {
+		old.http.CloseIdleConnections()
+	}
+	delete(r.built, name)
+}
+
+// setReadyCondition sets the supplied Ready condition on the ProviderConfig,
+// updating its status only if the condition changed. The supplied
+// reconcileErr is returned unless the status update itself fails.
+func (r *Reconciler) setReadyCondition(ctx context.Context, pc *apisv1alpha1.ProviderConfig, cond xpv1.Condition, reconcileErr error) error {
+	if pc.GetCondition(xpv1.TypeReady).Equal(cond) {
+		return reconcileErr
+	}
+
+	pc.SetConditions(cond)
+	if err := r.kube.Status().Update(ctx, pc); err != nil {
+		return errors.Wrap(err, errUpdateStatus)
+	}
+
+	return reconcileErr
+}
+
+// validateBackend verifies that the backend can be reached and accepts the
+// credentials of the supplied client.
+func validateBackend(ctx context.Context, s3client *s3.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, connectivityCheckTimeout)
+	defer cancel()
+
+	_, err := s3client.ListBuckets(ctx, &s3.ListBucketsInput{})
+
+	return errors.Wrap(err, errValidateCreds)
+}
+
+// unavailableReason returns the condition reason that best describes why
+// the backend could not be validated.
+func unavailableReason(err error) xpv1.ConditionReason {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case errCodeInvalidAccessKeyID:
+			return apisv1alpha1.ReasonInvalidAccessKeyID
+		case errCodeSignatureDoesNotMatch:
+			return apisv1alpha1.ReasonSignatureDoesNotMatch
+		case errCodeAccessDenied:
+			return apisv1alpha1.ReasonAccessDenied
+		}
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return apisv1alpha1.ReasonDNSFailure
+	}
+
+	var (
+		unknownAuthErr x509.UnknownAuthorityError
+		hostnameErr    x509.HostnameError
+		certInvalidErr x509.CertificateInvalidError
+		recordErr      tls.RecordHeaderError
+	)
+	if errors.As(err, &unknownAuthErr) || errors.As(err, &hostnameErr) || errors.As(err, &certInvalidErr) || errors.As(err, &recordErr) {
+		return apisv1alpha1.ReasonTLSError
+	}
+
+	return apisv1alpha1.ReasonConnectionFailure
 }
 
 func (r *Reconciler) setupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&apisv1alpha1.ProviderConfig{}).
-		Complete(r)
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &apisv1alpha1.ProviderConfig{}, secretRefsField, secretRefs); err != nil {
+		return errors.Wrap(err, errIndexSecretRefs)
+	}
+
+	// Status updates made by this reconciler must not trigger it again.
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		For(&apisv1alpha1.ProviderConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Build(r)
+	if err != nil {
+		return err
+	}
+
+	// Secrets are watched so that rotated credentials are picked up without
+	// the ProviderConfig being touched. Only the namespaces referenced by
+	// ProviderConfigs are watched, as they are reconciled.
+	r.secrets = newSecretWatches(r.watchNamespacedSecrets(mgr, c))
+
+	return nil
+}
+
+// syncSecretWatches watches the Secrets of the namespaces referenced by any
+// ProviderConfig, and stops watching those of other namespaces.
+func (r *Reconciler) syncSecretWatches(ctx context.Context) error {
+	pcList := &apisv1alpha1.ProviderConfigList{}
+	if err := r.kube.List(ctx, pcList); err != nil {
+		return errors.Wrap(err, errListPCs)
+	}
+
+	namespaces := []string{}
+	for i := range pcList.Items {
+		namespaces = append(namespaces, secretNamespaces(&pcList.Items[i])...)
+	}
+
+	return r.secrets.sync(namespaces...)
+}
+
+// providerConfigsForSecret maps a Secret to reconcile requests for the
+// ProviderConfigs that reference it.
+func (r *Reconciler) providerConfigsForSecret(obj client.Object) []reconcile.Request {
+	secret := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	pcList := &apisv1alpha1.ProviderConfigList{}
+	if err := r.kube.List(context.Background(), pcList, client.MatchingFields{secretRefsField: secret.String()}); err != nil {
+		r.log.Info(errors.Wrap(err, errListPCs).Error())
+
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for i := range pcList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: pcList.Items[i].Name}})
+	}
+
+	return requests
+}
+
+// referencedSecrets returns the Secrets referenced by a ProviderConfig.
+func referencedSecrets(pc *apisv1alpha1.ProviderConfig) []types.NamespacedName {
+	refs := []types.NamespacedName{}
+	if ref := pc.Spec.Credentials.SecretRef; ref != nil && (pc.Spec.Credentials.Source == xpv1.CredentialsSourceSecret || pc.Spec.Credentials.Source == apisv1alpha1.CredentialsSourceS3Cfg) {
+		refs = append(refs, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name})
+	}
+	if tlsConfig := pc.Spec.TLS; tlsConfig != nil {
+		if ref := tlsConfig.CABundleSecretRef; ref != nil {
+			refs = append(refs, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name})
+		}
+		if ref := tlsConfig.ClientCertSecretRef; ref != nil {
+			refs = append(refs, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name})
+		}
+	}
+	if httpConfig := pc.Spec.HTTP; httpConfig != nil && httpConfig.ProxyCredentialsSecretRef != nil {
+		ref := httpConfig.ProxyCredentialsSecretRef
+		refs = append(refs, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name})
+	}
+	if admin := pc.Spec.Admin; admin != nil && admin.CredentialsSecretRef != nil {
+		ref := admin.CredentialsSecretRef
+		refs = append(refs, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name})
+	}
+
+	return refs
 }
@@ -0,0 +1,266 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+)
+
+func TestUnavailableReason(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		reason string
+		err    error
+		want   xpv1.ConditionReason
+	}{
+		"InvalidAccessKeyId": {
+			reason: "An unknown access key should be reported as such.",
+			err:    errors.Wrap(&smithy.GenericAPIError{Code: errCodeInvalidAccessKeyID}, errValidateCreds),
+			want:   apisv1alpha1.ReasonInvalidAccessKeyID,
+		},
+		"SignatureDoesNotMatch": {
+			reason: "A wrong secret key should be reported as a signature mismatch.",
+			err:    errors.Wrap(&smithy.GenericAPIError{Code: errCodeSignatureDoesNotMatch}, errValidateCreds),
+			want:   apisv1alpha1.ReasonSignatureDoesNotMatch,
+		},
+		"DNSFailure": {
+			reason: "A host that cannot be resolved should be reported as a DNS failure.",
+			err:    errors.Wrap(&net.DNSError{Name: "rgw.example.com", IsNotFound: true}, errValidateCreds),
+			want:   apisv1alpha1.ReasonDNSFailure,
+		},
+		"TLSError": {
+			reason: "A certificate signed by an unknown authority should be reported as a TLS error.",
+			err:    errors.Wrap(x509.UnknownAuthorityError{}, errValidateCreds),
+			want:   apisv1alpha1.ReasonTLSError,
+		},
+		"OtherError": {
+			reason: "Any other error should be reported as a connection failure.",
+			err:    errors.New("connection refused"),
+			want:   apisv1alpha1.ReasonConnectionFailure,
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := unavailableReason(tc.err)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nunavailableReason(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConfigVersion(t *testing.T) {
+	t.Parallel()
+
+	pc := &apisv1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: "backend-a", Generation: 2}}
+	pc.Spec.Credentials.Source = xpv1.CredentialsSourceSecret
+	pc.Spec.Credentials.SecretRef = &xpv1.SecretKeySelector{
+		SecretReference: xpv1.SecretReference{Namespace: "crossplane-system", Name: "creds"},
+	}
+	pc.Spec.TLS = &apisv1alpha1.TLSConfig{
+		CABundleConfigMapRef: &apisv1alpha1.ConfigMapKeySelector{Namespace: "ceph", Name: "ca"},
+	}
+
+	cases := map[string]struct {
+		reason  string
+		kube    client.Client
+		want    string
+		wantErr bool
+	}{
+		"ReferencedObjects": {
+			reason: "The version should be made up of the generation and the resource versions of the referenced Secret and ConfigMap.",
+			kube: &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					switch obj.(type) {
+					case *corev1.Secret:
+						obj.SetResourceVersion("10")
+					case *corev1.ConfigMap:
+						obj.SetResourceVersion("20")
+					}
+
+					return nil
+				},
+			},
+			want: "2/10/20",
+		},
+		"GetError": {
+			reason:  "An error should be returned if a referenced object cannot be read.",
+			kube:    &test.MockClient{MockGet: test.NewMockGetFn(errors.New("boom"))},
+			wantErr: true,
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Reconciler{kube: tc.kube, log: logging.NewNopLogger()}
+			got, err := r.configVersion(context.Background(), pc)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("\n%s\nr.configVersion(...): want error %t, got %v\n", tc.reason, tc.wantErr, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nr.configVersion(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestProviderConfigsForSecret(t *testing.T) {
+	t.Parallel()
+
+	pcWithSecret := func(name, secretNamespace, secretName string) apisv1alpha1.ProviderConfig {
+		pc := apisv1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		pc.Spec.Credentials.Source = xpv1.CredentialsSourceSecret
+		pc.Spec.Credentials.SecretRef = &xpv1.SecretKeySelector{
+			SecretReference: xpv1.SecretReference{Namespace: secretNamespace, Name: secretName},
+		}
+
+		return pc
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   client.Client
+		secret client.Object
+		want   []reconcile.Request
+	}{
+		"ListError": {
+			reason: "No requests should be returned if ProviderConfigs cannot be listed.",
+			kube: &test.MockClient{
+				MockList: test.NewMockListFn(errors.New("boom")),
+			},
+			secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "crossplane-system", Name: "creds"}},
+		},
+		"ReferencingProviderConfigs": {
+			reason: "Only ProviderConfigs indexed by the Secret should be enqueued.",
+			kube: &test.MockClient{
+				MockList: func(_ context.Context, obj client.ObjectList, opts ...client.ListOption) error {
+					lo := &client.ListOptions{}
+					lo.ApplyOptions(opts)
+					if lo.FieldSelector.String() != secretRefsField+"=crossplane-system/creds" {
+						return errors.Errorf("unexpected field selector %q", lo.FieldSelector)
+					}
+					obj.(*apisv1alpha1.ProviderConfigList).Items = []apisv1alpha1.ProviderConfig{
+						pcWithSecret("backend-a", "crossplane-system", "creds"),
+					}
+
+					return nil
+				},
+			},
+			secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "crossplane-system", Name: "creds"}},
+			want: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Name: "backend-a"}},
+			},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Reconciler{kube: tc.kube, log: logging.NewNopLogger()}
+			got := r.providerConfigsForSecret(tc.secret)
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\n%s\nr.providerConfigsForSecret(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSecretRefs(t *testing.T) {
+	t.Parallel()
+
+	pc := &apisv1alpha1.ProviderConfig{}
+	pc.Spec.Credentials.Source = xpv1.CredentialsSourceSecret
+	pc.Spec.Credentials.SecretRef = &xpv1.SecretKeySelector{
+		SecretReference: xpv1.SecretReference{Namespace: "crossplane-system", Name: "creds"},
+	}
+	pc.Spec.TLS = &apisv1alpha1.TLSConfig{
+		CABundleSecretRef: &xpv1.SecretKeySelector{
+			SecretReference: xpv1.SecretReference{Namespace: "ceph", Name: "ca"},
+		},
+	}
+
+	want := []string{"crossplane-system/creds", "ceph/ca"}
+	if diff := cmp.Diff(want, secretRefs(pc)); diff != "" {
+		t.Errorf("secretRefs(...): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"crossplane-system", "ceph"}, secretNamespaces(pc)); diff != "" {
+		t.Errorf("secretNamespaces(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestSecretWatchesSync(t *testing.T) {
+	t.Parallel()
+
+	watched := []string{}
+	stopped := []string{}
+	fail := true
+	w := newSecretWatches(func(namespace string) (func(), error) {
+		if namespace == "failing" && fail {
+			fail = false
+
+			return nil, errors.New("boom")
+		}
+		watched = append(watched, namespace)
+
+		return func() { stopped = append(stopped, namespace) }, nil
+	})
+
+	if err := w.sync("crossplane-system", "crossplane-system", "ceph"); err != nil {
+		t.Fatalf("w.sync(...): %v", err)
+	}
+	if err := w.sync("ceph", "failing"); err == nil {
+		t.Errorf("w.sync(...): want error watching namespace, got nil")
+	}
+	// A namespace that could not be watched is retried, and one that is no
+	// longer referenced is not watched anymore.
+	if err := w.sync("failing", "crossplane-system"); err != nil {
+		t.Fatalf("w.sync(...): %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"crossplane-system", "ceph", "failing", "crossplane-system"}, watched); diff != "" {
+		t.Errorf("w.sync(...): -want watched namespaces, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"crossplane-system", "ceph"}, stopped); diff != "" {
+		t.Errorf("w.sync(...): -want stopped namespaces, +got:\n%s", diff)
+	}
+}
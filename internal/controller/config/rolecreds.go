@@ -0,0 +1,120 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/pkg/errors"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	s3internal "github.com/crossplane/provider-ceph/internal/s3"
+)
+
+const (
+	errRoleCredsKey = "cannot compute role credentials cache key"
+	errNewRoleCreds = "cannot create role credentials provider"
+)
+
+// roleCredentials caches the credentials of the role assumed for each backend
+// across reconciles, so that the role is only assumed again when its
+// credentials are about to expire or the ProviderConfig changes, rather than
+// on every poll.
+type roleCredentials struct {
+	mu      sync.Mutex
+	entries map[string]roleCredentialsEntry
+}
+
+type roleCredentialsEntry struct {
+	key   string
+	creds aws.CredentialsProvider
+}
+
+func newRoleCredentials() *roleCredentials {
+	return &roleCredentials{entries: map[string]roleCredentialsEntry{}}
+}
+
+// get returns the role credentials of the named backend if the spec
+// configures a role to assume, or the supplied credentials otherwise. The
+// cached credentials are replaced if the spec or the supplied credentials
+// changed since they were created.
+func (r *roleCredentials) get(ctx context.Context, backendName string, creds aws.CredentialsProvider, spec *apisv1alpha1.ProviderConfigSpec, opts ...s3internal.ClientOption) (aws.CredentialsProvider, error) {
+	if spec.AssumeRole == nil {
+		r.delete(backendName)
+
+		return creds, nil
+	}
+
+	key, err := roleCredentialsKey(ctx, creds, spec)
+	if err != nil {
+		return nil, errors.Wrap(err, errRoleCredsKey)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, ok := r.entries[backendName]; ok && e.key == key {
+		return e.creds, nil
+	}
+
+	roleCreds, err := s3internal.NewAssumeRoleCredentialsProvider(ctx, creds, spec, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewRoleCreds)
+	}
+	r.entries[backendName] = roleCredentialsEntry{key: key, creds: roleCreds}
+
+	return roleCreds, nil
+}
+
+func (r *roleCredentials) delete(backendName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.entries, backendName)
+}
+
+// roleCredentialsKey returns a digest of the spec and of the credentials the
+// role is assumed with. The credentials are static, so retrieving them does
+// not reach the backend.
+func roleCredentialsKey(ctx context.Context, creds aws.CredentialsProvider, spec *apisv1alpha1.ProviderConfigSpec) (string, error) {
+	k := struct {
+		Spec      *apisv1alpha1.ProviderConfigSpec
+		AccessKey string
+		SecretKey string
+	}{Spec: spec}
+
+	if creds != nil && spec.AssumeRole.WebIdentityTokenFile == "" {
+		v, err := creds.Retrieve(ctx)
+		if err != nil {
+			return "", err
+		}
+		k.AccessKey, k.SecretKey = v.AccessKeyID, v.SecretAccessKey
+	}
+
+	b, err := json.Marshal(k)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+)
+
+func TestRoleCredentials(t *testing.T) {
+	t.Parallel()
+
+	base := credentials.NewStaticCredentialsProvider("access", "secret", "")
+	withRole := func(arn string) *apisv1alpha1.ProviderConfigSpec {
+		return &apisv1alpha1.ProviderConfigSpec{
+			HostBase:   "rgw.example.com",
+			AssumeRole: &apisv1alpha1.AssumeRoleConfig{RoleARN: arn},
+		}
+	}
+	get := func(t *testing.T, r *roleCredentials, creds aws.CredentialsProvider, spec *apisv1alpha1.ProviderConfigSpec) aws.CredentialsProvider {
+		t.Helper()
+
+		got, err := r.get(context.Background(), "backend", creds, spec)
+		if err != nil {
+			t.Fatalf("r.get(...): unexpected error: %v", err)
+		}
+
+		return got
+	}
+
+	t.Run("NoRole", func(t *testing.T) {
+		t.Parallel()
+
+		got := get(t, newRoleCredentials(), base, &apisv1alpha1.ProviderConfigSpec{HostBase: "rgw.example.com"})
+		if got != aws.CredentialsProvider(base) {
+			t.Errorf("r.get(...): the credentials of the ProviderConfig should be used if no role is configured")
+		}
+	})
+
+	t.Run("Cached", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRoleCredentials()
+		first := get(t, r, base, withRole("arn:aws:iam:::role/provider"))
+		if first == aws.CredentialsProvider(base) {
+			t.Fatalf("r.get(...): role credentials should be used if a role is configured")
+		}
+		if got := get(t, r, base, withRole("arn:aws:iam:::role/provider")); got != first {
+			t.Errorf("r.get(...): role credentials should be reused while the ProviderConfig is unchanged")
+		}
+	})
+
+	t.Run("RoleChanged", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRoleCredentials()
+		first := get(t, r, base, withRole("arn:aws:iam:::role/provider"))
+		if got := get(t, r, base, withRole("arn:aws:iam:::role/other")); got == first {
+			t.Errorf("r.get(...): role credentials should be replaced if the role changed")
+		}
+	})
+
+	t.Run("CredentialsRotated", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRoleCredentials()
+		first := get(t, r, base, withRole("arn:aws:iam:::role/provider"))
+		rotated := credentials.NewStaticCredentialsProvider("access", "rotated", "")
+		if got := get(t, r, rotated, withRole("arn:aws:iam:::role/provider")); got == first {
+			t.Errorf("r.get(...): role credentials should be replaced if the credentials of the ProviderConfig changed")
+		}
+	})
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	kcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+)
+
+const (
+	errWatchSecrets    = "cannot watch Secrets in namespace %q"
+	errIndexSecretRefs = "cannot index ProviderConfigs by referenced Secrets"
+
+	// secretRefsField indexes ProviderConfigs by the "namespace/name" of
+	// the Secrets they reference.
+	secretRefsField = "spec.secretRefs"
+)
+
+// secretWatches watches the Secrets of the namespaces referenced by
+// ProviderConfigs, so that only Secrets of those namespaces are cached rather
+// than every Secret of the cluster. A namespace is watched while any
+// ProviderConfig references it.
+type secretWatches struct {
+	mu    sync.Mutex
+	stops map[string]func()
+	watch func(namespace string) (stop func(), err error)
+}
+
+func newSecretWatches(watch func(namespace string) (func(), error)) *secretWatches {
+	return &secretWatches{stops: map[string]func(){}, watch: watch}
+}
+
+// sync watches the Secrets of exactly the supplied namespaces, starting the
+// watches that are missing and stopping those of other namespaces.
+func (w *secretWatches) sync(namespaces ...string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	referenced := map[string]bool{}
+	for _, ns := range namespaces {
+		referenced[ns] = true
+	}
+	for ns, stop := range w.stops {
+		if !referenced[ns] {
+			stop()
+			delete(w.stops, ns)
+		}
+	}
+
+	for _, ns := range namespaces {
+		if _, ok := w.stops[ns]; ok {
+			continue
+		}
+		stop, err := w.watch(ns)
+		if err != nil {
+			return errors.Wrapf(err, errWatchSecrets, ns)
+		}
+		w.stops[ns] = stop
+	}
+
+	return nil
+}
+
+// watchNamespacedSecrets returns a function that starts an informer for the
+// Secrets of a namespace and enqueues the ProviderConfigs referencing them
+// through the supplied controller. The informer runs until the returned stop
+// function is called or the manager stops.
+func (r *Reconciler) watchNamespacedSecrets(mgr ctrl.Manager, c kcontroller.Controller) func(namespace string) (func(), error) {
+	return func(namespace string) (func(), error) {
+		nsCache, err := cache.New(mgr.GetConfig(), cache.Options{
+			Scheme:    mgr.GetScheme(),
+			Mapper:    mgr.GetRESTMapper(),
+			Namespace: namespace,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		stopped, stop := context.WithCancel(context.Background())
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go func() {
+				select {
+				case <-stopped.Done():
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+
+			return nsCache.Start(ctx)
+		})); err != nil {
+			stop()
+
+			return nil, err
+		}
+
+		if err := c.Watch(source.NewKindWithCache(&corev1.Secret{}, nsCache), handler.EnqueueRequestsFromMapFunc(r.providerConfigsForSecret)); err != nil {
+			stop()
+
+			return nil, err
+		}
+
+		return stop, nil
+	}
+}
+
+// uncachedSecrets is a client that reads Secrets and ConfigMaps from the API
+// server rather than through the cache of the manager, which would cache every
+// Secret and ConfigMap of the cluster. It is only used by the ProviderConfig
+// reconciler. The CA bundle ConfigMap is not watched, changes to it are
+// picked up on the next poll.
+type uncachedSecrets struct {
+	client.Client
+	reader client.Reader
+}
+
+func (c *uncachedSecrets) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	switch obj.(type) {
+	case *corev1.Secret, *corev1.ConfigMap:
+		return c.reader.Get(ctx, key, obj)
+	default:
+		return c.Client.Get(ctx, key, obj)
+	}
+}
+
+// secretRefs returns the "namespace/name" of the Secrets referenced by the
+// supplied ProviderConfig, the values it is indexed by.
+func secretRefs(obj client.Object) []string {
+	pc, ok := obj.(*apisv1alpha1.ProviderConfig)
+	if !ok {
+		return nil
+	}
+
+	refs := []string{}
+	for _, ref := range referencedSecrets(pc) {
+		refs = append(refs, ref.String())
+	}
+
+	return refs
+}
+
+// secretNamespaces returns the namespaces of the Secrets referenced by the
+// supplied ProviderConfig.
+func secretNamespaces(pc *apisv1alpha1.ProviderConfig) []string {
+	namespaces := []string{}
+	for _, ref := range referencedSecrets(pc) {
+		namespaces = append(namespaces, ref.Namespace)
+	}
+
+	return namespaces
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package inlinepolicy reconciles the inline policies of IAM entities, like
+// roles and users, on the backends the managed resource is managed on.
+package inlinepolicy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/controller/adminops"
+	"github.com/crossplane/provider-ceph/internal/iampolicy"
+	s3internal "github.com/crossplane/provider-ceph/internal/s3"
+)
+
+const (
+	errNotKind       = "managed resource is not a %s custom resource"
+	errNoEntityName  = "no %s specified or resolved"
+	errGetPolicy     = "cannot get %s"
+	errPutPolicy     = "cannot put %s"
+	errDeletePolicy  = "cannot delete %s"
+	errComparePolicy = "cannot compare policy documents"
+)
+
+// Parameters of an inline policy.
+type Parameters struct {
+	// EntityName is the name of the entity the policy is attached to.
+	EntityName string
+
+	// PolicyName is the name of the policy.
+	PolicyName string
+
+	// PolicyDocument is the desired policy document.
+	PolicyDocument string
+}
+
+// A Policy describes the managed resources of one kind of inline policy, and
+// the IAM API calls that manage them.
+type Policy[T resource.Managed] struct {
+	// Kind of the managed resource, e.g. RolePolicy.
+	Kind string
+
+	// EntityNameField is the field of the managed resource naming the entity
+	// the policy is attached to, e.g. roleName.
+	EntityNameField string
+
+	// Parameters returns the parameters of the policy of the managed
+	// resource.
+	Parameters func(cr T) Parameters
+
+	// Get returns the document of the policy.
+	Get func(ctx context.Context, cl *iam.Client, p Parameters) (string, error)
+
+	// Put creates or replaces the policy.
+	Put func(ctx context.Context, cl *iam.Client, p Parameters) error
+
+	// Delete deletes the policy.
+	Delete func(ctx context.Context, cl *iam.Client, p Parameters) error
+}
+
+// PolicyName returns the supplied policy name, defaulting to the name of the
+// managed resource.
+func PolicyName(mg resource.Managed, policyName string) string {
+	if policyName != "" {
+		return policyName
+	}
+
+	return mg.GetName()
+}
+
+// An External observes, then either creates, updates, or deletes an inline
+// policy to ensure it reflects the managed resource's desired state. The
+// policy is managed on the backends selected by adminops.IAMClients.
+type External[T resource.Managed] struct {
+	Policy       Policy[T]
+	BackendStore *backendstore.BackendStore
+	Log          logging.Logger
+}
+
+func (c *External[T]) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	cr, p, err := c.parameters(mg)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+
+	clients, err := adminops.IAMClients(c.BackendStore, cr, apisv1alpha1.BackendModeActive, apisv1alpha1.BackendModeMaintenance)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+
+	docs, failed, err := c.getPolicies(ctx, cr, p, clients)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if len(docs) == 0 && len(failed) == len(clients) && adminops.IsSingleBackend(cr) {
+		// Errors from a backend in maintenance are expected, leave the
+		// policy alone until the backend is back.
+		return managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}, nil
+	}
+	if len(docs) == 0 {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+
+	upToDate := true
+	for backendName := range clients {
+		doc, found := docs[backendName]
+		if failed[backendName] {
+			continue
+		}
+		if !found {
+			// Policies missing from a backend in maintenance are put
+			// once the backend is active again.
+			upToDate = upToDate && c.BackendStore.GetBackendMode(backendName) != apisv1alpha1.BackendModeActive
+
+			continue
+		}
+		equal, err := iampolicy.Equal(p.PolicyDocument, *doc)
+		if err != nil {
+			return managed.ExternalObservation{}, errors.Wrap(err, errComparePolicy)
+		}
+		upToDate = upToDate && equal
+	}
+
+	cr.SetConditions(xpv1.Available())
+
+	return managed.ExternalObservation{
+		ResourceExists:   true,
+		ResourceUpToDate: upToDate,
+	}, nil
+}
+
+func (c *External[T]) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	cr, ok := mg.(T)
+	if !ok {
+		return managed.ExternalCreation{}, errors.Errorf(errNotKind, c.Policy.Kind)
+	}
+
+	cr.SetConditions(xpv1.Creating())
+
+	_, p, err := c.parameters(mg)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+
+	// New policies are only put on backends that are not in maintenance.
+	clients, err := adminops.IAMClients(c.BackendStore, cr, apisv1alpha1.BackendModeActive)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+
+	return managed.ExternalCreation{}, errors.Wrapf(c.putPolicy(ctx, p, clients), errPutPolicy, c.Policy.Kind)
+}
+
+func (c *External[T]) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	cr, p, err := c.parameters(mg)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+
+	clients, err := adminops.IAMClients(c.BackendStore, cr, apisv1alpha1.BackendModeActive)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+
+	// Putting a policy replaces it, so it is put on every backend rather
+	// than only where it differs.
+	return managed.ExternalUpdate{}, errors.Wrapf(c.putPolicy(ctx, p, clients), errPutPolicy, c.Policy.Kind)
+}
+
+func (c *External[T]) Delete(ctx context.Context, mg resource.Managed) error {
+	cr, ok := mg.(T)
+	if !ok {
+		return errors.Errorf(errNotKind, c.Policy.Kind)
+	}
+
+	cr.SetConditions(xpv1.Deleting())
+
+	// Policies are deleted from backends in maintenance too.
+	clients, err := adminops.IAMClients(c.BackendStore, cr)
+	if err != nil {
+		return err
+	}
+
+	p := c.Policy.Parameters(cr)
+	g := new(errgroup.Group)
+	for _, cl := range clients {
+		cl := cl
+		g.Go(func() error {
+			err := c.Policy.Delete(ctx, cl, p)
+			if s3internal.IsNoSuchEntity(err) {
+				return nil
+			}
+
+			return err
+		})
+	}
+
+	return errors.Wrapf(g.Wait(), errDeletePolicy, c.Policy.Kind)
+}
+
+// parameters returns the managed resource as a T, and the parameters of its
+// policy. An error is returned if the entity of the policy is unknown.
+func (c *External[T]) parameters(mg resource.Managed) (T, Parameters, error) {
+	cr, ok := mg.(T)
+	if !ok {
+		return cr, Parameters{}, errors.Errorf(errNotKind, c.Policy.Kind)
+	}
+
+	p := c.Policy.Parameters(cr)
+	if p.EntityName == "" {
+		return cr, Parameters{}, errors.Errorf(errNoEntityName, c.Policy.EntityNameField)
+	}
+
+	return cr, p, nil
+}
+
+// getPolicies returns the policy document found on each backend.
+func (c *External[T]) getPolicies(ctx context.Context, cr T, p Parameters, clients map[string]*iam.Client) (map[string]*string, map[string]bool, error) {
+	return adminops.Get(ctx, c.BackendStore, c.Log, cr, clients, fmt.Sprintf(errGetPolicy, c.Policy.Kind), func(ctx context.Context, cl *iam.Client) (*string, error) {
+		doc, err := c.Policy.Get(ctx, cl, p)
+		if s3internal.IsNoSuchEntity(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &doc, nil
+	})
+}
+
+func (c *External[T]) putPolicy(ctx context.Context, p Parameters, clients map[string]*iam.Client) error {
+	g := new(errgroup.Group)
+	for _, cl := range clients {
+		cl := cl
+		g.Go(func() error {
+			return c.Policy.Put(ctx, cl, p)
+		})
+	}
+
+	return g.Wait()
+}
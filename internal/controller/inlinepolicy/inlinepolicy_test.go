@@ -0,0 +1,341 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inlinepolicy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/s3/fake"
+)
+
+const (
+	trust       = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam:::user/alice"]},"Action":["sts:AssumeRole"]}]}`
+	readOnly    = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::photos/*"]}]}`
+	writeAccess = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:PutObject"],"Resource":["arn:aws:s3:::photos/*"]}]}`
+)
+
+// newServers returns a fake server per backend, each with the "reader" role
+// and the supplied policy document of that role, if any, and closes them when
+// the test ends.
+func newServers(t *testing.T, policies map[string]string, names ...string) map[string]*fake.IAMServer {
+	t.Helper()
+
+	ctx := context.Background()
+	servers := fake.NewIAMServers(t, names...)
+	for name, srv := range servers {
+		cl := srv.NewClient()
+		if _, err := cl.CreateRole(ctx, &iam.CreateRoleInput{RoleName: aws.String("reader"), AssumeRolePolicyDocument: aws.String(trust)}); err != nil {
+			t.Fatalf("CreateRole(...): %v", err)
+		}
+		if doc, ok := policies[name]; ok {
+			if _, err := cl.PutRolePolicy(ctx, &iam.PutRolePolicyInput{RoleName: aws.String("reader"), PolicyName: aws.String("read-only"), PolicyDocument: aws.String(doc)}); err != nil {
+				t.Fatalf("PutRolePolicy(...): %v", err)
+			}
+		}
+	}
+
+	return servers
+}
+
+// rolePolicy manages the inline policies of roles, like the RolePolicy
+// controller does.
+var rolePolicy = Policy[*v1alpha1.RolePolicy]{
+	Kind:            v1alpha1.RolePolicyKind,
+	EntityNameField: "roleName",
+	Parameters: func(cr *v1alpha1.RolePolicy) Parameters {
+		return Parameters{
+			EntityName:     cr.Spec.ForProvider.RoleName,
+			PolicyName:     PolicyName(cr, cr.Spec.ForProvider.PolicyName),
+			PolicyDocument: cr.Spec.ForProvider.PolicyDocument,
+		}
+	},
+	Get: func(ctx context.Context, cl *iam.Client, p Parameters) (string, error) {
+		out, err := cl.GetRolePolicy(ctx, &iam.GetRolePolicyInput{RoleName: aws.String(p.EntityName), PolicyName: aws.String(p.PolicyName)})
+		if err != nil {
+			return "", err
+		}
+
+		return aws.ToString(out.PolicyDocument), nil
+	},
+	Put: func(ctx context.Context, cl *iam.Client, p Parameters) error {
+		_, err := cl.PutRolePolicy(ctx, &iam.PutRolePolicyInput{RoleName: aws.String(p.EntityName), PolicyName: aws.String(p.PolicyName), PolicyDocument: aws.String(p.PolicyDocument)})
+
+		return err
+	},
+	Delete: func(ctx context.Context, cl *iam.Client, p Parameters) error {
+		_, err := cl.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{RoleName: aws.String(p.EntityName), PolicyName: aws.String(p.PolicyName)})
+
+		return err
+	},
+}
+
+func newExternal(s *backendstore.BackendStore) *External[*v1alpha1.RolePolicy] {
+	return &External[*v1alpha1.RolePolicy]{Policy: rolePolicy, BackendStore: s, Log: logging.NewNopLogger()}
+}
+
+func newRolePolicy(doc string) *v1alpha1.RolePolicy {
+	return &v1alpha1.RolePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-only"},
+		Spec:       v1alpha1.RolePolicySpec{ForProvider: v1alpha1.RolePolicyParameters{RoleName: "reader", PolicyDocument: doc}},
+	}
+}
+
+func TestObserve(t *testing.T) {
+	t.Parallel()
+
+	type want struct {
+		o   managed.ExternalObservation
+		err error
+	}
+
+	cases := map[string]struct {
+		reason   string
+		policies map[string]string
+		backends fake.Backends
+		mg       resource.Managed
+		want     want
+	}{
+		"NotRolePolicy": {
+			reason: "An error should be returned if the managed resource is not a RolePolicy.",
+			mg:     &v1alpha1.Role{},
+			want:   want{err: errors.Errorf(errNotKind, v1alpha1.RolePolicyKind)},
+		},
+		"NoRoleName": {
+			reason: "An error should be returned if no role name is specified or resolved.",
+			mg: &v1alpha1.RolePolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "read-only"},
+				Spec:       v1alpha1.RolePolicySpec{ForProvider: v1alpha1.RolePolicyParameters{PolicyDocument: "{}"}},
+			},
+			want: want{err: errors.Errorf(errNoEntityName, "roleName")},
+		},
+		"NotFound": {
+			reason: "The policy should not exist if it is found on no backend.",
+			mg:     newRolePolicy(readOnly),
+			want:   want{o: managed.ExternalObservation{ResourceExists: false}},
+		},
+		"UpToDate": {
+			reason:   "A policy with the desired document on every backend should be up to date.",
+			policies: map[string]string{"s3-backend-1": readOnly, "s3-backend-2": readOnly},
+			mg:       newRolePolicy(readOnly),
+			want:     want{o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}},
+		},
+		"DocumentDiffers": {
+			reason:   "A policy with another document on a backend should not be up to date.",
+			policies: map[string]string{"s3-backend-1": readOnly, "s3-backend-2": writeAccess},
+			mg:       newRolePolicy(readOnly),
+			want:     want{o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: false}},
+		},
+		"MissingOnBackend": {
+			reason:   "A policy missing from an active backend should not be up to date, so that it is put there.",
+			policies: map[string]string{"s3-backend-1": readOnly},
+			mg:       newRolePolicy(readOnly),
+			want:     want{o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: false}},
+		},
+		"MissingOnMaintenanceBackend": {
+			reason:   "A policy missing from a backend in maintenance should be up to date until the backend is active again.",
+			policies: map[string]string{"s3-backend-1": readOnly},
+			backends: fake.Backends{
+				Modes: map[string]apisv1alpha1.BackendMode{"s3-backend-2": apisv1alpha1.BackendModeMaintenance},
+			},
+			mg:   newRolePolicy(readOnly),
+			want: want{o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}},
+		},
+		"MaintenanceBackendDown": {
+			reason:   "A backend in maintenance that cannot be queried should be skipped.",
+			policies: map[string]string{"s3-backend-1": readOnly, "s3-backend-2": readOnly},
+			backends: fake.Backends{
+				Modes: map[string]apisv1alpha1.BackendMode{"s3-backend-2": apisv1alpha1.BackendModeMaintenance},
+				Down:  map[string]bool{"s3-backend-2": true},
+			},
+			mg:   newRolePolicy(readOnly),
+			want: want{o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			servers := newServers(t, tc.policies, "s3-backend-1", "s3-backend-2")
+			e := newExternal(fake.NewBackendStore(servers, tc.backends))
+			got, err := e.Observe(context.Background(), tc.mg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.o, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestObserveActiveBackendDown(t *testing.T) {
+	t.Parallel()
+
+	servers := newServers(t, map[string]string{"s3-backend-1": readOnly, "s3-backend-2": readOnly}, "s3-backend-1", "s3-backend-2")
+	s := fake.NewBackendStore(servers, fake.Backends{Down: map[string]bool{"s3-backend-2": true}})
+	e := newExternal(s)
+
+	if _, err := e.Observe(context.Background(), newRolePolicy(readOnly)); err == nil {
+		t.Errorf("e.Observe(...): expected an error querying an active backend that is down")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	t.Parallel()
+
+	servers := newServers(t, nil, "s3-backend-1", "s3-backend-2")
+	s := fake.NewBackendStore(servers, fake.Backends{
+		Modes: map[string]apisv1alpha1.BackendMode{"s3-backend-2": apisv1alpha1.BackendModeMaintenance},
+	})
+	e := newExternal(s)
+
+	if _, err := e.Create(context.Background(), newRolePolicy(readOnly)); err != nil {
+		t.Fatalf("e.Create(...): %v", err)
+	}
+
+	doc, ok := servers["s3-backend-1"].GetRolePolicy("reader", "read-only")
+	if !ok {
+		t.Fatalf("s3-backend-1: policy was not put")
+	}
+	if diff := cmp.Diff(readOnly, doc); diff != "" {
+		t.Errorf("s3-backend-1: -want policy document, +got policy document:\n%s", diff)
+	}
+	if _, ok := servers["s3-backend-2"].GetRolePolicy("reader", "read-only"); ok {
+		t.Errorf("s3-backend-2: policy should not be put on a backend in maintenance")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	servers := newServers(t, map[string]string{"s3-backend-1": writeAccess}, "s3-backend-1", "s3-backend-2")
+	e := newExternal(fake.NewBackendStore(servers, fake.Backends{}))
+
+	if _, err := e.Update(context.Background(), newRolePolicy(readOnly)); err != nil {
+		t.Fatalf("e.Update(...): %v", err)
+	}
+
+	// The policy is replaced where it differs and put where it is missing.
+	for name, srv := range servers {
+		doc, ok := srv.GetRolePolicy("reader", "read-only")
+		if !ok {
+			t.Errorf("%s: policy was not put", name)
+
+			continue
+		}
+		if diff := cmp.Diff(readOnly, doc); diff != "" {
+			t.Errorf("%s: -want policy document, +got policy document:\n%s", name, diff)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		reason   string
+		policies map[string]string
+		backends fake.Backends
+		roleGone map[string]bool
+	}{
+		"Deleted": {
+			reason:   "The policy should be deleted from every backend, including those in maintenance.",
+			policies: map[string]string{"s3-backend-1": readOnly, "s3-backend-2": readOnly},
+			backends: fake.Backends{
+				Modes: map[string]apisv1alpha1.BackendMode{"s3-backend-2": apisv1alpha1.BackendModeMaintenance},
+			},
+		},
+		"AlreadyGone": {
+			reason:   "Deleting a policy that is already gone from a backend should not return an error.",
+			policies: map[string]string{"s3-backend-1": readOnly},
+		},
+		"RoleGone": {
+			reason:   "Deleting a policy of a role that is already gone from a backend should not return an error.",
+			policies: map[string]string{"s3-backend-1": readOnly},
+			roleGone: map[string]bool{"s3-backend-2": true},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			servers := newServers(t, tc.policies, "s3-backend-1", "s3-backend-2")
+			for name := range tc.roleGone {
+				if _, err := servers[name].NewClient().DeleteRole(context.Background(), &iam.DeleteRoleInput{RoleName: aws.String("reader")}); err != nil {
+					t.Fatalf("DeleteRole(...): %v", err)
+				}
+			}
+			e := newExternal(fake.NewBackendStore(servers, tc.backends))
+			if err := e.Delete(context.Background(), newRolePolicy(readOnly)); err != nil {
+				t.Errorf("\n%s\ne.Delete(...): %v", tc.reason, err)
+			}
+			for name, srv := range servers {
+				if _, ok := srv.GetRolePolicy("reader", "read-only"); ok {
+					t.Errorf("\n%s\n%s: policy was not deleted", tc.reason, name)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyName(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		reason     string
+		policyName string
+		want       string
+	}{
+		"Default": {
+			reason: "The policy should be named after the managed resource by default.",
+			want:   "read-only",
+		},
+		"PolicyName": {
+			reason:     "The policy name should take precedence over the name of the managed resource.",
+			policyName: "ReadOnly",
+			want:       "ReadOnly",
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := PolicyName(newRolePolicy(readOnly), tc.policyName)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nPolicyName(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidcprovider
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/controller/adminops"
+	"github.com/crossplane/provider-ceph/internal/controller/features"
+	s3internal "github.com/crossplane/provider-ceph/internal/s3"
+)
+
+const (
+	errNotOIDCProvider    = "managed resource is not an OIDCProvider custom resource"
+	errTrackPCUsage       = "cannot track ProviderConfig usage"
+	errGetOIDCProvider    = "cannot get OIDCProvider"
+	errCreateOIDCProvider = "cannot create OIDCProvider"
+	errUpdateOIDCProvider = "cannot update OIDCProvider"
+	errDeleteOIDCProvider = "cannot delete OIDCProvider"
+
+	arnResourcePrefix = ":oidc-provider/"
+)
+
+// Setup adds a controller that reconciles OIDCProvider managed resources.
+func Setup(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore) error {
+	name := managed.ControllerName(v1alpha1.OIDCProviderGroupKind)
+
+	cps := []managed.ConnectionPublisher{managed.NewAPISecretPublisher(mgr.GetClient(), mgr.GetScheme())}
+	if o.Features.Enabled(features.EnableAlphaExternalSecretStores) {
+		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
+	}
+
+	r := managed.NewReconciler(mgr,
+		resource.ManagedKind(v1alpha1.OIDCProviderGroupVersionKind),
+		managed.WithExternalConnecter(&connector{
+			kube:         mgr.GetClient(),
+			usage:        resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+			backendStore: s,
+			log:          o.Logger.WithValues("controller", name),
+		}),
+		managed.WithLogger(o.Logger.WithValues("controller", name)),
+		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		managed.WithConnectionPublishers(cps...))
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.OIDCProvider{}).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// A connector is expected to produce an ExternalClient when its Connect method
+// is called.
+type connector struct {
+	kube         client.Client
+	usage        resource.Tracker
+	backendStore *backendstore.BackendStore
+	log          logging.Logger
+}
+
+// Connect tracks that the managed resource is using a ProviderConfig and
+// returns an ExternalClient using the IAM clients of the backend store.
+func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
+	if err := c.usage.Track(ctx, mg); err != nil {
+		return nil, errors.Wrap(err, errTrackPCUsage)
+	}
+
+	return &external{backendStore: c.backendStore.GetBackendStore(), log: c.log}, nil
+}
+
+// An ExternalClient observes, then either creates, updates, or deletes an
+// external resource to ensure it reflects the managed resource's desired state.
+// An OIDCProvider is managed on the backends selected by adminops.IAMClients.
+type external struct {
+	backendStore *backendstore.BackendStore
+	log          logging.Logger
+}
+
+// provider is an identity provider found on a backend.
+type provider struct {
+	ARN            string
+	ClientIDList   []string
+	ThumbprintList []string
+}
+
+func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	cr, ok := mg.(*v1alpha1.OIDCProvider)
+	if !ok {
+		return managed.ExternalObservation{}, errors.New(errNotOIDCProvider)
+	}
+
+	clients, err := adminops.IAMClients(c.backendStore, cr, apisv1alpha1.BackendModeActive, apisv1alpha1.BackendModeMaintenance)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+
+	providers, failed, err := c.getProviders(ctx, cr, clients)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if len(providers) == 0 && len(failed) == len(clients) && adminops.IsSingleBackend(cr) {
+		// Errors from a backend in maintenance are expected, leave the
+		// provider alone until the backend is back.
+		return managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}, nil
+	}
+	if len(providers) == 0 {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+
+	upToDate := true
+	for backendName := range clients {
+		p, found := providers[backendName]
+		if failed[backendName] {
+			continue
+		}
+		if !found {
+			// Providers missing from a backend in maintenance are
+			// created once the backend is active again.
+			upToDate = upToDate && c.backendStore.GetBackendMode(backendName) != apisv1alpha1.BackendModeActive
+
+			continue
+		}
+		upToDate = upToDate && isUpToDate(cr.Spec.ForProvider, p)
+	}
+
+	cr.Status.AtProvider.ARN = providers[adminops.FirstName(providers)].ARN
+	cr.Status.SetConditions(xpv1.Available())
+
+	return managed.ExternalObservation{
+		ResourceExists:   true,
+		ResourceUpToDate: upToDate,
+	}, nil
+}
+
+func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	cr, ok := mg.(*v1alpha1.OIDCProvider)
+	if !ok {
+		return managed.ExternalCreation{}, errors.New(errNotOIDCProvider)
+	}
+
+	cr.Status.SetConditions(xpv1.Creating())
+
+	// New providers are only created on backends that are not in
+	// maintenance.
+	clients, err := adminops.IAMClients(c.backendStore, cr, apisv1alpha1.BackendModeActive)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+
+	c.log.Info("Creating OIDC provider", "url", cr.Spec.ForProvider.URL, "backends", len(clients))
+
+	g := new(errgroup.Group)
+	for _, cl := range clients {
+		cl := cl
+		g.Go(func() error {
+			_, err := cl.CreateOpenIDConnectProvider(ctx, createProviderInput(cr))
+
+			return err
+		})
+	}
+
+	return managed.ExternalCreation{}, errors.Wrap(g.Wait(), errCreateOIDCProvider)
+}
+
+func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	cr, ok := mg.(*v1alpha1.OIDCProvider)
+	if !ok {
+		return managed.ExternalUpdate{}, errors.New(errNotOIDCProvider)
+	}
+
+	clients, err := adminops.IAMClients(c.backendStore, cr, apisv1alpha1.BackendModeActive)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+
+	providers, failed, err := c.getProviders(ctx, cr, clients)
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errUpdateOIDCProvider)
+	}
+
+	g := new(errgroup.Group)
+	for backendName, cl := range clients {
+		if failed[backendName] {
+			continue
+		}
+		backendName, cl, p := backendName, cl, providers[backendName]
+		g.Go(func() error {
+			switch {
+			case p == nil:
+				_, err := cl.CreateOpenIDConnectProvider(ctx, createProviderInput(cr))
+
+				return err
+			case isUpToDate(cr.Spec.ForProvider, p):
+				return nil
+			}
+
+			err := updateProvider(ctx, cl, cr.Spec.ForProvider, p)
+			if !s3internal.IsNotImplemented(err) {
+				return err
+			}
+
+			// RGW releases before Squid cannot update a provider,
+			// so it is recreated. Another provider with the same
+			// URL cannot be created first, so tokens of the
+			// provider are rejected until it is recreated. Its ARN
+			// only depends on the URL and is kept.
+			c.log.Info("Recreating OIDC provider, the backend cannot update it", "url", cr.Spec.ForProvider.URL, "backend name", backendName)
+			if _, err := cl.DeleteOpenIDConnectProvider(ctx, &iam.DeleteOpenIDConnectProviderInput{OpenIDConnectProviderArn: aws.String(p.ARN)}); err != nil {
+				return err
+			}
+			_, err = cl.CreateOpenIDConnectProvider(ctx, createProviderInput(cr))
+
+			return err
+		})
+	}
+
+	return managed.ExternalUpdate{}, errors.Wrap(g.Wait(), errUpdateOIDCProvider)
+}
+
+func (c *external) Delete(ctx context.Context, mg resource.Managed) error {
+	cr, ok := mg.(*v1alpha1.OIDCProvider)
+	if !ok {
+		return errors.New(errNotOIDCProvider)
+	}
+
+	cr.Status.SetConditions(xpv1.Deleting())
+
+	// Providers are deleted from backends in maintenance too.
+	clients, err := adminops.IAMClients(c.backendStore, cr)
+	if err != nil {
+		return err
+	}
+
+	c.log.Info("Deleting OIDC provider", "url", cr.Spec.ForProvider.URL, "backends", len(clients))
+
+	g := new(errgroup.Group)
+	for _, cl := range clients {
+		cl := cl
+		g.Go(func() error {
+			arn, err := findProvider(ctx, cl, cr.Spec.ForProvider.URL)
+			if err != nil || arn == "" {
+				return err
+			}
+			_, err = cl.DeleteOpenIDConnectProvider(ctx, &iam.DeleteOpenIDConnectProviderInput{OpenIDConnectProviderArn: aws.String(arn)})
+			if s3internal.IsNoSuchEntity(err) {
+				return nil
+			}
+
+			return err
+		})
+	}
+
+	return errors.Wrap(g.Wait(), errDeleteOIDCProvider)
+}
+
+func (c *external) getProviders(ctx context.Context, cr *v1alpha1.OIDCProvider, clients map[string]*iam.Client) (map[string]*provider, map[string]bool, error) {
+	return adminops.Get(ctx, c.backendStore, c.log, cr, clients, errGetOIDCProvider, func(ctx context.Context, cl *iam.Client) (*provider, error) {
+		arn, err := findProvider(ctx, cl, cr.Spec.ForProvider.URL)
+		if err != nil || arn == "" {
+			return nil, err
+		}
+		out, err := cl.GetOpenIDConnectProvider(ctx, &iam.GetOpenIDConnectProviderInput{OpenIDConnectProviderArn: aws.String(arn)})
+		if s3internal.IsNoSuchEntity(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &provider{ARN: arn, ClientIDList: out.ClientIDList, ThumbprintList: out.ThumbprintList}, nil
+	})
+}
+
+// findProvider returns the ARN of the provider with the supplied URL, or an
+// empty string if there is none. The ARN of a provider ends with its URL
+// without the scheme, which is how it is found without storing the ARN of
+// each backend.
+func findProvider(ctx context.Context, cl *iam.Client, url string) (string, error) {
+	out, err := cl.ListOpenIDConnectProviders(ctx, &iam.ListOpenIDConnectProvidersInput{})
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range out.OpenIDConnectProviderList {
+		if matchesURL(aws.ToString(p.Arn), url) {
+			return aws.ToString(p.Arn), nil
+		}
+	}
+
+	return "", nil
+}
+
+// updateProvider updates the thumbprints and client IDs of the supplied
+// provider in place, where they differ from the desired ones.
+func updateProvider(ctx context.Context, cl *iam.Client, p v1alpha1.OIDCProviderParameters, found *provider) error {
+	arn := aws.String(found.ARN)
+
+	if !equalSets(p.ThumbprintList, found.ThumbprintList, true) {
+		if _, err := cl.UpdateOpenIDConnectProviderThumbprint(ctx, &iam.UpdateOpenIDConnectProviderThumbprintInput{
+			OpenIDConnectProviderArn: arn,
+			ThumbprintList:           p.ThumbprintList,
+		}); err != nil {
+			return err
+		}
+	}
+
+	add, remove := diffSets(p.ClientIDList, found.ClientIDList)
+	for _, id := range add {
+		if _, err := cl.AddClientIDToOpenIDConnectProvider(ctx, &iam.AddClientIDToOpenIDConnectProviderInput{
+			OpenIDConnectProviderArn: arn,
+			ClientID:                 aws.String(id),
+		}); err != nil {
+			return err
+		}
+	}
+	for _, id := range remove {
+		if _, err := cl.RemoveClientIDFromOpenIDConnectProvider(ctx, &iam.RemoveClientIDFromOpenIDConnectProviderInput{
+			OpenIDConnectProviderArn: arn,
+			ClientID:                 aws.String(id),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// diffSets returns the elements of desired missing from found, and the
+// elements of found missing from desired.
+func diffSets(desired, found []string) (add, remove []string) {
+	inDesired, inFound := map[string]bool{}, map[string]bool{}
+	for _, e := range desired {
+		inDesired[e] = true
+	}
+	for _, e := range found {
+		inFound[e] = true
+		if !inDesired[e] {
+			remove = append(remove, e)
+		}
+	}
+	for _, e := range desired {
+		if !inFound[e] {
+			add = append(add, e)
+			inFound[e] = true
+		}
+	}
+
+	return add, remove
+}
+
+// matchesURL returns true if the supplied ARN is the ARN of the provider with
+// the supplied URL. RGW strips the scheme of the URL from the ARN.
+func matchesURL(arn, url string) bool {
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+len("://"):]
+	}
+
+	return strings.HasSuffix(arn, arnResourcePrefix+strings.TrimSuffix(url, "/"))
+}
+
+// isUpToDate returns true if the provider has the desired client IDs and
+// thumbprints, in any order. Thumbprints are compared case insensitively.
+func isUpToDate(p v1alpha1.OIDCProviderParameters, found *provider) bool {
+	return equalSets(p.ClientIDList, found.ClientIDList, false) && equalSets(p.ThumbprintList, found.ThumbprintList, true)
+}
+
+func equalSets(a, b []string, foldCase bool) bool {
+	normalize := func(s []string) []string {
+		set := map[string]bool{}
+		for _, e := range s {
+			if foldCase {
+				e = strings.ToLower(e)
+			}
+			set[e] = true
+		}
+		out := make([]string, 0, len(set))
+		for e := range set {
+			out = append(out, e)
+		}
+		sort.Strings(out)
+
+		return out
+	}
+
+	na, nb := normalize(a), normalize(b)
+	if len(na) != len(nb) {
+		return false
+	}
+	for i := range na {
+		if na[i] != nb[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func createProviderInput(cr *v1alpha1.OIDCProvider) *iam.CreateOpenIDConnectProviderInput {
+	return &iam.CreateOpenIDConnectProviderInput{
+		Url:            aws.String(cr.Spec.ForProvider.URL),
+		ClientIDList:   cr.Spec.ForProvider.ClientIDList,
+		ThumbprintList: cr.Spec.ForProvider.ThumbprintList,
+	}
+}
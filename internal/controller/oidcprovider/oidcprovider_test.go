@@ -0,0 +1,186 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidcprovider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/s3/fake"
+)
+
+const thumbprint = "9E99A48A9960B14926BB7F3B02E22DA2B0AB7280"
+
+func TestIsUpToDate(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		reason string
+		p      v1alpha1.OIDCProviderParameters
+		found  *provider
+		want   bool
+	}{
+		"UpToDate": {
+			reason: "A provider with the desired client IDs and thumbprints in another order should be up to date.",
+			p:      v1alpha1.OIDCProviderParameters{ClientIDList: []string{"sts", "rgw"}, ThumbprintList: []string{thumbprint}},
+			found:  &provider{ClientIDList: []string{"rgw", "sts"}, ThumbprintList: []string{thumbprint}},
+			want:   true,
+		},
+		"ThumbprintCase": {
+			reason: "Thumbprints should be compared case insensitively.",
+			p:      v1alpha1.OIDCProviderParameters{ThumbprintList: []string{thumbprint}},
+			found:  &provider{ThumbprintList: []string{"9e99a48a9960b14926bb7f3b02e22da2b0ab7280"}},
+			want:   true,
+		},
+		"ClientIDMissing": {
+			reason: "A provider missing a client ID should not be up to date.",
+			p:      v1alpha1.OIDCProviderParameters{ClientIDList: []string{"sts", "rgw"}, ThumbprintList: []string{thumbprint}},
+			found:  &provider{ClientIDList: []string{"sts"}, ThumbprintList: []string{thumbprint}},
+			want:   false,
+		},
+		"ThumbprintDiffers": {
+			reason: "A provider with another thumbprint should not be up to date.",
+			p:      v1alpha1.OIDCProviderParameters{ThumbprintList: []string{thumbprint}},
+			found:  &provider{ThumbprintList: []string{"0000000000000000000000000000000000000000"}},
+			want:   false,
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := isUpToDate(tc.p, tc.found)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nisUpToDate(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMatchesURL(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		reason string
+		arn    string
+		url    string
+		want   bool
+	}{
+		"Matches": {
+			reason: "The ARN of a provider should end with its URL without the scheme.",
+			arn:    "arn:aws:iam:::oidc-provider/oidc.example.com/cluster",
+			url:    "https://oidc.example.com/cluster",
+			want:   true,
+		},
+		"OtherScheme": {
+			reason: "Any scheme of the URL should be stripped, not only https.",
+			arn:    "arn:aws:iam:::oidc-provider/oidc.example.com/cluster",
+			url:    "http://oidc.example.com/cluster",
+			want:   true,
+		},
+		"TrailingSlash": {
+			reason: "A trailing slash of the URL should be ignored.",
+			arn:    "arn:aws:iam::tenant:oidc-provider/oidc.example.com",
+			url:    "https://oidc.example.com/",
+			want:   true,
+		},
+		"OtherProvider": {
+			reason: "The ARN of a provider with another URL should not match.",
+			arn:    "arn:aws:iam:::oidc-provider/other.example.com/oidc.example.com",
+			url:    "https://oidc.example.com",
+			want:   false,
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := matchesURL(tc.arn, tc.url)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nmatchesURL(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	const newThumbprint = "0000000000000000000000000000000000000000"
+
+	cases := map[string]struct {
+		reason string
+		reject []string
+	}{
+		"InPlace": {
+			reason: "The provider should be updated in place, without deleting it.",
+			reject: []string{"CreateOpenIDConnectProvider", "DeleteOpenIDConnectProvider"},
+		},
+		"Recreate": {
+			reason: "The provider should be recreated on backends that cannot update it.",
+			reject: []string{"UpdateOpenIDConnectProviderThumbprint", "AddClientIDToOpenIDConnectProvider", "RemoveClientIDFromOpenIDConnectProvider"},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			servers := fake.NewIAMServers(t, "s3-backend-1")
+			srv := servers["s3-backend-1"]
+			if _, err := srv.NewClient().CreateOpenIDConnectProvider(context.Background(), &iam.CreateOpenIDConnectProviderInput{
+				Url:            aws.String("https://oidc.example.com"),
+				ClientIDList:   []string{"sts", "old"},
+				ThumbprintList: []string{thumbprint},
+			}); err != nil {
+				t.Fatalf("CreateOpenIDConnectProvider(...): %v", err)
+			}
+			srv.Reject(tc.reject...)
+
+			s := fake.NewBackendStore(servers, fake.Backends{})
+			e := external{backendStore: s, log: logging.NewNopLogger()}
+
+			cr := &v1alpha1.OIDCProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+				Spec: v1alpha1.OIDCProviderSpec{ForProvider: v1alpha1.OIDCProviderParameters{
+					URL:            "https://oidc.example.com",
+					ClientIDList:   []string{"sts", "rgw"},
+					ThumbprintList: []string{newThumbprint},
+				}},
+			}
+			if _, err := e.Update(context.Background(), cr); err != nil {
+				t.Fatalf("\n%s\ne.Update(...): %v", tc.reason, err)
+			}
+
+			got, _ := srv.GetOIDCProvider("https://oidc.example.com")
+			want := fake.OIDCProvider{ClientIDs: []string{"sts", "rgw"}, Thumbprints: []string{newThumbprint}}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("\n%s\ne.Update(...): -want provider, +got provider:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
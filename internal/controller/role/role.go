@@ -0,0 +1,340 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package role
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/controller/adminops"
+	"github.com/crossplane/provider-ceph/internal/controller/features"
+	"github.com/crossplane/provider-ceph/internal/iampolicy"
+	s3internal "github.com/crossplane/provider-ceph/internal/s3"
+)
+
+const (
+	errNotRole       = "managed resource is not a Role custom resource"
+	errTrackPCUsage  = "cannot track ProviderConfig usage"
+	errGetRole       = "cannot get Role"
+	errCreateRole    = "cannot create Role"
+	errUpdateRole    = "cannot update Role"
+	errDeleteRole    = "cannot delete Role"
+	errComparePolicy = "cannot compare assume role policy documents"
+)
+
+// Setup adds a controller that reconciles Role managed resources.
+func Setup(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore) error {
+	name := managed.ControllerName(v1alpha1.RoleGroupKind)
+
+	cps := []managed.ConnectionPublisher{managed.NewAPISecretPublisher(mgr.GetClient(), mgr.GetScheme())}
+	if o.Features.Enabled(features.EnableAlphaExternalSecretStores) {
+		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
+	}
+
+	r := managed.NewReconciler(mgr,
+		resource.ManagedKind(v1alpha1.RoleGroupVersionKind),
+		managed.WithExternalConnecter(&connector{
+			kube:         mgr.GetClient(),
+			usage:        resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+			backendStore: s,
+			log:          o.Logger.WithValues("controller", name),
+		}),
+		managed.WithLogger(o.Logger.WithValues("controller", name)),
+		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		managed.WithConnectionPublishers(cps...))
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.Role{}).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// A connector is expected to produce an ExternalClient when its Connect method
+// is called.
+type connector struct {
+	kube         client.Client
+	usage        resource.Tracker
+	backendStore *backendstore.BackendStore
+	log          logging.Logger
+}
+
+// Connect tracks that the managed resource is using a ProviderConfig and
+// returns an ExternalClient using the IAM clients of the backend store.
+func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
+	if err := c.usage.Track(ctx, mg); err != nil {
+		return nil, errors.Wrap(err, errTrackPCUsage)
+	}
+
+	return &external{backendStore: c.backendStore.GetBackendStore(), log: c.log}, nil
+}
+
+// An ExternalClient observes, then either creates, updates, or deletes an
+// external resource to ensure it reflects the managed resource's desired state.
+// A Role is managed on the backends selected by adminops.IAMClients.
+type external struct {
+	backendStore *backendstore.BackendStore
+	log          logging.Logger
+}
+
+func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	cr, ok := mg.(*v1alpha1.Role)
+	if !ok {
+		return managed.ExternalObservation{}, errors.New(errNotRole)
+	}
+
+	clients, err := adminops.IAMClients(c.backendStore, cr, apisv1alpha1.BackendModeActive, apisv1alpha1.BackendModeMaintenance)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+
+	roles, failed, err := c.getRoles(ctx, cr, clients)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if len(roles) == 0 && len(failed) == len(clients) && adminops.IsSingleBackend(cr) {
+		// Errors from a backend in maintenance are expected, leave the
+		// role alone until the backend is back.
+		return managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}, nil
+	}
+	if len(roles) == 0 {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+
+	upToDate := true
+	for backendName := range clients {
+		r, found := roles[backendName]
+		if failed[backendName] {
+			continue
+		}
+		if !found {
+			// Roles missing from a backend in maintenance are created
+			// once the backend is active again.
+			upToDate = upToDate && c.backendStore.GetBackendMode(backendName) != apisv1alpha1.BackendModeActive
+
+			continue
+		}
+		ok, err := isUpToDate(cr.Spec.ForProvider, r)
+		if err != nil {
+			return managed.ExternalObservation{}, errors.Wrap(err, errComparePolicy)
+		}
+		upToDate = upToDate && ok
+	}
+
+	r := roles[adminops.FirstName(roles)]
+	cr.Status.AtProvider.ARN = aws.ToString(r.Arn)
+	cr.Status.AtProvider.RoleID = aws.ToString(r.RoleId)
+	cr.Status.SetConditions(xpv1.Available())
+
+	return managed.ExternalObservation{
+		ResourceExists:   true,
+		ResourceUpToDate: upToDate,
+	}, nil
+}
+
+func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	cr, ok := mg.(*v1alpha1.Role)
+	if !ok {
+		return managed.ExternalCreation{}, errors.New(errNotRole)
+	}
+
+	cr.Status.SetConditions(xpv1.Creating())
+
+	// New roles are only created on backends that are not in maintenance.
+	clients, err := adminops.IAMClients(c.backendStore, cr, apisv1alpha1.BackendModeActive)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+
+	c.log.Info("Creating role", "name", roleName(cr), "backends", len(clients))
+
+	g := new(errgroup.Group)
+	for _, cl := range clients {
+		cl := cl
+		g.Go(func() error {
+			_, err := cl.CreateRole(ctx, createRoleInput(cr))
+
+			return err
+		})
+	}
+
+	return managed.ExternalCreation{}, errors.Wrap(g.Wait(), errCreateRole)
+}
+
+func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	cr, ok := mg.(*v1alpha1.Role)
+	if !ok {
+		return managed.ExternalUpdate{}, errors.New(errNotRole)
+	}
+
+	clients, err := adminops.IAMClients(c.backendStore, cr, apisv1alpha1.BackendModeActive)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+
+	roles, failed, err := c.getRoles(ctx, cr, clients)
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errUpdateRole)
+	}
+
+	g := new(errgroup.Group)
+	for backendName, cl := range clients {
+		if failed[backendName] {
+			continue
+		}
+		cl, r := cl, roles[backendName]
+		g.Go(func() error {
+			if r == nil {
+				// Roles missing from a backend, e.g. one added after
+				// the Role was created, are created there.
+				_, err := cl.CreateRole(ctx, createRoleInput(cr))
+
+				return err
+			}
+
+			return updateRole(ctx, cl, cr, r)
+		})
+	}
+
+	return managed.ExternalUpdate{}, errors.Wrap(g.Wait(), errUpdateRole)
+}
+
+func (c *external) Delete(ctx context.Context, mg resource.Managed) error {
+	cr, ok := mg.(*v1alpha1.Role)
+	if !ok {
+		return errors.New(errNotRole)
+	}
+
+	cr.Status.SetConditions(xpv1.Deleting())
+
+	// Roles are deleted from backends in maintenance too.
+	clients, err := adminops.IAMClients(c.backendStore, cr)
+	if err != nil {
+		return err
+	}
+
+	c.log.Info("Deleting role", "name", roleName(cr), "backends", len(clients))
+
+	// RGW refuses to delete roles with policies attached, so a Role is
+	// only deleted once its RolePolicies are gone.
+	g := new(errgroup.Group)
+	for _, cl := range clients {
+		cl := cl
+		g.Go(func() error {
+			_, err := cl.DeleteRole(ctx, &iam.DeleteRoleInput{RoleName: aws.String(roleName(cr))})
+			if s3internal.IsNoSuchEntity(err) {
+				return nil
+			}
+
+			return err
+		})
+	}
+
+	return errors.Wrap(g.Wait(), errDeleteRole)
+}
+
+func (c *external) getRoles(ctx context.Context, cr *v1alpha1.Role, clients map[string]*iam.Client) (map[string]*iamtypes.Role, map[string]bool, error) {
+	return adminops.Get(ctx, c.backendStore, c.log, cr, clients, errGetRole, func(ctx context.Context, cl *iam.Client) (*iamtypes.Role, error) {
+		out, err := cl.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName(cr))})
+		if s3internal.IsNoSuchEntity(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return out.Role, nil
+	})
+}
+
+// updateRole updates the trust policy and the maximum session duration of
+// the role if they differ from the desired ones. The path of a role cannot
+// be changed.
+func updateRole(ctx context.Context, cl *iam.Client, cr *v1alpha1.Role, r *iamtypes.Role) error {
+	p := cr.Spec.ForProvider
+
+	equal, err := iampolicy.Equal(p.AssumeRolePolicyDocument, aws.ToString(r.AssumeRolePolicyDocument))
+	if err != nil {
+		return errors.Wrap(err, errComparePolicy)
+	}
+	if !equal {
+		if _, err := cl.UpdateAssumeRolePolicy(ctx, &iam.UpdateAssumeRolePolicyInput{
+			RoleName:       aws.String(roleName(cr)),
+			PolicyDocument: aws.String(p.AssumeRolePolicyDocument),
+		}); err != nil {
+			return err
+		}
+	}
+
+	if p.MaxSessionDuration != nil && *p.MaxSessionDuration != aws.ToInt32(r.MaxSessionDuration) {
+		_, err := cl.UpdateRole(ctx, &iam.UpdateRoleInput{
+			RoleName:           aws.String(roleName(cr)),
+			MaxSessionDuration: p.MaxSessionDuration,
+		})
+
+		return err
+	}
+
+	return nil
+}
+
+// isUpToDate returns true if the trust policy and the maximum session
+// duration of the role are the desired ones.
+func isUpToDate(p v1alpha1.RoleParameters, r *iamtypes.Role) (bool, error) {
+	if p.MaxSessionDuration != nil && *p.MaxSessionDuration != aws.ToInt32(r.MaxSessionDuration) {
+		return false, nil
+	}
+
+	return iampolicy.Equal(p.AssumeRolePolicyDocument, aws.ToString(r.AssumeRolePolicyDocument))
+}
+
+// roleName returns the name of the role, defaulting to the name of the Role.
+func roleName(cr *v1alpha1.Role) string {
+	return v1alpha1.RoleName()(cr)
+}
+
+func createRoleInput(cr *v1alpha1.Role) *iam.CreateRoleInput {
+	in := &iam.CreateRoleInput{
+		RoleName:                 aws.String(roleName(cr)),
+		AssumeRolePolicyDocument: aws.String(cr.Spec.ForProvider.AssumeRolePolicyDocument),
+		MaxSessionDuration:       cr.Spec.ForProvider.MaxSessionDuration,
+	}
+	if cr.Spec.ForProvider.Path != "" {
+		in.Path = aws.String(cr.Spec.ForProvider.Path)
+	}
+
+	return in
+}
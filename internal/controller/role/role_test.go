@@ -0,0 +1,350 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package role
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/s3/fake"
+)
+
+const (
+	trust      = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam:::user/alice"]},"Action":["sts:AssumeRole"]}]}`
+	otherTrust = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam:::user/bob"]},"Action":["sts:AssumeRole"]}]}`
+)
+
+// newServers returns a fake server per backend, with the supplied roles
+// created, and closes them when the test ends.
+func newServers(t *testing.T, roles map[string]*iam.CreateRoleInput, names ...string) map[string]*fake.IAMServer {
+	t.Helper()
+
+	servers := fake.NewIAMServers(t, names...)
+	for name, in := range roles {
+		if _, err := servers[name].NewClient().CreateRole(context.Background(), in); err != nil {
+			t.Fatalf("CreateRole(...): %v", err)
+		}
+	}
+
+	return servers
+}
+
+func newRole(pc string, p v1alpha1.RoleParameters) *v1alpha1.Role {
+	cr := &v1alpha1.Role{ObjectMeta: metav1.ObjectMeta{Name: "reader"}, Spec: v1alpha1.RoleSpec{ForProvider: p}}
+	if pc != "" {
+		cr.SetProviderConfigReference(&xpv1.Reference{Name: pc})
+	}
+
+	return cr
+}
+
+func roleInput(trust string) *iam.CreateRoleInput {
+	return &iam.CreateRoleInput{RoleName: aws.String("reader"), AssumeRolePolicyDocument: aws.String(trust)}
+}
+
+func TestObserve(t *testing.T) {
+	t.Parallel()
+
+	type want struct {
+		o   managed.ExternalObservation
+		err bool
+	}
+
+	cases := map[string]struct {
+		reason   string
+		roles    map[string]*iam.CreateRoleInput
+		backends fake.Backends
+		mg       resource.Managed
+		want     want
+	}{
+		"InvalidManagedResource": {
+			reason: "An error should be returned if the managed resource is not a Role.",
+			want:   want{err: true},
+		},
+		"NotFound": {
+			reason: "The role should not exist if it is found on no backend.",
+			mg:     newRole("", v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust}),
+			want:   want{o: managed.ExternalObservation{ResourceExists: false}},
+		},
+		"UpToDate": {
+			reason: "A role with the desired trust policy on every backend should be up to date.",
+			roles:  map[string]*iam.CreateRoleInput{"s3-backend-1": roleInput(trust), "s3-backend-2": roleInput(trust)},
+			mg:     newRole("", v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust}),
+			want:   want{o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}},
+		},
+		"TrustPolicyDiffers": {
+			reason: "A role trusting another principal on a backend should not be up to date.",
+			roles:  map[string]*iam.CreateRoleInput{"s3-backend-1": roleInput(trust), "s3-backend-2": roleInput(otherTrust)},
+			mg:     newRole("", v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust}),
+			want:   want{o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: false}},
+		},
+		"MissingOnBackend": {
+			reason: "A role missing from an active backend should not be up to date, so that it is created there.",
+			roles:  map[string]*iam.CreateRoleInput{"s3-backend-1": roleInput(trust)},
+			mg:     newRole("", v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust}),
+			want:   want{o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: false}},
+		},
+		"MissingOnMaintenanceBackend": {
+			reason: "A role missing from a backend in maintenance should be up to date until the backend is active again.",
+			roles:  map[string]*iam.CreateRoleInput{"s3-backend-1": roleInput(trust)},
+			backends: fake.Backends{
+				Modes: map[string]apisv1alpha1.BackendMode{"s3-backend-2": apisv1alpha1.BackendModeMaintenance},
+			},
+			mg:   newRole("", v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust}),
+			want: want{o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}},
+		},
+		"MaintenanceBackendDown": {
+			reason: "A backend in maintenance that cannot be queried should be skipped.",
+			roles:  map[string]*iam.CreateRoleInput{"s3-backend-1": roleInput(trust), "s3-backend-2": roleInput(trust)},
+			backends: fake.Backends{
+				Modes: map[string]apisv1alpha1.BackendMode{"s3-backend-2": apisv1alpha1.BackendModeMaintenance},
+				Down:  map[string]bool{"s3-backend-2": true},
+			},
+			mg:   newRole("", v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust}),
+			want: want{o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}},
+		},
+		"ActiveBackendDown": {
+			reason: "An error should be returned if an active backend cannot be queried.",
+			roles:  map[string]*iam.CreateRoleInput{"s3-backend-1": roleInput(trust), "s3-backend-2": roleInput(trust)},
+			backends: fake.Backends{
+				Down: map[string]bool{"s3-backend-2": true},
+			},
+			mg:   newRole("", v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust}),
+			want: want{err: true},
+		},
+		"SingleBackendInMaintenanceDown": {
+			reason: "A role on a single backend in maintenance that cannot be queried should be left alone.",
+			backends: fake.Backends{
+				Modes: map[string]apisv1alpha1.BackendMode{"s3-backend-2": apisv1alpha1.BackendModeMaintenance},
+				Down:  map[string]bool{"s3-backend-2": true},
+			},
+			mg:   newRole("s3-backend-2", v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust}),
+			want: want{o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			servers := newServers(t, tc.roles, "s3-backend-1", "s3-backend-2")
+			e := external{backendStore: fake.NewBackendStore(servers, tc.backends), log: logging.NewNopLogger()}
+			got, err := e.Observe(context.Background(), tc.mg)
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+			if diff := cmp.Diff(tc.want.o, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestObserveStatus(t *testing.T) {
+	t.Parallel()
+
+	servers := newServers(t, map[string]*iam.CreateRoleInput{"s3-backend-1": roleInput(trust)}, "s3-backend-1")
+	e := external{backendStore: fake.NewBackendStore(servers, fake.Backends{}), log: logging.NewNopLogger()}
+	cr := newRole("", v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust})
+	if _, err := e.Observe(context.Background(), cr); err != nil {
+		t.Fatalf("e.Observe(...): %v", err)
+	}
+
+	r, _ := servers["s3-backend-1"].GetRole("reader")
+	want := v1alpha1.RoleObservation{ARN: aws.ToString(r.Arn), RoleID: aws.ToString(r.RoleId)}
+	if diff := cmp.Diff(want, cr.Status.AtProvider); diff != "" {
+		t.Errorf("e.Observe(...): -want status, +got status:\n%s", diff)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	t.Parallel()
+
+	servers := newServers(t, nil, "s3-backend-1", "s3-backend-2")
+	s := fake.NewBackendStore(servers, fake.Backends{
+		Modes: map[string]apisv1alpha1.BackendMode{"s3-backend-2": apisv1alpha1.BackendModeMaintenance},
+	})
+	e := external{backendStore: s, log: logging.NewNopLogger()}
+
+	cr := newRole("", v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust, Path: "/app/", MaxSessionDuration: aws.Int32(7200)})
+	if _, err := e.Create(context.Background(), cr); err != nil {
+		t.Fatalf("e.Create(...): %v", err)
+	}
+
+	r, ok := servers["s3-backend-1"].GetRole("reader")
+	if !ok {
+		t.Fatalf("s3-backend-1: role was not created")
+	}
+	if diff := cmp.Diff("/app/", aws.ToString(r.Path)); diff != "" {
+		t.Errorf("s3-backend-1: -want path, +got path:\n%s", diff)
+	}
+	if diff := cmp.Diff(int32(7200), aws.ToInt32(r.MaxSessionDuration)); diff != "" {
+		t.Errorf("s3-backend-1: -want max session duration, +got max session duration:\n%s", diff)
+	}
+	if _, ok := servers["s3-backend-2"].GetRole("reader"); ok {
+		t.Errorf("s3-backend-2: role should not be created on a backend in maintenance")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	servers := newServers(t, map[string]*iam.CreateRoleInput{"s3-backend-1": roleInput(otherTrust)}, "s3-backend-1", "s3-backend-2")
+	e := external{backendStore: fake.NewBackendStore(servers, fake.Backends{}), log: logging.NewNopLogger()}
+
+	cr := newRole("", v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust, MaxSessionDuration: aws.Int32(7200)})
+	if _, err := e.Update(context.Background(), cr); err != nil {
+		t.Fatalf("e.Update(...): %v", err)
+	}
+
+	// The role is updated where it differs and created where it is missing.
+	for name, srv := range servers {
+		r, ok := srv.GetRole("reader")
+		if !ok {
+			t.Errorf("%s: role was not created", name)
+
+			continue
+		}
+		if diff := cmp.Diff(trust, aws.ToString(r.AssumeRolePolicyDocument)); diff != "" {
+			t.Errorf("%s: -want trust policy, +got trust policy:\n%s", name, diff)
+		}
+		if diff := cmp.Diff(int32(7200), aws.ToInt32(r.MaxSessionDuration)); diff != "" {
+			t.Errorf("%s: -want max session duration, +got max session duration:\n%s", name, diff)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		reason   string
+		roles    map[string]*iam.CreateRoleInput
+		backends fake.Backends
+		want     error
+	}{
+		"Deleted": {
+			reason: "The role should be deleted from every backend, including those in maintenance.",
+			roles:  map[string]*iam.CreateRoleInput{"s3-backend-1": roleInput(trust), "s3-backend-2": roleInput(trust)},
+			backends: fake.Backends{
+				Modes: map[string]apisv1alpha1.BackendMode{"s3-backend-2": apisv1alpha1.BackendModeMaintenance},
+			},
+		},
+		"AlreadyGone": {
+			reason: "Deleting a role that is already gone from a backend should not return an error.",
+			roles:  map[string]*iam.CreateRoleInput{"s3-backend-1": roleInput(trust)},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			servers := newServers(t, tc.roles, "s3-backend-1", "s3-backend-2")
+			e := external{backendStore: fake.NewBackendStore(servers, tc.backends), log: logging.NewNopLogger()}
+			err := e.Delete(context.Background(), newRole("", v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust}))
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Delete(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			for name, srv := range servers {
+				if _, ok := srv.GetRole("reader"); ok {
+					t.Errorf("\n%s\n%s: role was not deleted", tc.reason, name)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteBackendDown(t *testing.T) {
+	t.Parallel()
+
+	servers := newServers(t, map[string]*iam.CreateRoleInput{"s3-backend-1": roleInput(trust)}, "s3-backend-1", "s3-backend-2")
+	s := fake.NewBackendStore(servers, fake.Backends{Down: map[string]bool{"s3-backend-2": true}})
+	e := external{backendStore: s, log: logging.NewNopLogger()}
+
+	// The Role must not be reported as deleted while a backend may still
+	// have the role.
+	if err := e.Delete(context.Background(), newRole("", v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust})); err == nil {
+		t.Errorf("e.Delete(...): expected an error deleting from a backend that is down")
+	}
+}
+
+func TestIsUpToDate(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		reason string
+		p      v1alpha1.RoleParameters
+		r      *iamtypes.Role
+		want   bool
+	}{
+		"UpToDate": {
+			reason: "A role with the desired trust policy should be up to date.",
+			p:      v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust},
+			r:      &iamtypes.Role{AssumeRolePolicyDocument: aws.String(trust), MaxSessionDuration: aws.Int32(3600)},
+			want:   true,
+		},
+		"TrustPolicyFormatting": {
+			reason: "A URL encoded trust policy with single element arrays unwrapped should be up to date.",
+			p:      v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust},
+			r: &iamtypes.Role{
+				AssumeRolePolicyDocument: aws.String(`%7B%22Statement%22%3A%7B%22Action%22%3A%22sts%3AAssumeRole%22%2C%22Effect%22%3A%22Allow%22%2C%22Principal%22%3A%7B%22AWS%22%3A%22arn%3Aaws%3Aiam%3A%3A%3Auser%2Falice%22%7D%7D%2C%22Version%22%3A%222012-10-17%22%7D`),
+			},
+			want: true,
+		},
+		"TrustPolicyDiffers": {
+			reason: "A role trusting another principal should not be up to date.",
+			p:      v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust},
+			r:      &iamtypes.Role{AssumeRolePolicyDocument: aws.String(otherTrust)},
+			want:   false,
+		},
+		"MaxSessionDurationDiffers": {
+			reason: "A role with another maximum session duration should not be up to date.",
+			p:      v1alpha1.RoleParameters{AssumeRolePolicyDocument: trust, MaxSessionDuration: aws.Int32(7200)},
+			r:      &iamtypes.Role{AssumeRolePolicyDocument: aws.String(trust), MaxSessionDuration: aws.Int32(3600)},
+			want:   false,
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := isUpToDate(tc.p, tc.r)
+			if err != nil {
+				t.Fatalf("\n%s\nisUpToDate(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nisUpToDate(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2023 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rolepolicy
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/connection"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/controller/features"
+	"github.com/crossplane/provider-ceph/internal/controller/inlinepolicy"
+)
+
+const errTrackPCUsage = "cannot track ProviderConfig usage"
+
+// Setup adds a controller that reconciles RolePolicy managed resources.
+func Setup(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore) error {
+	name := managed.ControllerName(v1alpha1.RolePolicyGroupKind)
+
+	cps := []managed.ConnectionPublisher{managed.NewAPISecretPublisher(mgr.GetClient(), mgr.GetScheme())}
+	if o.Features.Enabled(features.EnableAlphaExternalSecretStores) {
+		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
+	}
+
+	r := managed.NewReconciler(mgr,
+		resource.ManagedKind(v1alpha1.RolePolicyGroupVersionKind),
+		managed.WithExternalConnecter(&connector{
+			kube:         mgr.GetClient(),
+			usage:        resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+			backendStore: s,
+			log:          o.Logger.WithValues("controller", name),
+		}),
+		managed.WithReferenceResolver(managed.NewAPISimpleReferenceResolver(mgr.GetClient())),
+		managed.WithLogger(o.Logger.WithValues("controller", name)),
+		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		managed.WithConnectionPublishers(cps...))
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.RolePolicy{}).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// A connector is expected to produce an ExternalClient when its Connect method
+// is called.
+type connector struct {
+	kube         client.Client
+	usage        resource.Tracker
+	backendStore *backendstore.BackendStore
+	log          logging.Logger
+}
+
+// Connect tracks that the managed resource is using a ProviderConfig and
+// returns an ExternalClient using the IAM clients of the backend store.
+func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
+	if err := c.usage.Track(ctx, mg); err != nil {
+		return nil, errors.Wrap(err, errTrackPCUsage)
+	}
+
+	return &inlinepolicy.External[*v1alpha1.RolePolicy]{Policy: policy, BackendStore: c.backendStore.GetBackendStore(), Log: c.log}, nil
+}
+
+// policy manages the inline policies of roles.
+var policy = inlinepolicy.Policy[*v1alpha1.RolePolicy]{
+	Kind:            v1alpha1.RolePolicyKind,
+	EntityNameField: "roleName",
+	Parameters: func(cr *v1alpha1.RolePolicy) inlinepolicy.Parameters {
+		return inlinepolicy.Parameters{
+			EntityName:     cr.Spec.ForProvider.RoleName,
+			PolicyName:     inlinepolicy.PolicyName(cr, cr.Spec.ForProvider.PolicyName),
+			PolicyDocument: cr.Spec.ForProvider.PolicyDocument,
+		}
+	},
+	Get: func(ctx context.Context, cl *iam.Client, p inlinepolicy.Parameters) (string, error) {
+		out, err := cl.GetRolePolicy(ctx, &iam.GetRolePolicyInput{
+			RoleName:   aws.String(p.EntityName),
+			PolicyName: aws.String(p.PolicyName),
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return aws.ToString(out.PolicyDocument), nil
+	},
+	Put: func(ctx context.Context, cl *iam.Client, p inlinepolicy.Parameters) error {
+		_, err := cl.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+			RoleName:       aws.String(p.EntityName),
+			PolicyName:     aws.String(p.PolicyName),
+			PolicyDocument: aws.String(p.PolicyDocument),
+		})
+
+		return err
+	},
+	Delete: func(ctx context.Context, cl *iam.Client, p inlinepolicy.Parameters) error {
+		_, err := cl.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{
+			RoleName:   aws.String(p.EntityName),
+			PolicyName: aws.String(p.PolicyName),
+		})
+
+		return err
+	},
+}
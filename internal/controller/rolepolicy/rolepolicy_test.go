@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rolepolicy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/controller/inlinepolicy"
+	"github.com/crossplane/provider-ceph/internal/s3/fake"
+)
+
+const readOnly = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::photos/*"]}]}`
+
+// TestPolicy checks that the policy of a RolePolicy is put on, observed on and
+// deleted from its role. The reconcile logic is tested by inlinepolicy.
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	servers := fake.NewIAMServers(t, "s3-backend-1")
+	srv := servers["s3-backend-1"]
+	if _, err := srv.NewClient().CreateRole(ctx, &iam.CreateRoleInput{RoleName: aws.String("reader"), AssumeRolePolicyDocument: aws.String(`{}`)}); err != nil {
+		t.Fatalf("CreateRole(...): %v", err)
+	}
+
+	s := fake.NewBackendStore(servers, fake.Backends{})
+	e := &inlinepolicy.External[*v1alpha1.RolePolicy]{Policy: policy, BackendStore: s, Log: logging.NewNopLogger()}
+
+	cr := &v1alpha1.RolePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-only"},
+		Spec:       v1alpha1.RolePolicySpec{ForProvider: v1alpha1.RolePolicyParameters{RoleName: "reader", PolicyDocument: readOnly}},
+	}
+	if _, err := e.Create(ctx, cr); err != nil {
+		t.Fatalf("e.Create(...): %v", err)
+	}
+	if doc, _ := srv.GetRolePolicy("reader", "read-only"); doc != readOnly {
+		t.Errorf("e.Create(...): want policy document %s, got %s", readOnly, doc)
+	}
+
+	got, err := e.Observe(ctx, cr)
+	if err != nil {
+		t.Fatalf("e.Observe(...): %v", err)
+	}
+	if diff := cmp.Diff(managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}, got); diff != "" {
+		t.Errorf("e.Observe(...): -want, +got:\n%s", diff)
+	}
+
+	if err := e.Delete(ctx, cr); err != nil {
+		t.Fatalf("e.Delete(...): %v", err)
+	}
+	if _, ok := srv.GetRolePolicy("reader", "read-only"); ok {
+		t.Errorf("e.Delete(...): policy was not deleted")
+	}
+}
+
+func TestNoRoleName(t *testing.T) {
+	t.Parallel()
+
+	e := &inlinepolicy.External[*v1alpha1.RolePolicy]{Policy: policy, BackendStore: backendstore.NewBackendStore(), Log: logging.NewNopLogger()}
+	_, err := e.Observe(context.Background(), &v1alpha1.RolePolicy{ObjectMeta: metav1.ObjectMeta{Name: "read-only"}})
+	want := errors.New("no roleName specified or resolved")
+	if diff := cmp.Diff(want, err, test.EquateErrors()); diff != "" {
+		t.Errorf("e.Observe(...): -want error, +got error:\n%s", diff)
+	}
+}
@@ -0,0 +1,464 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subuser
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/controller/adminops"
+	"github.com/crossplane/provider-ceph/internal/controller/features"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
+)
+
+const (
+	errNotSubuser    = "managed resource is not a Subuser custom resource"
+	errTrackPCUsage  = "cannot track ProviderConfig usage"
+	errNoUserID      = "no userID specified or resolved"
+	errCreateSubuser = "cannot create Subuser"
+	errUpdateSubuser = "cannot update Subuser"
+	errDeleteSubuser = "cannot delete Subuser"
+	errSaveKeyID     = "cannot save access key ID of Subuser"
+)
+
+// Setup adds a controller that reconciles Subuser managed resources.
+func Setup(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore) error {
+	name := managed.ControllerName(v1alpha1.SubuserGroupKind)
+
+	cps := []managed.ConnectionPublisher{managed.NewAPISecretPublisher(mgr.GetClient(), mgr.GetScheme())}
+	if o.Features.Enabled(features.EnableAlphaExternalSecretStores) {
+		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
+	}
+
+	r := managed.NewReconciler(mgr,
+		resource.ManagedKind(v1alpha1.SubuserGroupVersionKind),
+		managed.WithExternalConnecter(&connector{
+			kube:         mgr.GetClient(),
+			usage:        resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+			backendStore: s,
+			log:          o.Logger.WithValues("controller", name),
+		}),
+		managed.WithReferenceResolver(managed.NewAPISimpleReferenceResolver(mgr.GetClient())),
+		managed.WithLogger(o.Logger.WithValues("controller", name)),
+		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		managed.WithConnectionPublishers(cps...))
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.Subuser{}).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// A connector is expected to produce an ExternalClient when its Connect method
+// is called.
+type connector struct {
+	kube         client.Client
+	usage        resource.Tracker
+	backendStore *backendstore.BackendStore
+	log          logging.Logger
+}
+
+// Connect tracks that the managed resource is using a ProviderConfig and
+// returns an ExternalClient using the admin ops clients of the backend store.
+func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
+	if err := c.usage.Track(ctx, mg); err != nil {
+		return nil, errors.Wrap(err, errTrackPCUsage)
+	}
+
+	return &external{kube: c.kube, backendStore: c.backendStore.GetBackendStore(), log: c.log}, nil
+}
+
+// An ExternalClient observes, then either creates, updates, or deletes an
+// external resource to ensure it reflects the managed resource's desired state.
+// A Subuser is managed on the backends selected by adminops.Clients, with the
+// same key on every backend. The access key ID of the S3 key of a Subuser is
+// recorded in an annotation, as the subuser may have other S3 keys, e.g.
+// those of AccessKeys.
+type external struct {
+	kube         client.Client
+	backendStore *backendstore.BackendStore
+	log          logging.Logger
+}
+
+func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	cr, ok := mg.(*v1alpha1.Subuser)
+	if !ok {
+		return managed.ExternalObservation{}, errors.New(errNotSubuser)
+	}
+	if cr.Spec.ForProvider.UserID == "" {
+		return managed.ExternalObservation{}, errors.New(errNoUserID)
+	}
+
+	clients, err := adminops.Clients(c.backendStore, cr, apisv1alpha1.BackendModeActive, apisv1alpha1.BackendModeMaintenance)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+
+	users, failed, err := c.getUsers(ctx, cr, clients)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if len(users) == 0 && len(failed) == len(clients) && adminops.IsSingleBackend(cr) {
+		// Errors from a backend in maintenance are expected, leave the
+		// subuser alone until the backend is back.
+		return managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}, nil
+	}
+
+	id := subuserSpec(cr).ID()
+	found := map[string]*rgwadmin.Subuser{}
+	for backendName, u := range users {
+		if sub := findSubuser(u, id); sub != nil {
+			found[backendName] = sub
+		}
+	}
+	if len(found) == 0 {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+
+	key, _ := findKey(users, cr)
+	want := rgwadmin.SubuserPermissions(cr.Spec.ForProvider.Access)
+	upToDate := true
+	for backendName := range clients {
+		if failed[backendName] || c.backendStore.GetBackendMode(backendName) != apisv1alpha1.BackendModeActive {
+			continue
+		}
+		sub, ok := found[backendName]
+		if !ok || sub.Permissions != want {
+			upToDate = false
+
+			continue
+		}
+		if _, ok := findKey(map[string]*rgwadmin.User{backendName: users[backendName]}, cr); !ok {
+			upToDate = false
+		}
+	}
+
+	cr.Status.AtProvider.ID = id
+	cr.Status.AtProvider.Permissions = found[adminops.FirstName(found)].Permissions
+	cr.Status.SetConditions(xpv1.Available())
+
+	return managed.ExternalObservation{
+		ResourceExists:    true,
+		ResourceUpToDate:  upToDate,
+		ConnectionDetails: connectionDetails(cr, key),
+	}, nil
+}
+
+func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	cr, ok := mg.(*v1alpha1.Subuser)
+	if !ok {
+		return managed.ExternalCreation{}, errors.New(errNotSubuser)
+	}
+
+	cr.Status.SetConditions(xpv1.Creating())
+
+	if cr.Spec.ForProvider.UserID == "" {
+		return managed.ExternalCreation{}, errors.New(errNoUserID)
+	}
+
+	// New subusers are only created on backends that are not in
+	// maintenance.
+	clients, err := adminops.Clients(c.backendStore, cr, apisv1alpha1.BackendModeActive)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+
+	key, err := generateKey(cr)
+	if err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errCreateSubuser)
+	}
+
+	// Status changes made here are not persisted, annotations are.
+	setAccessKeyID(cr, key)
+
+	g := new(errgroup.Group)
+	for _, cl := range clients {
+		cl := cl
+		g.Go(func() error {
+			return createSubuser(ctx, cl, cr, key)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errCreateSubuser)
+	}
+
+	return managed.ExternalCreation{ConnectionDetails: connectionDetails(cr, key)}, nil
+}
+
+func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	cr, ok := mg.(*v1alpha1.Subuser)
+	if !ok {
+		return managed.ExternalUpdate{}, errors.New(errNotSubuser)
+	}
+
+	clients, err := adminops.Clients(c.backendStore, cr, apisv1alpha1.BackendModeActive)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+
+	users, failed, err := c.getUsers(ctx, cr, clients)
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errUpdateSubuser)
+	}
+
+	spec := subuserSpec(cr)
+	key, found := findKey(users, cr)
+	if !found {
+		// The key is missing everywhere, e.g. because it was removed
+		// out of band, so a new one is created. Its access key ID is
+		// saved first, as annotations set by Update are not persisted
+		// otherwise.
+		if key, err = generateKey(cr); err != nil {
+			return managed.ExternalUpdate{}, errors.Wrap(err, errUpdateSubuser)
+		}
+		if setAccessKeyID(cr, key) {
+			status := cr.Status.DeepCopy()
+			err := c.kube.Update(ctx, cr)
+			cr.Status = *status
+			if err != nil {
+				return managed.ExternalUpdate{}, errors.Wrap(err, errSaveKeyID)
+			}
+		}
+	}
+
+	g := new(errgroup.Group)
+	for backendName, cl := range clients {
+		if failed[backendName] {
+			continue
+		}
+		cl, u := cl, users[backendName]
+		g.Go(func() error {
+			sub := findSubuser(u, spec.ID())
+			if sub == nil {
+				// Subusers missing from a backend, e.g. one added after
+				// the Subuser was created, get the same key.
+				return createSubuser(ctx, cl, cr, key)
+			}
+			if sub.Permissions != rgwadmin.SubuserPermissions(spec.Access) {
+				if err := cl.ModifySubuser(ctx, spec); err != nil {
+					return err
+				}
+			}
+			if _, ok := findKey(map[string]*rgwadmin.User{"": u}, cr); !ok {
+				_, err := cl.CreateKey(ctx, keySpec(cr, key))
+
+				return err
+			}
+
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errUpdateSubuser)
+	}
+
+	return managed.ExternalUpdate{ConnectionDetails: connectionDetails(cr, key)}, nil
+}
+
+func (c *external) Delete(ctx context.Context, mg resource.Managed) error {
+	cr, ok := mg.(*v1alpha1.Subuser)
+	if !ok {
+		return errors.New(errNotSubuser)
+	}
+
+	cr.Status.SetConditions(xpv1.Deleting())
+
+	// Subusers are deleted from backends in maintenance too.
+	clients, err := adminops.Clients(c.backendStore, cr)
+	if err != nil {
+		return err
+	}
+
+	g := new(errgroup.Group)
+	for _, cl := range clients {
+		cl := cl
+		g.Go(func() error {
+			err := cl.RemoveSubuser(ctx, subuserSpec(cr))
+			if rgwadmin.IsNotFound(err) {
+				return nil
+			}
+
+			return err
+		})
+	}
+
+	return errors.Wrap(g.Wait(), errDeleteSubuser)
+}
+
+func (c *external) getUsers(ctx context.Context, cr *v1alpha1.Subuser, clients map[string]*rgwadmin.Client) (map[string]*rgwadmin.User, map[string]bool, error) {
+	return adminops.GetUsers(ctx, c.backendStore, c.log, cr, clients, cr.Spec.ForProvider.Tenant, cr.Spec.ForProvider.UserID, false)
+}
+
+func createSubuser(ctx context.Context, cl *rgwadmin.Client, cr *v1alpha1.Subuser, key rgwadmin.UserKey) error {
+	spec := subuserSpec(cr)
+	spec.KeyType = keyType(cr)
+	spec.AccessKey, spec.SecretKey = key.AccessKey, key.SecretKey
+
+	_, err := cl.CreateSubuser(ctx, spec)
+
+	return err
+}
+
+// subuserName returns the name of the subuser, defaulting to the name of the
+// Subuser.
+func subuserName(cr *v1alpha1.Subuser) string {
+	if cr.Spec.ForProvider.Name != "" {
+		return cr.Spec.ForProvider.Name
+	}
+
+	return cr.Name
+}
+
+func subuserSpec(cr *v1alpha1.Subuser) rgwadmin.SubuserSpec {
+	return rgwadmin.SubuserSpec{
+		Tenant:  cr.Spec.ForProvider.Tenant,
+		UserID:  cr.Spec.ForProvider.UserID,
+		Subuser: subuserName(cr),
+		Access:  cr.Spec.ForProvider.Access,
+	}
+}
+
+func keySpec(cr *v1alpha1.Subuser, key rgwadmin.UserKey) rgwadmin.KeySpec {
+	return rgwadmin.KeySpec{
+		Tenant:    cr.Spec.ForProvider.Tenant,
+		UserID:    cr.Spec.ForProvider.UserID,
+		Subuser:   subuserName(cr),
+		KeyType:   keyType(cr),
+		AccessKey: key.AccessKey,
+		SecretKey: key.SecretKey,
+	}
+}
+
+func keyType(cr *v1alpha1.Subuser) string {
+	if cr.Spec.ForProvider.KeyType == "" {
+		return rgwadmin.KeyTypeSwift
+	}
+
+	return cr.Spec.ForProvider.KeyType
+}
+
+// generateKey generates a key of the key type of the Subuser. Swift keys
+// only have a secret key.
+func generateKey(cr *v1alpha1.Subuser) (rgwadmin.UserKey, error) {
+	key := rgwadmin.UserKey{User: subuserSpec(cr).ID()}
+	if keyType(cr) == rgwadmin.KeyTypeSwift {
+		secretKey, err := rgwadmin.GenerateSecretKey()
+		key.SecretKey = secretKey
+
+		return key, err
+	}
+
+	accessKey, secretKey, err := rgwadmin.GenerateKeyPair()
+	key.AccessKey, key.SecretKey = accessKey, secretKey
+
+	return key, err
+}
+
+func findSubuser(u *rgwadmin.User, id string) *rgwadmin.Subuser {
+	if u == nil {
+		return nil
+	}
+	for i := range u.Subusers {
+		if u.Subusers[i].ID == id {
+			return &u.Subusers[i]
+		}
+	}
+
+	return nil
+}
+
+// findKey returns the key of the Subuser, taken from the first backend in
+// name order that has it. A subuser has a single Swift key, while its S3 key
+// is the one with the access key ID recorded in the annotations.
+func findKey(users map[string]*rgwadmin.User, cr *v1alpha1.Subuser) (rgwadmin.UserKey, bool) {
+	id := subuserSpec(cr).ID()
+	accessKeyID := cr.GetAnnotations()[v1alpha1.AnnotationKeyAccessKeyID]
+
+	found := map[string]rgwadmin.UserKey{}
+	for name, u := range users {
+		if u == nil {
+			continue
+		}
+		if keyType(cr) == rgwadmin.KeyTypeSwift {
+			for _, k := range u.SwiftKeys {
+				if k.User == id {
+					found[name] = rgwadmin.UserKey{User: k.User, SecretKey: k.SecretKey}
+				}
+			}
+
+			continue
+		}
+		for _, k := range u.Keys {
+			if k.User == id && accessKeyID != "" && k.AccessKey == accessKeyID {
+				found[name] = k
+			}
+		}
+	}
+	if len(found) == 0 {
+		return rgwadmin.UserKey{}, false
+	}
+
+	return found[adminops.FirstName(found)], true
+}
+
+// setAccessKeyID records the access key ID of the supplied S3 key of the
+// Subuser, returning true if it changed.
+func setAccessKeyID(cr *v1alpha1.Subuser, key rgwadmin.UserKey) bool {
+	if key.AccessKey == "" || cr.GetAnnotations()[v1alpha1.AnnotationKeyAccessKeyID] == key.AccessKey {
+		return false
+	}
+	meta.AddAnnotations(cr, map[string]string{v1alpha1.AnnotationKeyAccessKeyID: key.AccessKey})
+
+	return true
+}
+
+func connectionDetails(cr *v1alpha1.Subuser, key rgwadmin.UserKey) managed.ConnectionDetails {
+	if key.SecretKey == "" {
+		return managed.ConnectionDetails{}
+	}
+
+	if keyType(cr) == rgwadmin.KeyTypeSwift {
+		return managed.ConnectionDetails{
+			v1alpha1.ConnectionDetailSwiftUser:      []byte(key.User),
+			v1alpha1.ConnectionDetailSwiftSecretKey: []byte(key.SecretKey),
+		}
+	}
+
+	return managed.ConnectionDetails{
+		v1alpha1.ConnectionDetailAccessKey: []byte(key.AccessKey),
+		v1alpha1.ConnectionDetailSecretKey: []byte(key.SecretKey),
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subuser
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin/fake"
+)
+
+func TestSubuser(t *testing.T) {
+	t.Parallel()
+
+	servers := map[string]*fake.Server{"s3-backend-1": fake.NewServer(), "s3-backend-2": fake.NewServer()}
+	s := backendstore.NewBackendStore()
+	ctx := context.Background()
+	for name, srv := range servers {
+		defer srv.Close()
+		s.AddOrUpdateBackend(name, s3.New(s3.Options{}))
+		s.SetAdminClient(name, srv.NewClient())
+		if _, err := srv.NewClient().CreateUser(ctx, rgwadmin.UserSpec{UserID: "alice", DisplayName: "Alice"}); err != nil {
+			t.Fatalf("CreateUser(...): %v", err)
+		}
+	}
+
+	e := external{backendStore: s, log: logging.NewNopLogger()}
+	cr := &v1alpha1.Subuser{
+		ObjectMeta: metav1.ObjectMeta{Name: "swift"},
+		Spec: v1alpha1.SubuserSpec{ForProvider: v1alpha1.SubuserParameters{
+			UserID: "alice",
+			Access: rgwadmin.SubuserAccessRead,
+		}},
+	}
+
+	o, err := e.Observe(ctx, cr)
+	if err != nil {
+		t.Fatalf("e.Observe(...): %v", err)
+	}
+	if diff := cmp.Diff(managed.ExternalObservation{ResourceExists: false}, o); diff != "" {
+		t.Errorf("e.Observe(...): -want, +got:\n%s", diff)
+	}
+
+	created, err := e.Create(ctx, cr)
+	if err != nil {
+		t.Fatalf("e.Create(...): %v", err)
+	}
+	if diff := cmp.Diff("alice:swift", string(created.ConnectionDetails[v1alpha1.ConnectionDetailSwiftUser])); diff != "" {
+		t.Errorf("e.Create(...): -want swift user, +got swift user:\n%s", diff)
+	}
+
+	// Changing the access level makes the subuser outdated.
+	cr.Spec.ForProvider.Access = rgwadmin.SubuserAccessFull
+	o, err = e.Observe(ctx, cr)
+	if err != nil {
+		t.Fatalf("e.Observe(...): %v", err)
+	}
+	want := managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: false, ConnectionDetails: created.ConnectionDetails}
+	if diff := cmp.Diff(want, o); diff != "" {
+		t.Errorf("e.Observe(...): -want, +got:\n%s", diff)
+	}
+
+	if _, err := e.Update(ctx, cr); err != nil {
+		t.Fatalf("e.Update(...): %v", err)
+	}
+	for name, srv := range servers {
+		u, _ := srv.GetUser("alice")
+		if diff := cmp.Diff([]rgwadmin.Subuser{{ID: "alice:swift", Permissions: "full-control"}}, u.Subusers); diff != "" {
+			t.Errorf("%s: -want subusers, +got subusers:\n%s", name, diff)
+		}
+		if diff := cmp.Diff(string(created.ConnectionDetails[v1alpha1.ConnectionDetailSwiftSecretKey]), u.SwiftKeys[0].SecretKey); diff != "" {
+			t.Errorf("%s: -want swift secret, +got swift secret:\n%s", name, diff)
+		}
+	}
+
+	if err := e.Delete(ctx, cr); err != nil {
+		t.Fatalf("e.Delete(...): %v", err)
+	}
+	for name, srv := range servers {
+		if u, _ := srv.GetUser("alice"); len(u.Subusers) != 0 || len(u.SwiftKeys) != 0 {
+			t.Errorf("%s: e.Delete(...): want no subusers and swift keys, got %+v", name, u)
+		}
+	}
+}
+
+func TestS3SubuserKey(t *testing.T) {
+	t.Parallel()
+
+	srv := fake.NewServer()
+	defer srv.Close()
+
+	s := backendstore.NewBackendStore()
+	s.AddOrUpdateBackend("s3-backend-1", s3.New(s3.Options{}))
+	s.SetAdminClient("s3-backend-1", srv.NewClient())
+
+	ctx := context.Background()
+	if _, err := srv.NewClient().CreateUser(ctx, rgwadmin.UserSpec{UserID: "alice", DisplayName: "Alice"}); err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+
+	e := external{kube: &test.MockClient{MockUpdate: test.NewMockUpdateFn(nil)}, backendStore: s, log: logging.NewNopLogger()}
+	cr := &v1alpha1.Subuser{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3"},
+		Spec: v1alpha1.SubuserSpec{ForProvider: v1alpha1.SubuserParameters{
+			UserID:  "alice",
+			Access:  rgwadmin.SubuserAccessRead,
+			KeyType: rgwadmin.KeyTypeS3,
+		}},
+	}
+
+	created, err := e.Create(ctx, cr)
+	if err != nil {
+		t.Fatalf("e.Create(...): %v", err)
+	}
+	accessKeyID := string(created.ConnectionDetails[v1alpha1.ConnectionDetailAccessKey])
+	if diff := cmp.Diff(accessKeyID, cr.GetAnnotations()[v1alpha1.AnnotationKeyAccessKeyID]); diff != "" {
+		t.Errorf("e.Create(...): -want access key ID annotation, +got:\n%s", diff)
+	}
+
+	// Another S3 key of the subuser, e.g. that of an AccessKey, is never
+	// published by the Subuser.
+	if _, err := srv.NewClient().CreateKey(ctx, rgwadmin.KeySpec{UserID: "alice", Subuser: "s3", KeyType: rgwadmin.KeyTypeS3, AccessKey: "AAAAAAAAAAAAAAAAAAAA", SecretKey: "other"}); err != nil {
+		t.Fatalf("CreateKey(...): %v", err)
+	}
+	o, err := e.Observe(ctx, cr)
+	if err != nil {
+		t.Fatalf("e.Observe(...): %v", err)
+	}
+	if diff := cmp.Diff(created.ConnectionDetails, o.ConnectionDetails); diff != "" {
+		t.Errorf("e.Observe(...): -want connection details, +got:\n%s", diff)
+	}
+
+	// A key removed out of band is replaced, and the new key recorded.
+	if err := srv.NewClient().RemoveKey(ctx, rgwadmin.KeySpec{UserID: "alice", Subuser: "s3", KeyType: rgwadmin.KeyTypeS3, AccessKey: accessKeyID}); err != nil {
+		t.Fatalf("RemoveKey(...): %v", err)
+	}
+	updated, err := e.Update(ctx, cr)
+	if err != nil {
+		t.Fatalf("e.Update(...): %v", err)
+	}
+	newAccessKeyID := string(updated.ConnectionDetails[v1alpha1.ConnectionDetailAccessKey])
+	if newAccessKeyID == accessKeyID || newAccessKeyID == "AAAAAAAAAAAAAAAAAAAA" {
+		t.Errorf("e.Update(...): want a new key, got %q", newAccessKeyID)
+	}
+	if diff := cmp.Diff(newAccessKeyID, cr.GetAnnotations()[v1alpha1.AnnotationKeyAccessKeyID]); diff != "" {
+		t.Errorf("e.Update(...): -want access key ID annotation, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,511 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/controller/adminops"
+	"github.com/crossplane/provider-ceph/internal/controller/features"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
+)
+
+const (
+	errNotUser      = "managed resource is not a User custom resource"
+	errTrackPCUsage = "cannot track ProviderConfig usage"
+	errCreateUser   = "cannot create User"
+	errUpdateUser   = "cannot update User"
+	errDeleteUser   = "cannot delete User"
+	errUpdateCaps   = "cannot update User caps"
+	errUpdateQuota  = "cannot update User quota"
+)
+
+// Setup adds a controller that reconciles User managed resources.
+func Setup(mgr ctrl.Manager, o controller.Options, s *backendstore.BackendStore) error {
+	name := managed.ControllerName(v1alpha1.UserGroupKind)
+
+	cps := []managed.ConnectionPublisher{managed.NewAPISecretPublisher(mgr.GetClient(), mgr.GetScheme())}
+	if o.Features.Enabled(features.EnableAlphaExternalSecretStores) {
+		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
+	}
+
+	r := managed.NewReconciler(mgr,
+		resource.ManagedKind(v1alpha1.UserGroupVersionKind),
+		managed.WithExternalConnecter(&connector{
+			kube:         mgr.GetClient(),
+			usage:        resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+			backendStore: s,
+			log:          o.Logger.WithValues("controller", name),
+		}),
+		managed.WithLogger(o.Logger.WithValues("controller", name)),
+		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		managed.WithConnectionPublishers(cps...))
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.User{}).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// A connector is expected to produce an ExternalClient when its Connect method
+// is called.
+type connector struct {
+	kube         client.Client
+	usage        resource.Tracker
+	backendStore *backendstore.BackendStore
+	log          logging.Logger
+}
+
+// Connect tracks that the managed resource is using a ProviderConfig and
+// returns an ExternalClient using the admin ops clients of the backend store.
+func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
+	if err := c.usage.Track(ctx, mg); err != nil {
+		return nil, errors.Wrap(err, errTrackPCUsage)
+	}
+
+	return &external{backendStore: c.backendStore.GetBackendStore(), log: c.log}, nil
+}
+
+// An ExternalClient observes, then either creates, updates, or deletes an
+// external resource to ensure it reflects the managed resource's desired state.
+// A User is managed on the backends selected by adminops.Clients.
+type external struct {
+	backendStore *backendstore.BackendStore
+	log          logging.Logger
+}
+
+func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	cr, ok := mg.(*v1alpha1.User)
+	if !ok {
+		return managed.ExternalObservation{}, errors.New(errNotUser)
+	}
+
+	clients, err := adminops.Clients(c.backendStore, cr, apisv1alpha1.BackendModeActive, apisv1alpha1.BackendModeMaintenance)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+
+	users, failed, err := adminops.GetUsers(ctx, c.backendStore, c.log, cr, clients, cr.Spec.ForProvider.Tenant, uid(cr), true)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if len(users) == 0 && len(failed) == len(clients) && adminops.IsSingleBackend(cr) {
+		// Errors from a backend in maintenance are expected, leave the
+		// user alone until the backend is back.
+		return managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}, nil
+	}
+	if len(users) == 0 {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+
+	key := publishedKey(cr, users)
+	upToDate := true
+	for backendName := range clients {
+		u, found := users[backendName]
+		if failed[backendName] {
+			continue
+		}
+		if !found {
+			// Users missing from a backend in maintenance are created
+			// once the backend is active again.
+			upToDate = upToDate && c.backendStore.GetBackendMode(backendName) != apisv1alpha1.BackendModeActive
+
+			continue
+		}
+		upToDate = upToDate && isUpToDate(cr.Spec.ForProvider, u)
+	}
+
+	cr.Status.AtProvider.AccessKeyID = key.AccessKey
+	cr.Status.AtProvider.Backends = backendStatuses(users)
+	cr.Status.SetConditions(xpv1.Available())
+
+	return managed.ExternalObservation{
+		ResourceExists:    true,
+		ResourceUpToDate:  upToDate,
+		ConnectionDetails: connectionDetails(key),
+	}, nil
+}
+
+func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	cr, ok := mg.(*v1alpha1.User)
+	if !ok {
+		return managed.ExternalCreation{}, errors.New(errNotUser)
+	}
+
+	cr.Status.SetConditions(xpv1.Creating())
+
+	// New users are only created on backends that are not in maintenance.
+	clients, err := adminops.Clients(c.backendStore, cr, apisv1alpha1.BackendModeActive)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+
+	// The key pair is generated here rather than by RGW so that the user
+	// has the same credentials on every backend.
+	accessKey, secretKey, err := rgwadmin.GenerateKeyPair()
+	if err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errCreateUser)
+	}
+	key := rgwadmin.UserKey{AccessKey: accessKey, SecretKey: secretKey}
+
+	// Status changes made here are not persisted, annotations are.
+	meta.AddAnnotations(cr, map[string]string{v1alpha1.AnnotationKeyAccessKeyID: key.AccessKey})
+
+	c.log.Info("Creating user", "uid", uid(cr), "backends", len(clients))
+
+	g := new(errgroup.Group)
+	for _, cl := range clients {
+		cl := cl
+		g.Go(func() error {
+			return c.createUser(ctx, cl, cr, key)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errCreateUser)
+	}
+
+	cr.Status.AtProvider.AccessKeyID = key.AccessKey
+
+	return managed.ExternalCreation{ConnectionDetails: connectionDetails(key)}, nil
+}
+
+func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	cr, ok := mg.(*v1alpha1.User)
+	if !ok {
+		return managed.ExternalUpdate{}, errors.New(errNotUser)
+	}
+
+	clients, err := adminops.Clients(c.backendStore, cr, apisv1alpha1.BackendModeActive)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+
+	users, failed, err := adminops.GetUsers(ctx, c.backendStore, c.log, cr, clients, cr.Spec.ForProvider.Tenant, uid(cr), false)
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errUpdateUser)
+	}
+	key := publishedKey(cr, users)
+
+	g := new(errgroup.Group)
+	for backendName, cl := range clients {
+		if failed[backendName] {
+			continue
+		}
+		cl, u := cl, users[backendName]
+		g.Go(func() error {
+			if u == nil {
+				// Users missing from a backend, e.g. one added after the
+				// User was created, get the same key pair.
+				return c.createUser(ctx, cl, cr, key)
+			}
+
+			return c.updateUser(ctx, cl, cr, u)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errUpdateUser)
+	}
+
+	return managed.ExternalUpdate{ConnectionDetails: connectionDetails(key)}, nil
+}
+
+func (c *external) Delete(ctx context.Context, mg resource.Managed) error {
+	cr, ok := mg.(*v1alpha1.User)
+	if !ok {
+		return errors.New(errNotUser)
+	}
+
+	cr.Status.SetConditions(xpv1.Deleting())
+
+	// Users are deleted from backends in maintenance too.
+	clients, err := adminops.Clients(c.backendStore, cr)
+	if err != nil {
+		return err
+	}
+
+	c.log.Info("Deleting user", "uid", uid(cr), "backends", len(clients))
+
+	g := new(errgroup.Group)
+	for _, cl := range clients {
+		cl := cl
+		g.Go(func() error {
+			err := cl.RemoveUser(ctx, cr.Spec.ForProvider.Tenant, uid(cr), false)
+			if rgwadmin.IsNotFound(err) {
+				return nil
+			}
+
+			return err
+		})
+	}
+
+	return errors.Wrap(g.Wait(), errDeleteUser)
+}
+
+func (c *external) createUser(ctx context.Context, cl *rgwadmin.Client, cr *v1alpha1.User, key rgwadmin.UserKey) error {
+	spec := userSpec(cr)
+	spec.AccessKey = key.AccessKey
+	spec.SecretKey = key.SecretKey
+	spec.Caps = formatCaps(cr.Spec.ForProvider.Caps)
+
+	if _, err := cl.CreateUser(ctx, spec); err != nil {
+		return err
+	}
+	if cr.Spec.ForProvider.Quota == nil {
+		return nil
+	}
+
+	return errors.Wrap(cl.SetUserQuota(ctx, cr.Spec.ForProvider.Tenant, uid(cr), desiredQuota(cr.Spec.ForProvider.Quota)), errUpdateQuota)
+}
+
+func (c *external) updateUser(ctx context.Context, cl *rgwadmin.Client, cr *v1alpha1.User, u *rgwadmin.User) error {
+	if _, err := cl.ModifyUser(ctx, userSpec(cr)); err != nil {
+		return err
+	}
+
+	add, remove := diffCaps(cr.Spec.ForProvider.Caps, u.Caps)
+	if remove != "" {
+		if err := cl.RemoveUserCaps(ctx, cr.Spec.ForProvider.Tenant, uid(cr), remove); err != nil {
+			return errors.Wrap(err, errUpdateCaps)
+		}
+	}
+	if add != "" {
+		if err := cl.AddUserCaps(ctx, cr.Spec.ForProvider.Tenant, uid(cr), add); err != nil {
+			return errors.Wrap(err, errUpdateCaps)
+		}
+	}
+
+	if q := cr.Spec.ForProvider.Quota; q != nil && !desiredQuota(q).Equal(u.UserQuota) {
+		return errors.Wrap(cl.SetUserQuota(ctx, cr.Spec.ForProvider.Tenant, uid(cr), desiredQuota(q)), errUpdateQuota)
+	}
+
+	return nil
+}
+
+// uid returns the uid of the user, defaulting to the name of the User.
+func uid(cr *v1alpha1.User) string {
+	if cr.Spec.ForProvider.UID != "" {
+		return cr.Spec.ForProvider.UID
+	}
+
+	return cr.Name
+}
+
+func userSpec(cr *v1alpha1.User) rgwadmin.UserSpec {
+	p := cr.Spec.ForProvider
+
+	return rgwadmin.UserSpec{
+		UserID:      uid(cr),
+		Tenant:      p.Tenant,
+		DisplayName: p.DisplayName,
+		Email:       p.Email,
+		MaxBuckets:  maxBuckets(p),
+		Suspended:   p.Suspended,
+		OpMask:      p.OpMask,
+	}
+}
+
+// publishedKey returns the key of the users that is published as connection
+// details, the one generated when the User was created. An empty key is
+// returned if it no longer exists.
+func publishedKey(cr *v1alpha1.User, users map[string]*rgwadmin.User) rgwadmin.UserKey {
+	keyID := accessKeyID(cr)
+	if keyID == "" {
+		return rgwadmin.UserKey{}
+	}
+
+	backendNames := make([]string, 0, len(users))
+	for name := range users {
+		backendNames = append(backendNames, name)
+	}
+	sort.Strings(backendNames)
+
+	for _, name := range backendNames {
+		for _, k := range users[name].Keys {
+			if k.AccessKey == keyID {
+				return k
+			}
+		}
+	}
+
+	return rgwadmin.UserKey{}
+}
+
+// accessKeyID returns the access key ID of the key generated when the User
+// was created. Users created before it was recorded as an annotation fall
+// back to the key ID in their status.
+func accessKeyID(cr *v1alpha1.User) string {
+	if id := cr.GetAnnotations()[v1alpha1.AnnotationKeyAccessKeyID]; id != "" {
+		return id
+	}
+
+	return cr.Status.AtProvider.AccessKeyID
+}
+
+// maxBuckets returns the maximum number of buckets of the user, taken from
+// its quota if set there.
+func maxBuckets(p v1alpha1.UserParameters) *int {
+	if p.Quota != nil && p.Quota.MaxBuckets != nil {
+		return p.Quota.MaxBuckets
+	}
+
+	return p.MaxBuckets
+}
+
+// desiredQuota returns the RGW quota for the supplied user quota. Unset
+// limits are unlimited.
+func desiredQuota(q *v1alpha1.UserQuota) rgwadmin.Quota {
+	out := rgwadmin.Quota{
+		Enabled:    true,
+		MaxSize:    rgwadmin.QuotaUnlimited,
+		MaxObjects: rgwadmin.QuotaUnlimited,
+	}
+	if q.Enabled != nil {
+		out.Enabled = *q.Enabled
+	}
+	if q.MaxSize != nil {
+		out.MaxSize = q.MaxSize.Value()
+	}
+	if q.MaxObjects != nil {
+		out.MaxObjects = *q.MaxObjects
+	}
+
+	return out
+}
+
+// backendStatuses returns the usage and quota of the users found on each
+// backend.
+func backendStatuses(users map[string]*rgwadmin.User) map[string]v1alpha1.BackendUserStatus {
+	out := make(map[string]v1alpha1.BackendUserStatus, len(users))
+	for backendName, u := range users {
+		st := v1alpha1.BackendUserStatus{
+			Quota: &v1alpha1.UserQuotaStatus{
+				Enabled:      u.UserQuota.Enabled,
+				MaxSizeBytes: u.UserQuota.MaxSize,
+				MaxObjects:   u.UserQuota.MaxObjects,
+				MaxBuckets:   u.MaxBuckets,
+			},
+		}
+		if u.Stats != nil {
+			st.SizeBytes = u.Stats.Size
+			st.Objects = u.Stats.NumObjects
+		}
+		out[backendName] = st
+	}
+
+	return out
+}
+
+func connectionDetails(key rgwadmin.UserKey) managed.ConnectionDetails {
+	if key.AccessKey == "" {
+		return managed.ConnectionDetails{}
+	}
+
+	return managed.ConnectionDetails{
+		v1alpha1.ConnectionDetailAccessKey: []byte(key.AccessKey),
+		v1alpha1.ConnectionDetailSecretKey: []byte(key.SecretKey),
+	}
+}
+
+// isUpToDate returns true if the user matches the supplied parameters. Nil
+// parameters are not managed and always match.
+func isUpToDate(p v1alpha1.UserParameters, u *rgwadmin.User) bool {
+	switch {
+	case p.DisplayName != u.DisplayName,
+		p.Email != nil && *p.Email != u.Email,
+		maxBuckets(p) != nil && *maxBuckets(p) != u.MaxBuckets,
+		p.Quota != nil && !desiredQuota(p.Quota).Equal(u.UserQuota),
+		p.Suspended != nil && *p.Suspended != (u.Suspended != 0),
+		p.OpMask != nil && normalizeOpMask(*p.OpMask) != normalizeOpMask(u.OpMask):
+		return false
+	}
+
+	add, remove := diffCaps(p.Caps, u.Caps)
+
+	return add == "" && remove == ""
+}
+
+// normalizeOpMask returns an op mask such as "write, read" in a canonical
+// form, as RGW reorders the operations.
+func normalizeOpMask(mask string) string {
+	ops := strings.FieldsFunc(mask, func(r rune) bool { return r == ',' || r == ' ' })
+	sort.Strings(ops)
+
+	return strings.Join(ops, ",")
+}
+
+// diffCaps returns the caps to add and remove, in the format
+// "users=read;buckets=*", for the actual caps of a user to match the desired
+// ones. Caps whose permission changed are removed and added again, as RGW
+// merges the permissions of added caps.
+func diffCaps(desired []v1alpha1.UserCap, actual []rgwadmin.UserCap) (add, remove string) {
+	want := make(map[string]string, len(desired))
+	for _, c := range desired {
+		want[c.Type] = c.Perm
+	}
+	have := make(map[string]string, len(actual))
+	for _, c := range actual {
+		have[c.Type] = c.Perm
+	}
+
+	toAdd := []v1alpha1.UserCap{}
+	for _, c := range desired {
+		if have[c.Type] != c.Perm {
+			toAdd = append(toAdd, c)
+		}
+	}
+	toRemove := []v1alpha1.UserCap{}
+	for _, c := range actual {
+		if perm, ok := want[c.Type]; !ok || perm != c.Perm {
+			toRemove = append(toRemove, v1alpha1.UserCap{Type: c.Type, Perm: c.Perm})
+		}
+	}
+
+	return formatCaps(toAdd), formatCaps(toRemove)
+}
+
+// formatCaps formats caps as "users=read;buckets=*", sorted by type.
+func formatCaps(caps []v1alpha1.UserCap) string {
+	out := make([]string, 0, len(caps))
+	for _, c := range caps {
+		out = append(out, c.Type+"="+c.Perm)
+	}
+	sort.Strings(out)
+
+	return strings.Join(out, ";")
+}
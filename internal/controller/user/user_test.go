@@ -0,0 +1,355 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin/fake"
+)
+
+// newBackendStore returns a BackendStore with a backend per supplied fake
+// server.
+func newBackendStore(t *testing.T, servers map[string]*fake.Server) *backendstore.BackendStore {
+	t.Helper()
+
+	s := backendstore.NewBackendStore()
+	for name, srv := range servers {
+		s.AddOrUpdateBackend(name, s3.New(s3.Options{}))
+		s.SetAdminClient(name, srv.NewClient())
+	}
+
+	return s
+}
+
+func newUser(pc string, p v1alpha1.UserParameters) *v1alpha1.User {
+	cr := &v1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "alice"}, Spec: v1alpha1.UserSpec{ForProvider: p}}
+	if pc != "" {
+		cr.SetProviderConfigReference(&xpv1.Reference{Name: pc})
+	}
+
+	return cr
+}
+
+// withKey returns the User with the supplied access key ID recorded as the
+// key generated when it was created.
+func withKey(cr *v1alpha1.User, accessKeyID string) *v1alpha1.User {
+	meta.AddAnnotations(cr, map[string]string{v1alpha1.AnnotationKeyAccessKeyID: accessKeyID})
+
+	return cr
+}
+
+func TestObserve(t *testing.T) {
+	t.Parallel()
+
+	type want struct {
+		o   managed.ExternalObservation
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		users  map[string][]rgwadmin.UserSpec
+		mg     resource.Managed
+		want   want
+	}{
+		"InvalidManagedResource": {
+			reason: "An error should be returned if the managed resource is not a User.",
+			want:   want{err: errors.New(errNotUser)},
+		},
+		"BackendNotStored": {
+			reason: "An error should be returned if the referenced backend is not stored.",
+			mg:     newUser("s3-backend-3", v1alpha1.UserParameters{}),
+			// Reported by adminops.Clients.
+			want: want{err: errors.New("s3 backend is not stored")},
+		},
+		"NotFound": {
+			reason: "The user should not exist if it is found on no backend.",
+			mg:     newUser("", v1alpha1.UserParameters{DisplayName: "Alice"}),
+			want:   want{o: managed.ExternalObservation{ResourceExists: false}},
+		},
+		"UpToDate": {
+			reason: "A user matching the parameters on every backend should be up to date and its key published.",
+			users: map[string][]rgwadmin.UserSpec{
+				"s3-backend-1": {{UserID: "alice", DisplayName: "Alice", AccessKey: "AK", SecretKey: "SK"}},
+				"s3-backend-2": {{UserID: "alice", DisplayName: "Alice", AccessKey: "AK", SecretKey: "SK"}},
+			},
+			mg: withKey(newUser("", v1alpha1.UserParameters{DisplayName: "Alice"}), "AK"),
+			want: want{o: managed.ExternalObservation{
+				ResourceExists:   true,
+				ResourceUpToDate: true,
+				ConnectionDetails: managed.ConnectionDetails{
+					v1alpha1.ConnectionDetailAccessKey: []byte("AK"),
+					v1alpha1.ConnectionDetailSecretKey: []byte("SK"),
+				},
+			}},
+		},
+		"MissingOnBackend": {
+			reason: "A user missing from an active backend should not be up to date.",
+			users: map[string][]rgwadmin.UserSpec{
+				"s3-backend-1": {{UserID: "alice", DisplayName: "Alice", AccessKey: "AK", SecretKey: "SK"}},
+			},
+			mg: withKey(newUser("", v1alpha1.UserParameters{DisplayName: "Alice"}), "AK"),
+			want: want{o: managed.ExternalObservation{
+				ResourceExists:   true,
+				ResourceUpToDate: false,
+				ConnectionDetails: managed.ConnectionDetails{
+					v1alpha1.ConnectionDetailAccessKey: []byte("AK"),
+					v1alpha1.ConnectionDetailSecretKey: []byte("SK"),
+				},
+			}},
+		},
+		"OnlyOtherKeys": {
+			reason: "Keys the User did not create, e.g. those of AccessKeys, should never be published.",
+			users: map[string][]rgwadmin.UserSpec{
+				"s3-backend-1": {{UserID: "alice", DisplayName: "Alice", AccessKey: "OTHER", SecretKey: "OTHER-SK"}},
+				"s3-backend-2": {{UserID: "alice", DisplayName: "Alice", AccessKey: "OTHER", SecretKey: "OTHER-SK"}},
+			},
+			mg: withKey(newUser("", v1alpha1.UserParameters{DisplayName: "Alice"}), "AK"),
+			want: want{o: managed.ExternalObservation{
+				ResourceExists:    true,
+				ResourceUpToDate:  true,
+				ConnectionDetails: managed.ConnectionDetails{},
+			}},
+		},
+		"SingleBackend": {
+			reason: "A user managed on a single backend should only be looked up on that backend.",
+			users: map[string][]rgwadmin.UserSpec{
+				"s3-backend-1": {{UserID: "alice", DisplayName: "Alice", AccessKey: "AK", SecretKey: "SK"}},
+			},
+			mg:   newUser("s3-backend-2", v1alpha1.UserParameters{DisplayName: "Alice"}),
+			want: want{o: managed.ExternalObservation{ResourceExists: false}},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			servers := map[string]*fake.Server{"s3-backend-1": fake.NewServer(), "s3-backend-2": fake.NewServer()}
+			for _, srv := range servers {
+				defer srv.Close()
+			}
+			s := newBackendStore(t, servers)
+			for backendName, specs := range tc.users {
+				for _, spec := range specs {
+					if _, err := s.GetAdminClient(backendName).CreateUser(context.Background(), spec); err != nil {
+						t.Fatalf("CreateUser(...): %v", err)
+					}
+				}
+			}
+
+			e := external{backendStore: s, log: logging.NewNopLogger()}
+			got, err := e.Observe(context.Background(), tc.mg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.o, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCreateAndUpdate(t *testing.T) {
+	t.Parallel()
+
+	servers := map[string]*fake.Server{"s3-backend-1": fake.NewServer(), "s3-backend-2": fake.NewServer()}
+	for _, srv := range servers {
+		defer srv.Close()
+	}
+	s := newBackendStore(t, servers)
+	e := external{backendStore: s, log: logging.NewNopLogger()}
+	maxObjects, maxBuckets := int64(100), 5
+
+	cr := newUser("", v1alpha1.UserParameters{
+		DisplayName: "Alice",
+		Caps:        []v1alpha1.UserCap{{Type: "users", Perm: "read"}},
+	})
+	created, err := e.Create(context.Background(), cr)
+	if err != nil {
+		t.Fatalf("e.Create(...): %v", err)
+	}
+	// Like the managed reconciler, only keep the annotations set by Create.
+	cr.Status = v1alpha1.UserStatus{}
+
+	// A backend added later gets the user with the same key on update.
+	servers["s3-backend-3"] = fake.NewServer()
+	defer servers["s3-backend-3"].Close()
+	s = newBackendStore(t, servers)
+	e = external{backendStore: s, log: logging.NewNopLogger()}
+
+	cr.Spec.ForProvider.Caps = []v1alpha1.UserCap{{Type: "buckets", Perm: "*"}}
+	cr.Spec.ForProvider.Quota = &v1alpha1.UserQuota{MaxObjects: &maxObjects, MaxBuckets: &maxBuckets}
+	updated, err := e.Update(context.Background(), cr)
+	if err != nil {
+		t.Fatalf("e.Update(...): %v", err)
+	}
+	if diff := cmp.Diff(created.ConnectionDetails, updated.ConnectionDetails); diff != "" {
+		t.Errorf("e.Update(...): -want connection details, +got connection details:\n%s", diff)
+	}
+
+	for name, srv := range servers {
+		u, ok := srv.GetUser("alice")
+		if !ok {
+			t.Errorf("%s: user was not created", name)
+
+			continue
+		}
+		if diff := cmp.Diff([]rgwadmin.UserCap{{Type: "buckets", Perm: "*"}}, u.Caps); diff != "" {
+			t.Errorf("%s: -want caps, +got caps:\n%s", name, diff)
+		}
+		wantQuota := rgwadmin.Quota{Enabled: true, MaxSize: rgwadmin.QuotaUnlimited, MaxObjects: maxObjects}
+		if diff := cmp.Diff(wantQuota, u.UserQuota); diff != "" {
+			t.Errorf("%s: -want quota, +got quota:\n%s", name, diff)
+		}
+		if diff := cmp.Diff(maxBuckets, u.MaxBuckets); diff != "" {
+			t.Errorf("%s: -want max buckets, +got max buckets:\n%s", name, diff)
+		}
+		if diff := cmp.Diff(string(created.ConnectionDetails[v1alpha1.ConnectionDetailAccessKey]), u.Keys[0].AccessKey); diff != "" {
+			t.Errorf("%s: -want access key, +got access key:\n%s", name, diff)
+		}
+	}
+}
+
+func TestIsUpToDate(t *testing.T) {
+	t.Parallel()
+
+	email := "alice@example.com"
+	suspended := true
+	opMask := "write, read"
+	maxBuckets, defaultMaxBuckets := 5, 1000
+
+	cases := map[string]struct {
+		reason string
+		p      v1alpha1.UserParameters
+		u      *rgwadmin.User
+		want   bool
+	}{
+		"UnsetParameters": {
+			reason: "Unset optional parameters should not be compared.",
+			p:      v1alpha1.UserParameters{DisplayName: "Alice"},
+			u:      &rgwadmin.User{DisplayName: "Alice", Email: "other@example.com", MaxBuckets: 1000},
+			want:   true,
+		},
+		"OpMaskOrder": {
+			reason: "Op masks listing the same operations in another order should match.",
+			p:      v1alpha1.UserParameters{DisplayName: "Alice", OpMask: &opMask},
+			u:      &rgwadmin.User{DisplayName: "Alice", OpMask: "read, write"},
+			want:   true,
+		},
+		"EmailDiffers": {
+			reason: "A different email should not be up to date.",
+			p:      v1alpha1.UserParameters{DisplayName: "Alice", Email: &email},
+			u:      &rgwadmin.User{DisplayName: "Alice"},
+			want:   false,
+		},
+		"Suspended": {
+			reason: "An active user that should be suspended should not be up to date.",
+			p:      v1alpha1.UserParameters{DisplayName: "Alice", Suspended: &suspended},
+			u:      &rgwadmin.User{DisplayName: "Alice", Suspended: 0},
+			want:   false,
+		},
+		"QuotaUnlimited": {
+			reason: "An enabled quota without limits should match the unlimited quota RGW reports.",
+			p:      v1alpha1.UserParameters{DisplayName: "Alice", Quota: &v1alpha1.UserQuota{}},
+			u:      &rgwadmin.User{DisplayName: "Alice", UserQuota: rgwadmin.Quota{Enabled: true, MaxSize: 0, MaxObjects: rgwadmin.QuotaUnlimited}},
+			want:   true,
+		},
+		"QuotaMaxBuckets": {
+			reason: "The max buckets of the quota should take precedence over maxBuckets.",
+			p:      v1alpha1.UserParameters{DisplayName: "Alice", MaxBuckets: &defaultMaxBuckets, Quota: &v1alpha1.UserQuota{MaxBuckets: &maxBuckets}},
+			u:      &rgwadmin.User{DisplayName: "Alice", MaxBuckets: 1000, UserQuota: rgwadmin.Quota{Enabled: true}},
+			want:   false,
+		},
+		"ExtraCaps": {
+			reason: "Caps that are not desired should not be up to date.",
+			p:      v1alpha1.UserParameters{DisplayName: "Alice"},
+			u:      &rgwadmin.User{DisplayName: "Alice", Caps: []rgwadmin.UserCap{{Type: "users", Perm: "read"}}},
+			want:   false,
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := isUpToDate(tc.p, tc.u)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nisUpToDate(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDiffCaps(t *testing.T) {
+	t.Parallel()
+
+	type want struct {
+		add    string
+		remove string
+	}
+
+	cases := map[string]struct {
+		reason  string
+		desired []v1alpha1.UserCap
+		actual  []rgwadmin.UserCap
+		want    want
+	}{
+		"Equal": {
+			reason:  "Nothing should change if the caps match.",
+			desired: []v1alpha1.UserCap{{Type: "users", Perm: "read"}},
+			actual:  []rgwadmin.UserCap{{Type: "users", Perm: "read"}},
+		},
+		"PermChanged": {
+			reason:  "A cap with a changed permission should be removed and added again.",
+			desired: []v1alpha1.UserCap{{Type: "users", Perm: "*"}, {Type: "buckets", Perm: "read"}},
+			actual:  []rgwadmin.UserCap{{Type: "users", Perm: "read"}, {Type: "usage", Perm: "read"}},
+			want: want{
+				add:    "buckets=read;users=*",
+				remove: "usage=read;users=read",
+			},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			add, remove := diffCaps(tc.desired, tc.actual)
+			if diff := cmp.Diff(tc.want, want{add: add, remove: remove}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\ndiffCaps(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
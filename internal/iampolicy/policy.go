@@ -0,0 +1,112 @@
+// Package iampolicy normalises IAM policy documents, so that documents that
+// only differ in formatting compare equal.
+package iampolicy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errUnescape = "cannot unescape policy document"
+	errParse    = "cannot parse policy document"
+)
+
+// Normalize returns the canonical form of the supplied policy document.
+// IAM APIs may return documents URL encoded, so encoded documents are
+// decoded first. Whitespace and key order are dropped, single element arrays
+// are replaced by their element and string arrays are sorted, as IAM treats
+// e.g. "Action": "s3:GetObject" and "Action": ["s3:GetObject"] the same.
+func Normalize(doc string) (string, error) {
+	doc = strings.TrimSpace(doc)
+	if doc == "" {
+		return "", nil
+	}
+
+	if !strings.HasPrefix(doc, "{") {
+		unescaped, err := url.PathUnescape(doc)
+		if err != nil {
+			return "", errors.Wrap(err, errUnescape)
+		}
+		doc = unescaped
+	}
+
+	d := json.NewDecoder(strings.NewReader(doc))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return "", errors.Wrap(err, errParse)
+	}
+
+	b := &bytes.Buffer{}
+	e := json.NewEncoder(b)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(normalize(v)); err != nil {
+		return "", errors.Wrap(err, errParse)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// Equal returns true if the supplied policy documents are equal once
+// normalised.
+func Equal(a, b string) (bool, error) {
+	na, err := Normalize(a)
+	if err != nil {
+		return false, err
+	}
+	nb, err := Normalize(b)
+	if err != nil {
+		return false, err
+	}
+
+	return na == nb, nil
+}
+
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalize(e)
+		}
+
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+		if len(v) == 1 {
+			return v[0]
+		}
+		if s, ok := asStrings(v); ok {
+			sort.Strings(s)
+			for i := range s {
+				v[i] = s[i]
+			}
+		}
+
+		return v
+	default:
+		return v
+	}
+}
+
+// asStrings returns the elements of the array if they are all strings.
+func asStrings(v []interface{}) ([]string, bool) {
+	s := make([]string, len(v))
+	for i, e := range v {
+		str, ok := e.(string)
+		if !ok {
+			return nil, false
+		}
+		s[i] = str
+	}
+
+	return s, true
+}
@@ -0,0 +1,93 @@
+package iampolicy
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEqual(t *testing.T) {
+	t.Parallel()
+
+	const policy = `{
+  "Version": "2012-10-17",
+  "Statement": [{
+    "Effect": "Allow",
+    "Principal": {"AWS": ["arn:aws:iam:::user/alice"]},
+    "Action": ["sts:AssumeRole"]
+  }]
+}`
+
+	cases := map[string]struct {
+		reason string
+		a      string
+		b      string
+		want   bool
+	}{
+		"Identical": {
+			reason: "Identical documents should be equal.",
+			a:      policy,
+			b:      policy,
+			want:   true,
+		},
+		"Formatting": {
+			reason: "Documents differing in whitespace and key order should be equal.",
+			a:      policy,
+			b:      `{"Statement":[{"Action":["sts:AssumeRole"],"Principal":{"AWS":["arn:aws:iam:::user/alice"]},"Effect":"Allow"}],"Version":"2012-10-17"}`,
+			want:   true,
+		},
+		"SingleElementArrays": {
+			reason: "Single element arrays should be equal to their element.",
+			a:      policy,
+			b:      `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam:::user/alice"},"Action":"sts:AssumeRole"}}`,
+			want:   true,
+		},
+		"StringArrayOrder": {
+			reason: "String arrays differing in order should be equal.",
+			a:      `{"Statement":[{"Action":["s3:GetObject","s3:PutObject"]}]}`,
+			b:      `{"Statement":[{"Action":["s3:PutObject","s3:GetObject"]}]}`,
+			want:   true,
+		},
+		"URLEncoded": {
+			reason: "URL encoded documents, as returned by IAM APIs, should be equal to their decoded form.",
+			a:      policy,
+			b:      `%7B%22Version%22%3A%222012-10-17%22%2C%22Statement%22%3A%5B%7B%22Effect%22%3A%22Allow%22%2C%22Principal%22%3A%7B%22AWS%22%3A%5B%22arn%3Aaws%3Aiam%3A%3A%3Auser%2Falice%22%5D%7D%2C%22Action%22%3A%5B%22sts%3AAssumeRole%22%5D%7D%5D%7D`,
+			want:   true,
+		},
+		"DifferentAction": {
+			reason: "Documents with different actions should not be equal.",
+			a:      policy,
+			b:      `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam:::user/alice"},"Action":"sts:AssumeRoleWithWebIdentity"}}`,
+			want:   false,
+		},
+		"Empty": {
+			reason: "Empty documents should be equal.",
+			a:      "",
+			b:      " ",
+			want:   true,
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Equal(tc.a, tc.b)
+			if err != nil {
+				t.Fatalf("\n%s\nEqual(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nEqual(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Normalize(`{"Version":`); err == nil {
+		t.Errorf("Normalize(...): expected an error for a truncated document")
+	}
+}
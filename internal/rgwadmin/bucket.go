@@ -0,0 +1,85 @@
+package rgwadmin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+const resourceBucket = "bucket"
+
+// BucketInfo is the information about a bucket returned by the Admin Ops
+// API.
+type BucketInfo struct {
+	Bucket        string                `json:"bucket"`
+	Tenant        string                `json:"tenant"`
+	ID            string                `json:"id"`
+	Owner         string                `json:"owner"`
+	NumShards     int                   `json:"num_shards"`
+	PlacementRule string                `json:"placement_rule"`
+	Usage         map[string]UsageStats `json:"usage"`
+	BucketQuota   Quota                 `json:"bucket_quota"`
+}
+
+// MainUsageCategory is the usage category of the objects stored in a
+// bucket.
+const MainUsageCategory = "rgw.main"
+
+// UsageStats is the storage used by a category of objects in a bucket, e.g.
+// "rgw.main".
+type UsageStats struct {
+	Size         int64 `json:"size"`
+	SizeActual   int64 `json:"size_actual"`
+	SizeKB       int64 `json:"size_kb"`
+	SizeKBActual int64 `json:"size_kb_actual"`
+	NumObjects   int64 `json:"num_objects"`
+}
+
+// bucketName returns the name of a bucket including its tenant, as expected
+// by the Admin Ops API.
+func bucketName(tenant, bucket string) string {
+	if tenant == "" {
+		return bucket
+	}
+
+	return tenant + "/" + bucket
+}
+
+// GetBucketInfo returns information about a bucket, including its usage if
+// stats is true.
+func (c *Client) GetBucketInfo(ctx context.Context, tenant, bucket string, stats bool) (*BucketInfo, error) {
+	q := url.Values{}
+	q.Set("bucket", bucketName(tenant, bucket))
+	if stats {
+		q.Set("stats", "true")
+	}
+
+	info := &BucketInfo{}
+	if err := c.do(ctx, http.MethodGet, resourceBucket, q, nil, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// LinkBucket links a bucket to the user with the supplied uid, making the
+// user its owner.
+func (c *Client) LinkBucket(ctx context.Context, tenant, bucket, bucketID, uid string) error {
+	q := url.Values{}
+	q.Set("bucket", bucketName(tenant, bucket))
+	q.Set("uid", QualifiedUID(tenant, uid))
+	if bucketID != "" {
+		q.Set("bucket-id", bucketID)
+	}
+
+	return c.do(ctx, http.MethodPut, resourceBucket, q, nil, nil)
+}
+
+// UnlinkBucket unlinks a bucket from the user with the supplied uid.
+func (c *Client) UnlinkBucket(ctx context.Context, tenant, bucket, uid string) error {
+	q := url.Values{}
+	q.Set("bucket", bucketName(tenant, bucket))
+	q.Set("uid", QualifiedUID(tenant, uid))
+
+	return c.do(ctx, http.MethodPost, resourceBucket, q, nil, nil)
+}
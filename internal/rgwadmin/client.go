@@ -0,0 +1,196 @@
+// Package rgwadmin implements a client for the Ceph RADOS Gateway (RGW) Admin
+// Ops REST API.
+package rgwadmin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Requests are signed like S3 requests, RGW ignores the region.
+	signingService = "s3"
+	defaultRegion  = "us-east-1"
+
+	defaultAdminPath = "admin"
+
+	errBuildRequest   = "cannot build admin ops request"
+	errRetrieveCreds  = "cannot retrieve credentials"
+	errSignRequest    = "cannot sign admin ops request"
+	errSendRequest    = "cannot send admin ops request"
+	errReadResponse   = "cannot read admin ops response"
+	errDecodeResponse = "cannot decode admin ops response"
+	errEncodeBody     = "cannot encode admin ops request body"
+)
+
+// An HTTPClient sends HTTP requests. Both *http.Client and the buildable
+// client of the AWS SDK satisfy it.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// A ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithHTTPClient sets the HTTP client used to send requests.
+func WithHTTPClient(c HTTPClient) ClientOption {
+	return func(cl *Client) {
+		cl.httpClient = c
+	}
+}
+
+// WithRegion sets the region requests are signed for.
+func WithRegion(region string) ClientOption {
+	return func(cl *Client) {
+		cl.region = region
+	}
+}
+
+// Client is a client for the RGW Admin Ops API of a single backend.
+type Client struct {
+	endpoint   *url.URL
+	creds      aws.CredentialsProvider
+	httpClient HTTPClient
+	signer     *v4.Signer
+	region     string
+}
+
+// NewClient returns a Client for the Admin Ops API served at the supplied
+// endpoint, e.g. "https://rgw.example.com". The API is expected under the
+// "admin" path unless the endpoint includes a path.
+func NewClient(endpoint string, creds aws.CredentialsProvider, o ...ClientOption) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse admin ops endpoint")
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = "/" + defaultAdminPath
+	}
+
+	c := &Client{
+		endpoint:   u,
+		creds:      creds,
+		httpClient: http.DefaultClient,
+		signer:     v4.NewSigner(),
+		region:     defaultRegion,
+	}
+
+	for _, opt := range o {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// An APIError is an error returned by the Admin Ops API.
+type APIError struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"Code"`
+	RequestID  string `json:"RequestId"`
+}
+
+func (e *APIError) Error() string {
+	return "admin ops request failed with status " + http.StatusText(e.StatusCode) + ": " + e.Code
+}
+
+// Error codes returned by the Admin Ops API.
+const (
+	ErrCodeNoSuchUser    = "NoSuchUser"
+	ErrCodeNoSuchBucket  = "NoSuchBucket"
+	ErrCodeNoSuchKey     = "NoSuchKey"
+	ErrCodeNoSuchSubUser = "NoSuchSubUser"
+)
+
+// IsNotFound returns true if the supplied error indicates that the requested
+// admin resource does not exist.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.Code {
+	case ErrCodeNoSuchUser, ErrCodeNoSuchBucket, ErrCodeNoSuchKey, ErrCodeNoSuchSubUser:
+		return true
+	}
+
+	return apiErr.StatusCode == http.StatusNotFound
+}
+
+// do sends a signed request for the supplied resource, e.g. "user", and
+// decodes a JSON response into out if it is not nil. A non-nil body is sent
+// JSON encoded.
+func (c *Client) do(ctx context.Context, method, resource string, query url.Values, body, out interface{}) error {
+	payload := []byte{}
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, errEncodeBody)
+		}
+		payload = b
+	}
+
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("format", "json")
+
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + resource
+	// The signer re-encodes the query canonically, sub-resource flags such
+	// as "quota" are sent as "quota=" which RGW accepts.
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(payload))
+	if err != nil {
+		return errors.Wrap(err, errBuildRequest)
+	}
+
+	creds, err := c.creds.Retrieve(ctx)
+	if err != nil {
+		return errors.Wrap(err, errRetrieveCreds)
+	}
+
+	hash := sha256.Sum256(payload)
+	payloadHash := hex.EncodeToString(hash[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if err := c.signer.SignHTTP(ctx, creds, req, payloadHash, signingService, c.region, time.Now()); err != nil {
+		return errors.Wrap(err, errSignRequest)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, errSendRequest)
+	}
+	defer resp.Body.Close() //nolint:errcheck // Nothing to do on error.
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, errReadResponse)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(data, apiErr)
+
+		return apiErr
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+
+	return errors.Wrap(json.Unmarshal(data, out), errDecodeResponse)
+}
@@ -0,0 +1,124 @@
+package rgwadmin_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin/fake"
+)
+
+func newClient(t *testing.T, srv *fake.Server, creds aws.CredentialsProvider) *rgwadmin.Client {
+	t.Helper()
+
+	c, err := rgwadmin.NewClient(srv.URL, creds, rgwadmin.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("NewClient(...): %v", err)
+	}
+
+	return c
+}
+
+func TestUser(t *testing.T) {
+	t.Parallel()
+
+	srv := fake.NewServer()
+	defer srv.Close()
+
+	c := newClient(t, srv, credentials.NewStaticCredentialsProvider("admin", "secret", ""))
+	ctx := context.Background()
+
+	if _, err := c.GetUser(ctx, "", "alice", false); !rgwadmin.IsNotFound(err) {
+		t.Errorf("GetUser(...) of a missing user: want not found error, got %v", err)
+	}
+
+	created, err := c.CreateUser(ctx, rgwadmin.UserSpec{
+		UserID:      "alice",
+		Tenant:      "team",
+		DisplayName: "Alice",
+		AccessKey:   "AKIA",
+		SecretKey:   "SECRET",
+		Caps:        "users=read",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+
+	maxBuckets := 5
+	suspended := true
+	if _, err := c.ModifyUser(ctx, rgwadmin.UserSpec{UserID: "alice", Tenant: "team", MaxBuckets: &maxBuckets, Suspended: &suspended}); err != nil {
+		t.Fatalf("ModifyUser(...): %v", err)
+	}
+
+	got, err := c.GetUser(ctx, "team", "alice", false)
+	if err != nil {
+		t.Fatalf("GetUser(...): %v", err)
+	}
+
+	want := &rgwadmin.User{
+		UserID:      "alice",
+		Tenant:      "team",
+		DisplayName: "Alice",
+		MaxBuckets:  5,
+		Suspended:   1,
+		Keys:        []rgwadmin.UserKey{{User: "team$alice", AccessKey: "AKIA", SecretKey: "SECRET"}},
+		Caps:        []rgwadmin.UserCap{{Type: "users", Perm: "read"}},
+	}
+	if diff := cmp.Diff(want.Keys, created.Keys); diff != "" {
+		t.Errorf("CreateUser(...): -want keys, +got keys:\n%s", diff)
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("GetUser(...): -want, +got:\n%s", diff)
+	}
+
+	if err := c.RemoveUser(ctx, "team", "alice", true); err != nil {
+		t.Fatalf("RemoveUser(...): %v", err)
+	}
+	if _, ok := srv.GetUser("team$alice"); ok {
+		t.Errorf("RemoveUser(...): user still exists")
+	}
+}
+
+func TestBucket(t *testing.T) {
+	t.Parallel()
+
+	srv := fake.NewServer()
+	defer srv.Close()
+	srv.AddBucket(rgwadmin.BucketInfo{Bucket: "photos", ID: "id-1", Owner: "admin", NumShards: 11})
+
+	c := newClient(t, srv, credentials.NewStaticCredentialsProvider("admin", "secret", ""))
+	ctx := context.Background()
+
+	if _, err := c.CreateUser(ctx, rgwadmin.UserSpec{UserID: "bob", DisplayName: "Bob"}); err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+	if err := c.LinkBucket(ctx, "", "photos", "id-1", "bob"); err != nil {
+		t.Fatalf("LinkBucket(...): %v", err)
+	}
+
+	got, err := c.GetBucketInfo(ctx, "", "photos", true)
+	if err != nil {
+		t.Fatalf("GetBucketInfo(...): %v", err)
+	}
+	want := &rgwadmin.BucketInfo{Bucket: "photos", ID: "id-1", Owner: "bob", NumShards: 11}
+	if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("GetBucketInfo(...): -want, +got:\n%s", diff)
+	}
+
+	keys, err := c.ListMetadata(ctx, rgwadmin.MetadataSectionBucket)
+	if err != nil {
+		t.Fatalf("ListMetadata(...): %v", err)
+	}
+	if diff := cmp.Diff([]string{"photos"}, keys); diff != "" {
+		t.Errorf("ListMetadata(...): -want, +got:\n%s", diff)
+	}
+
+	if _, err := c.GetBucketInfo(ctx, "", "missing", false); !rgwadmin.IsNotFound(err) {
+		t.Errorf("GetBucketInfo(...) of a missing bucket: want not found error, got %v", err)
+	}
+}
@@ -0,0 +1,579 @@
+// Package fake implements an in-memory RGW Admin Ops API server for tests.
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
+)
+
+const (
+	adminPath = "/admin/"
+
+	errCodeAccessDenied    = "AccessDenied"
+	errCodeInvalidArgument = "InvalidArgument"
+	errCodeUserExists      = "UserAlreadyExists"
+	errCodeKeyExists       = "KeyExists"
+)
+
+// Server is an in-memory RGW Admin Ops API server. Requests must be SigV4
+// signed but signatures are not verified.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	users   map[string]*rgwadmin.User
+	buckets map[string]*rgwadmin.BucketInfo
+	keySeq  int
+}
+
+// NewServer starts and returns a new Server. It must be closed by the caller.
+func NewServer() *Server {
+	s := &Server{
+		users:   map[string]*rgwadmin.User{},
+		buckets: map[string]*rgwadmin.BucketInfo{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// NewClient returns a client for the server.
+func (s *Server) NewClient() *rgwadmin.Client {
+	// The endpoint is a valid URL, so NewClient cannot fail.
+	cl, _ := rgwadmin.NewClient(s.URL, credentials.NewStaticCredentialsProvider("admin", "secret", ""), rgwadmin.WithHTTPClient(s.Client()))
+
+	return cl
+}
+
+// AddBucket adds a bucket to the server, as though it had been created
+// through the S3 API. The bucket name includes its tenant, if any, as
+// "tenant/bucket".
+func (s *Server) AddBucket(info rgwadmin.BucketInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buckets[bucketKey(info.Tenant, info.Bucket)] = &info
+}
+
+// GetUser returns a copy of the stored user with the supplied uid, which
+// includes its tenant as "tenant$uid" if it has one.
+func (s *Server) GetUser(uid string) (rgwadmin.User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[uid]
+	if !ok {
+		return rgwadmin.User{}, false
+	}
+
+	return *u, true
+}
+
+// GetBucket returns a copy of the stored bucket with the supplied name.
+func (s *Server) GetBucket(name string) (rgwadmin.BucketInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[name]
+	if !ok {
+		return rgwadmin.BucketInfo{}, false
+	}
+
+	return *b, true
+}
+
+func bucketKey(tenant, bucket string) string {
+	if tenant == "" {
+		return bucket
+	}
+
+	return tenant + "/" + bucket
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
+		writeError(w, http.StatusForbidden, errCodeAccessDenied)
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resource := strings.TrimPrefix(r.URL.Path, adminPath)
+	switch {
+	case resource == "user":
+		s.serveUser(w, r)
+	case resource == "bucket":
+		s.serveBucket(w, r)
+	case resource == "usage":
+		writeJSON(w, &rgwadmin.Usage{Entries: []rgwadmin.UsageEntry{}, Summary: []rgwadmin.UsageSummary{}})
+	case strings.HasPrefix(resource, "metadata/"):
+		s.serveMetadata(w, r, strings.TrimPrefix(resource, "metadata/"))
+	default:
+		writeError(w, http.StatusNotFound, errCodeInvalidArgument)
+	}
+}
+
+func (s *Server) serveUser(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	uid := q.Get("uid")
+	if uid == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidArgument)
+
+		return
+	}
+
+	u, exists := s.users[uid]
+	if q.Has("caps") {
+		s.serveCaps(w, r, u)
+
+		return
+	}
+	if q.Has("key") {
+		s.serveKey(w, r, u)
+
+		return
+	}
+	if q.Has("subuser") {
+		s.serveSubuser(w, r, u)
+
+		return
+	}
+	if q.Has("quota") {
+		s.serveUserQuota(w, r, u)
+
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchUser)
+
+			return
+		}
+		out := *u
+		if q.Get("stats") == "true" && out.Stats == nil {
+			out.Stats = &rgwadmin.UserStats{}
+		}
+		writeJSON(w, &out)
+	case http.MethodPut:
+		if exists {
+			writeError(w, http.StatusConflict, errCodeUserExists)
+
+			return
+		}
+		u = s.newUser(uid, q.Get("access-key"), q.Get("secret-key"))
+		applyUserQuery(u, q)
+		s.users[uid] = u
+		writeJSON(w, u)
+	case http.MethodPost:
+		if !exists {
+			writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchUser)
+
+			return
+		}
+		applyUserQuery(u, q)
+		writeJSON(w, u)
+	case http.MethodDelete:
+		if !exists {
+			writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchUser)
+
+			return
+		}
+		delete(s.users, uid)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errCodeInvalidArgument)
+	}
+}
+
+func (s *Server) serveCaps(w http.ResponseWriter, r *http.Request, u *rgwadmin.User) {
+	if u == nil {
+		writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchUser)
+
+		return
+	}
+
+	caps := parseCaps(r.URL.Query().Get("user-caps"))
+	switch r.Method {
+	case http.MethodPut:
+		for _, c := range caps {
+			u.Caps = removeCap(u.Caps, c.Type)
+			u.Caps = append(u.Caps, c)
+		}
+	case http.MethodDelete:
+		for _, c := range caps {
+			u.Caps = removeCap(u.Caps, c.Type)
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errCodeInvalidArgument)
+
+		return
+	}
+	writeJSON(w, u.Caps)
+}
+
+func (s *Server) serveKey(w http.ResponseWriter, r *http.Request, u *rgwadmin.User) {
+	if u == nil {
+		writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchUser)
+
+		return
+	}
+
+	q := r.URL.Query()
+	keyUser := q.Get("uid")
+	if sub := q.Get("subuser"); sub != "" {
+		keyUser = sub
+	}
+	accessKey := q.Get("access-key")
+
+	switch r.Method {
+	case http.MethodPut:
+		if q.Get("key-type") == rgwadmin.KeyTypeSwift {
+			u.SwiftKeys = append(removeSwiftKey(u.SwiftKeys, keyUser), rgwadmin.SwiftKey{User: keyUser, SecretKey: s.secretKey(q.Get("secret-key"))})
+			writeJSON(w, u.SwiftKeys)
+
+			return
+		}
+		if accessKey == "" {
+			s.keySeq++
+			accessKey = "ACCESSKEY" + strconv.Itoa(s.keySeq)
+		}
+		if s.keyExists(accessKey) {
+			writeError(w, http.StatusConflict, errCodeKeyExists)
+
+			return
+		}
+		u.Keys = append(u.Keys, rgwadmin.UserKey{User: keyUser, AccessKey: accessKey, SecretKey: s.secretKey(q.Get("secret-key"))})
+		writeJSON(w, u.Keys)
+	case http.MethodDelete:
+		if q.Get("key-type") == rgwadmin.KeyTypeSwift {
+			u.SwiftKeys = removeSwiftKey(u.SwiftKeys, keyUser)
+
+			return
+		}
+		for i, k := range u.Keys {
+			if k.AccessKey == accessKey {
+				u.Keys = append(u.Keys[:i], u.Keys[i+1:]...)
+
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchKey)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errCodeInvalidArgument)
+	}
+}
+
+func (s *Server) serveSubuser(w http.ResponseWriter, r *http.Request, u *rgwadmin.User) {
+	if u == nil {
+		writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchUser)
+
+		return
+	}
+
+	q := r.URL.Query()
+	id := q.Get("subuser")
+	idx := -1
+	for i, sub := range u.Subusers {
+		if sub.ID == id {
+			idx = i
+		}
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		if r.Method == http.MethodPost && idx < 0 {
+			writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchSubUser)
+
+			return
+		}
+		sub := rgwadmin.Subuser{ID: id, Permissions: rgwadmin.SubuserPermissions(q.Get("access"))}
+		if idx < 0 {
+			u.Subusers = append(u.Subusers, sub)
+		} else if q.Has("access") {
+			u.Subusers[idx] = sub
+		}
+		switch q.Get("key-type") {
+		case rgwadmin.KeyTypeSwift:
+			u.SwiftKeys = append(removeSwiftKey(u.SwiftKeys, id), rgwadmin.SwiftKey{User: id, SecretKey: s.secretKey(q.Get("secret-key"))})
+		case rgwadmin.KeyTypeS3:
+			accessKey := q.Get("access-key")
+			if accessKey == "" {
+				s.keySeq++
+				accessKey = "ACCESSKEY" + strconv.Itoa(s.keySeq)
+			}
+			u.Keys = append(u.Keys, rgwadmin.UserKey{User: id, AccessKey: accessKey, SecretKey: s.secretKey(q.Get("secret-key"))})
+		}
+		writeJSON(w, u.Subusers)
+	case http.MethodDelete:
+		if idx < 0 {
+			writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchSubUser)
+
+			return
+		}
+		u.Subusers = append(u.Subusers[:idx], u.Subusers[idx+1:]...)
+		u.SwiftKeys = removeSwiftKey(u.SwiftKeys, id)
+		keys := []rgwadmin.UserKey{}
+		for _, k := range u.Keys {
+			if k.User != id {
+				keys = append(keys, k)
+			}
+		}
+		u.Keys = keys
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errCodeInvalidArgument)
+	}
+}
+
+func (s *Server) serveUserQuota(w http.ResponseWriter, r *http.Request, u *rgwadmin.User) {
+	if u == nil {
+		writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchUser)
+
+		return
+	}
+	if r.URL.Query().Get("quota-type") != "user" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidArgument)
+
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, u.UserQuota)
+	case http.MethodPut:
+		q := rgwadmin.Quota{}
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidArgument)
+
+			return
+		}
+		u.UserQuota = q
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errCodeInvalidArgument)
+	}
+}
+
+func (s *Server) secretKey(secretKey string) string {
+	if secretKey != "" {
+		return secretKey
+	}
+	s.keySeq++
+
+	return "SECRETKEY" + strconv.Itoa(s.keySeq)
+}
+
+func (s *Server) keyExists(accessKey string) bool {
+	for _, u := range s.users {
+		for _, k := range u.Keys {
+			if k.AccessKey == accessKey {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func removeSwiftKey(keys []rgwadmin.SwiftKey, keyUser string) []rgwadmin.SwiftKey {
+	out := []rgwadmin.SwiftKey{}
+	for _, k := range keys {
+		if k.User != keyUser {
+			out = append(out, k)
+		}
+	}
+
+	return out
+}
+
+func removeCap(caps []rgwadmin.UserCap, capType string) []rgwadmin.UserCap {
+	out := []rgwadmin.UserCap{}
+	for _, c := range caps {
+		if c.Type != capType {
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+func (s *Server) newUser(uid, accessKey, secretKey string) *rgwadmin.User {
+	u := &rgwadmin.User{
+		UserID:     uid,
+		MaxBuckets: 1000,
+		UserQuota:  rgwadmin.Quota{MaxSize: rgwadmin.QuotaUnlimited, MaxObjects: rgwadmin.QuotaUnlimited},
+	}
+	if tenant, id, found := strings.Cut(uid, "$"); found {
+		u.Tenant, u.UserID = tenant, id
+	}
+	if accessKey == "" || secretKey == "" {
+		s.keySeq++
+		accessKey = "ACCESSKEY" + strconv.Itoa(s.keySeq)
+		secretKey = "SECRETKEY" + strconv.Itoa(s.keySeq)
+	}
+	u.Keys = []rgwadmin.UserKey{{User: uid, AccessKey: accessKey, SecretKey: secretKey}}
+
+	return u
+}
+
+func applyUserQuery(u *rgwadmin.User, q url.Values) {
+	get := func(k string) (string, bool) {
+		return q.Get(k), q.Has(k)
+	}
+	if v, ok := get("display-name"); ok {
+		u.DisplayName = v
+	}
+	if v, ok := get("email"); ok {
+		u.Email = v
+	}
+	if v, ok := get("max-buckets"); ok {
+		u.MaxBuckets, _ = strconv.Atoi(v)
+	}
+	if v, ok := get("suspended"); ok {
+		u.Suspended = 0
+		if b, _ := strconv.ParseBool(v); b {
+			u.Suspended = 1
+		}
+	}
+	if v, ok := get("op-mask"); ok {
+		u.OpMask = v
+	}
+	if v, ok := get("user-caps"); ok {
+		u.Caps = parseCaps(v)
+	}
+}
+
+// parseCaps parses caps in the format "users=read;buckets=*".
+func parseCaps(caps string) []rgwadmin.UserCap {
+	out := []rgwadmin.UserCap{}
+	for _, c := range strings.Split(caps, ";") {
+		t, p, found := strings.Cut(c, "=")
+		if !found {
+			continue
+		}
+		out = append(out, rgwadmin.UserCap{Type: strings.TrimSpace(t), Perm: strings.TrimSpace(p)})
+	}
+
+	return out
+}
+
+func (s *Server) serveBucket(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	b, exists := s.buckets[q.Get("bucket")]
+	if !exists {
+		writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchBucket)
+
+		return
+	}
+
+	if q.Has("quota") {
+		s.serveBucketQuota(w, r, b)
+
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, b)
+	case http.MethodPut:
+		// Link the bucket to a user.
+		if _, ok := s.users[q.Get("uid")]; !ok {
+			writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchUser)
+
+			return
+		}
+		b.Owner = q.Get("uid")
+	case http.MethodPost:
+		// Unlink the bucket from a user.
+		if b.Owner == q.Get("uid") {
+			b.Owner = ""
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errCodeInvalidArgument)
+	}
+}
+
+func (s *Server) serveBucketQuota(w http.ResponseWriter, r *http.Request, b *rgwadmin.BucketInfo) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, errCodeInvalidArgument)
+
+		return
+	}
+	if b.Owner != r.URL.Query().Get("uid") {
+		writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchBucket)
+
+		return
+	}
+
+	q := rgwadmin.Quota{}
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidArgument)
+
+		return
+	}
+	q.MaxSizeKB = q.MaxSize / 1024
+	if q.MaxSize < 0 {
+		q.MaxSizeKB = 0
+	}
+	b.BucketQuota = q
+}
+
+func (s *Server) serveMetadata(w http.ResponseWriter, r *http.Request, section string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errCodeInvalidArgument)
+
+		return
+	}
+
+	keys := []string{}
+	switch section {
+	case rgwadmin.MetadataSectionUser:
+		for k := range s.users {
+			keys = append(keys, k)
+		}
+	case rgwadmin.MetadataSectionBucket:
+		for k := range s.buckets {
+			keys = append(keys, k)
+		}
+	default:
+		writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchKey)
+
+		return
+	}
+	sort.Strings(keys)
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeJSON(w, keys)
+
+		return
+	}
+	if i := sort.SearchStrings(keys, key); i == len(keys) || keys[i] != key {
+		writeError(w, http.StatusNotFound, rgwadmin.ErrCodeNoSuchKey)
+
+		return
+	}
+	writeJSON(w, map[string]string{"key": key})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&rgwadmin.APIError{Code: code})
+}
@@ -0,0 +1,132 @@
+package rgwadmin
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	accessKeyLength = 20
+	secretKeyLength = 40
+
+	accessKeyChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	secretKeyChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+	errGenerateKey = "cannot generate key"
+)
+
+// GenerateKeyPair returns a random S3 access and secret key in the format
+// generated by RGW. Generating keys client side allows the same key pair to
+// be created on several backends.
+func GenerateKeyPair() (accessKey, secretKey string, err error) {
+	accessKey, err = randomString(accessKeyLength, accessKeyChars)
+	if err != nil {
+		return "", "", errors.Wrap(err, errGenerateKey)
+	}
+
+	secretKey, err = GenerateSecretKey()
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessKey, secretKey, nil
+}
+
+// GenerateSecretKey returns a random secret key, as used by S3 and Swift
+// keys, in the format generated by RGW.
+func GenerateSecretKey() (string, error) {
+	secretKey, err := randomString(secretKeyLength, secretKeyChars)
+
+	return secretKey, errors.Wrap(err, errGenerateKey)
+}
+
+func randomString(length int, chars string) (string, error) {
+	b := make([]byte, length)
+	max := big.NewInt(int64(len(chars)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = chars[n.Int64()]
+	}
+
+	return string(b), nil
+}
+
+// Key types.
+const (
+	KeyTypeS3    = "s3"
+	KeyTypeSwift = "swift"
+)
+
+// KeySpec identifies a key of a user or subuser.
+type KeySpec struct {
+	Tenant string
+	UserID string
+	// Subuser is the name of the subuser, without the uid prefix, the key
+	// belongs to. The key belongs to the user itself if it is empty.
+	Subuser string
+	// KeyType is either s3 or swift. Defaults to s3.
+	KeyType   string
+	AccessKey string
+	SecretKey string
+}
+
+// KeyUser returns the name of the user or subuser a key belongs to, as it
+// appears in the keys of a User.
+func (s KeySpec) KeyUser() string {
+	uid := QualifiedUID(s.Tenant, s.UserID)
+	if s.Subuser == "" {
+		return uid
+	}
+
+	return uid + ":" + s.Subuser
+}
+
+func (s KeySpec) query() url.Values {
+	q := url.Values{}
+	q.Set("key", "")
+	q.Set("uid", QualifiedUID(s.Tenant, s.UserID))
+	if s.Subuser != "" {
+		q.Set("subuser", s.KeyUser())
+	}
+	keyType := s.KeyType
+	if keyType == "" {
+		keyType = KeyTypeS3
+	}
+	q.Set("key-type", keyType)
+	if s.AccessKey != "" {
+		q.Set("access-key", s.AccessKey)
+	}
+
+	return q
+}
+
+// CreateKey creates a key. A key is generated unless both the access and
+// secret key are supplied. The S3 keys of the user are returned.
+func (c *Client) CreateKey(ctx context.Context, spec KeySpec) ([]UserKey, error) {
+	q := spec.query()
+	if spec.SecretKey != "" {
+		q.Set("secret-key", spec.SecretKey)
+	} else {
+		q.Set("generate-key", "true")
+	}
+
+	keys := []UserKey{}
+	if err := c.do(ctx, http.MethodPut, resourceUser, q, nil, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// RemoveKey removes the key with the access key of the supplied spec.
+func (c *Client) RemoveKey(ctx context.Context, spec KeySpec) error {
+	return c.do(ctx, http.MethodDelete, resourceUser, spec.query(), nil, nil)
+}
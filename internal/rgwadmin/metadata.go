@@ -0,0 +1,35 @@
+package rgwadmin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+const resourceMetadata = "metadata"
+
+// Metadata sections.
+const (
+	MetadataSectionUser   = "user"
+	MetadataSectionBucket = "bucket"
+)
+
+// ListMetadata returns the keys of the supplied metadata section, e.g. the
+// names of all buckets for the "bucket" section.
+func (c *Client) ListMetadata(ctx context.Context, section string) ([]string, error) {
+	keys := []string{}
+	if err := c.do(ctx, http.MethodGet, resourceMetadata+"/"+section, nil, nil, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// GetMetadata decodes the metadata entry with the supplied key of a metadata
+// section into out.
+func (c *Client) GetMetadata(ctx context.Context, section, key string, out interface{}) error {
+	q := url.Values{}
+	q.Set("key", key)
+
+	return c.do(ctx, http.MethodGet, resourceMetadata+"/"+section, q, nil, out)
+}
@@ -0,0 +1,68 @@
+package rgwadmin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// QuotaUnlimited is the value of a quota limit that is not enforced.
+const QuotaUnlimited = -1
+
+// Quota is a bucket or user quota.
+type Quota struct {
+	Enabled    bool  `json:"enabled"`
+	CheckOnRaw bool  `json:"check_on_raw"`
+	MaxSize    int64 `json:"max_size"`
+	MaxSizeKB  int64 `json:"max_size_kb"`
+	MaxObjects int64 `json:"max_objects"`
+}
+
+// Equal returns true if both quotas enforce the same limits. RGW reports
+// unlimited limits as either -1 or 0 depending on the version, both are
+// treated as unlimited.
+func (q Quota) Equal(other Quota) bool {
+	normalize := func(v int64) int64 {
+		if v <= 0 {
+			return QuotaUnlimited
+		}
+
+		return v
+	}
+
+	return q.Enabled == other.Enabled &&
+		normalize(q.MaxSize) == normalize(other.MaxSize) &&
+		normalize(q.MaxObjects) == normalize(other.MaxObjects)
+}
+
+// SetBucketQuota sets the quota of a bucket. The uid is the owner of the
+// bucket as returned in its BucketInfo, including any tenant.
+func (c *Client) SetBucketQuota(ctx context.Context, tenant, bucket, uid string, q Quota) error {
+	query := url.Values{}
+	query.Set("quota", "")
+	query.Set("bucket", bucketName(tenant, bucket))
+	query.Set("uid", uid)
+
+	return c.do(ctx, http.MethodPut, resourceBucket, query, quotaBody(q), nil)
+}
+
+// SetUserQuota sets the quota of a user, which limits the storage used by
+// all buckets of the user combined.
+func (c *Client) SetUserQuota(ctx context.Context, tenant, uid string, q Quota) error {
+	query := url.Values{}
+	query.Set("quota", "")
+	query.Set("uid", QualifiedUID(tenant, uid))
+	query.Set("quota-type", "user")
+
+	return c.do(ctx, http.MethodPut, resourceUser, query, quotaBody(q), nil)
+}
+
+// quotaBody returns the request body setting a quota. The size is sent in
+// bytes only, as RGW prefers max_size_kb over max_size otherwise.
+func quotaBody(q Quota) map[string]interface{} {
+	return map[string]interface{}{
+		"enabled":     q.Enabled,
+		"max_size":    q.MaxSize,
+		"max_objects": q.MaxObjects,
+	}
+}
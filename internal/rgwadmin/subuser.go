@@ -0,0 +1,103 @@
+package rgwadmin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Subuser access levels.
+const (
+	SubuserAccessRead      = "read"
+	SubuserAccessWrite     = "write"
+	SubuserAccessReadWrite = "readwrite"
+	SubuserAccessFull      = "full"
+)
+
+// subuserPermissions maps subuser access levels to the permissions RGW
+// reports for them.
+var subuserPermissions = map[string]string{
+	SubuserAccessRead:      "read",
+	SubuserAccessWrite:     "write",
+	SubuserAccessReadWrite: "read-write",
+	SubuserAccessFull:      "full-control",
+}
+
+// SubuserPermissions returns the permissions RGW reports for a subuser with
+// the supplied access level.
+func SubuserPermissions(access string) string {
+	if p, ok := subuserPermissions[access]; ok {
+		return p
+	}
+
+	return access
+}
+
+// SubuserSpec are the settable fields of a subuser.
+type SubuserSpec struct {
+	Tenant string
+	UserID string
+	// Subuser is the name of the subuser, without the uid prefix.
+	Subuser string
+	// Access is one of read, write, readwrite or full.
+	Access string
+	// KeyType of the key created along with the subuser, either s3 or
+	// swift. No key is created if it is empty.
+	KeyType   string
+	AccessKey string
+	SecretKey string
+}
+
+// ID returns the ID of the subuser, in the format "uid:subuser".
+func (s SubuserSpec) ID() string {
+	return KeySpec{Tenant: s.Tenant, UserID: s.UserID, Subuser: s.Subuser}.KeyUser()
+}
+
+func (s SubuserSpec) query() url.Values {
+	q := url.Values{}
+	q.Set("subuser", s.ID())
+	q.Set("uid", QualifiedUID(s.Tenant, s.UserID))
+	if s.Access != "" {
+		q.Set("access", s.Access)
+	}
+
+	return q
+}
+
+// CreateSubuser creates a subuser. A key of the supplied key type is created
+// along with it, using the supplied keys if set. The subusers of the user are
+// returned.
+func (c *Client) CreateSubuser(ctx context.Context, spec SubuserSpec) ([]Subuser, error) {
+	q := spec.query()
+	if spec.KeyType != "" {
+		q.Set("key-type", spec.KeyType)
+		if spec.AccessKey != "" {
+			q.Set("access-key", spec.AccessKey)
+		}
+		if spec.SecretKey != "" {
+			q.Set("secret-key", spec.SecretKey)
+		} else {
+			q.Set("generate-secret", "true")
+		}
+	}
+
+	subusers := []Subuser{}
+	if err := c.do(ctx, http.MethodPut, resourceUser, q, nil, &subusers); err != nil {
+		return nil, err
+	}
+
+	return subusers, nil
+}
+
+// ModifySubuser modifies the access level of a subuser.
+func (c *Client) ModifySubuser(ctx context.Context, spec SubuserSpec) error {
+	return c.do(ctx, http.MethodPost, resourceUser, spec.query(), nil, nil)
+}
+
+// RemoveSubuser removes a subuser along with its keys.
+func (c *Client) RemoveSubuser(ctx context.Context, spec SubuserSpec) error {
+	q := spec.query()
+	q.Set("purge-keys", "true")
+
+	return c.do(ctx, http.MethodDelete, resourceUser, q, nil, nil)
+}
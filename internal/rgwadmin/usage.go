@@ -0,0 +1,80 @@
+package rgwadmin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	resourceUsage = "usage"
+
+	usageTimeFormat = "2006-01-02 15:04:05"
+)
+
+// Usage is the bandwidth usage reported by the Admin Ops API.
+type Usage struct {
+	Entries []UsageEntry   `json:"entries"`
+	Summary []UsageSummary `json:"summary"`
+}
+
+// UsageEntry is the usage of the buckets of a single user.
+type UsageEntry struct {
+	User    string        `json:"user"`
+	Buckets []UsageBucket `json:"buckets"`
+}
+
+// UsageBucket is the usage of a single bucket.
+type UsageBucket struct {
+	Bucket     string          `json:"bucket"`
+	Time       string          `json:"time"`
+	Epoch      int64           `json:"epoch"`
+	Owner      string          `json:"owner"`
+	Categories []UsageCategory `json:"categories"`
+}
+
+// UsageSummary is the total usage of a single user.
+type UsageSummary struct {
+	User       string          `json:"user"`
+	Categories []UsageCategory `json:"categories"`
+	Total      UsageCounters   `json:"total"`
+}
+
+// UsageCategory is the usage of a category of operations, e.g. "put_obj".
+type UsageCategory struct {
+	Category string `json:"category"`
+	UsageCounters
+}
+
+// UsageCounters are the counters reported for usage.
+type UsageCounters struct {
+	BytesSent     int64 `json:"bytes_sent"`
+	BytesReceived int64 `json:"bytes_received"`
+	Ops           int64 `json:"ops"`
+	SuccessfulOps int64 `json:"successful_ops"`
+}
+
+// GetUsage returns the usage of the user with the supplied uid, or of all
+// users if uid is empty, between start and end. Zero times are not sent.
+func (c *Client) GetUsage(ctx context.Context, uid string, start, end time.Time) (*Usage, error) {
+	q := url.Values{}
+	if uid != "" {
+		q.Set("uid", uid)
+	}
+	if !start.IsZero() {
+		q.Set("start", start.UTC().Format(usageTimeFormat))
+	}
+	if !end.IsZero() {
+		q.Set("end", end.UTC().Format(usageTimeFormat))
+	}
+	q.Set("show-entries", "true")
+	q.Set("show-summary", "true")
+
+	u := &Usage{}
+	if err := c.do(ctx, http.MethodGet, resourceUsage, q, nil, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
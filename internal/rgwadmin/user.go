@@ -0,0 +1,190 @@
+package rgwadmin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const resourceUser = "user"
+
+// User is an RGW user as returned by the Admin Ops API.
+type User struct {
+	UserID      string     `json:"user_id"`
+	DisplayName string     `json:"display_name"`
+	Email       string     `json:"email,omitempty"`
+	Tenant      string     `json:"tenant,omitempty"`
+	Suspended   int        `json:"suspended"`
+	MaxBuckets  int        `json:"max_buckets"`
+	OpMask      string     `json:"op_mask,omitempty"`
+	Keys        []UserKey  `json:"keys,omitempty"`
+	SwiftKeys   []SwiftKey `json:"swift_keys,omitempty"`
+	Subusers    []Subuser  `json:"subusers,omitempty"`
+	Caps        []UserCap  `json:"caps,omitempty"`
+	UserQuota   Quota      `json:"user_quota"`
+	Stats       *UserStats `json:"stats,omitempty"`
+}
+
+// UserKey is an S3 key of a user or subuser.
+type UserKey struct {
+	User      string `json:"user"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+}
+
+// SwiftKey is a Swift key of a subuser.
+type SwiftKey struct {
+	User      string `json:"user"`
+	SecretKey string `json:"secret_key"`
+}
+
+// Subuser is a subuser of a user.
+type Subuser struct {
+	ID          string `json:"id"`
+	Permissions string `json:"permissions"`
+}
+
+// UserCap is an administrative capability of a user, e.g. "users" with
+// permission "read".
+type UserCap struct {
+	Type string `json:"type"`
+	Perm string `json:"perm"`
+}
+
+// UserStats is the storage used by a user.
+type UserStats struct {
+	Size       int64 `json:"size"`
+	SizeActual int64 `json:"size_actual"`
+	NumObjects int64 `json:"num_objects"`
+}
+
+// UserSpec are the settable fields of a user. Nil fields are left unchanged
+// when modifying a user.
+type UserSpec struct {
+	UserID      string
+	DisplayName string
+	Email       *string
+	Tenant      string
+	MaxBuckets  *int
+	Suspended   *bool
+	OpMask      *string
+	// AccessKey and SecretKey set the initial S3 key of a new user. A key
+	// is generated if they are empty.
+	AccessKey string
+	SecretKey string
+	// Caps in the format "users=read;buckets=*", only used on creation.
+	Caps string
+}
+
+// QualifiedUID returns the uid of a user including its tenant, as expected
+// by the Admin Ops API.
+func QualifiedUID(tenant, uid string) string {
+	if tenant == "" {
+		return uid
+	}
+
+	return tenant + "$" + uid
+}
+
+func (s UserSpec) query(create bool) url.Values {
+	q := url.Values{}
+	q.Set("uid", QualifiedUID(s.Tenant, s.UserID))
+	if s.DisplayName != "" {
+		q.Set("display-name", s.DisplayName)
+	}
+	if s.Email != nil {
+		q.Set("email", *s.Email)
+	}
+	if s.MaxBuckets != nil {
+		q.Set("max-buckets", strconv.Itoa(*s.MaxBuckets))
+	}
+	if s.Suspended != nil {
+		q.Set("suspended", strconv.FormatBool(*s.Suspended))
+	}
+	if s.OpMask != nil {
+		q.Set("op-mask", *s.OpMask)
+	}
+	if !create {
+		return q
+	}
+	if s.AccessKey != "" && s.SecretKey != "" {
+		q.Set("access-key", s.AccessKey)
+		q.Set("secret-key", s.SecretKey)
+		q.Set("generate-key", "false")
+	}
+	if s.Caps != "" {
+		q.Set("user-caps", s.Caps)
+	}
+
+	return q
+}
+
+// GetUser returns the user with the supplied uid, including its storage
+// stats if requested.
+func (c *Client) GetUser(ctx context.Context, tenant, uid string, stats bool) (*User, error) {
+	q := url.Values{}
+	q.Set("uid", QualifiedUID(tenant, uid))
+	if stats {
+		q.Set("stats", "true")
+	}
+
+	u := &User{}
+	if err := c.do(ctx, http.MethodGet, resourceUser, q, nil, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// CreateUser creates a user.
+func (c *Client) CreateUser(ctx context.Context, spec UserSpec) (*User, error) {
+	u := &User{}
+	if err := c.do(ctx, http.MethodPut, resourceUser, spec.query(true), nil, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// ModifyUser modifies the non-nil fields of a user.
+func (c *Client) ModifyUser(ctx context.Context, spec UserSpec) (*User, error) {
+	u := &User{}
+	if err := c.do(ctx, http.MethodPost, resourceUser, spec.query(false), nil, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// RemoveUser removes a user. The user's buckets and objects are removed too
+// if purgeData is true.
+func (c *Client) RemoveUser(ctx context.Context, tenant, uid string, purgeData bool) error {
+	q := url.Values{}
+	q.Set("uid", QualifiedUID(tenant, uid))
+	if purgeData {
+		q.Set("purge-data", "true")
+	}
+
+	return c.do(ctx, http.MethodDelete, resourceUser, q, nil, nil)
+}
+
+// AddUserCaps adds caps in the format "users=read;buckets=*" to a user.
+func (c *Client) AddUserCaps(ctx context.Context, tenant, uid, caps string) error {
+	return c.do(ctx, http.MethodPut, resourceUser, capsQuery(tenant, uid, caps), nil, nil)
+}
+
+// RemoveUserCaps removes caps in the format "users=read;buckets=*" from a
+// user.
+func (c *Client) RemoveUserCaps(ctx context.Context, tenant, uid, caps string) error {
+	return c.do(ctx, http.MethodDelete, resourceUser, capsQuery(tenant, uid, caps), nil, nil)
+}
+
+func capsQuery(tenant, uid, caps string) url.Values {
+	q := url.Values{}
+	q.Set("caps", "")
+	q.Set("uid", QualifiedUID(tenant, uid))
+	q.Set("user-caps", caps)
+
+	return q
+}
@@ -0,0 +1,68 @@
+package s3
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/rgwadmin"
+)
+
+const (
+	errLoadDefaultCreds = "cannot load default credentials"
+	errGetAdminCreds    = "cannot get admin credentials"
+)
+
+// GetAdminCredentialsProvider returns the credentials provider of the Admin
+// Ops API of a backend. The credentials referenced by the admin config take
+// precedence over the supplied credentials of the backend.
+func GetAdminCredentialsProvider(ctx context.Context, kube client.Client, admin *apisv1alpha1.AdminConfig, creds aws.CredentialsProvider) (aws.CredentialsProvider, error) {
+	if admin != nil && admin.CredentialsSecretRef != nil {
+		adminCreds, err := secretCredentials(ctx, kube, &xpv1.SecretKeySelector{SecretReference: *admin.CredentialsSecretRef})
+
+		return adminCreds, errors.Wrap(err, errGetAdminCreds)
+	}
+
+	return creds, nil
+}
+
+// NewAdminClient returns an Admin Ops API client for the backend described by
+// the supplied ProviderConfigSpec. It shares the HTTP settings of the S3
+// client. A nil credentials provider means the default credential chain of
+// the SDK is used.
+func NewAdminClient(ctx context.Context, creds aws.CredentialsProvider, pcSpec *apisv1alpha1.ProviderConfigSpec, o ...ClientOption) (*rgwadmin.Client, error) {
+	opts := &clientOptions{}
+	for _, opt := range o {
+		opt(opts)
+	}
+
+	if creds == nil {
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, errLoadDefaultCreds)
+		}
+		creds = cfg.Credentials
+	}
+
+	endpoint := strings.TrimSuffix(resolveHostBase(pcSpec.HostBase, aws.ToBool(pcSpec.UseHTTPS)), "/")
+	if pcSpec.Admin != nil && pcSpec.Admin.Endpoint != "" {
+		endpoint = resolveHostBase(pcSpec.Admin.Endpoint, aws.ToBool(pcSpec.UseHTTPS))
+	}
+
+	region := defaultRegion
+	if pcSpec.Region != "" {
+		region = pcSpec.Region
+	}
+
+	return rgwadmin.NewClient(endpoint, cachedCredentials(creds),
+		rgwadmin.WithHTTPClient(newHTTPClient(pcSpec, opts)),
+		rgwadmin.WithRegion(region),
+	)
+}
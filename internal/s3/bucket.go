@@ -1,17 +1,19 @@
 package s3
 
 import (
+	"strings"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
 )
 
-func BucketToCreateBucketInput(bucket *v1alpha1.Bucket) *s3.CreateBucketInput {
+func BucketToCreateBucketInput(bucket *v1alpha1.Bucket, bucketName string) *s3.CreateBucketInput {
 	createBucketInput := &s3.CreateBucketInput{
 
 		ACL:                        s3types.BucketCannedACL(aws.ToString(bucket.Spec.ForProvider.ACL)),
-		Bucket:                     aws.String(bucket.Name),
+		Bucket:                     aws.String(bucketName),
 		GrantFullControl:           bucket.Spec.ForProvider.GrantFullControl,
 		GrantRead:                  bucket.Spec.ForProvider.GrantRead,
 		GrantReadACP:               bucket.Spec.ForProvider.GrantReadACP,
@@ -21,11 +23,35 @@ func BucketToCreateBucketInput(bucket *v1alpha1.Bucket) *s3.CreateBucketInput {
 		ObjectOwnership:            s3types.ObjectOwnership(aws.ToString(bucket.Spec.ForProvider.ObjectOwnership)),
 	}
 
-	if bucket.Spec.ForProvider.LocationConstraint != "" {
+	if constraint := LocationConstraint(bucket.Spec.ForProvider); constraint != "" {
 		createBucketInput.CreateBucketConfiguration = &s3types.CreateBucketConfiguration{
-			LocationConstraint: s3types.BucketLocationConstraint(bucket.Spec.ForProvider.LocationConstraint),
+			LocationConstraint: s3types.BucketLocationConstraint(constraint),
 		}
 	}
 
 	return createBucketInput
 }
+
+// LocationConstraint returns the location constraint a bucket with the
+// supplied parameters is created with. RGW accepts constraints of the form
+// "zonegroup:placement-id/storage-class", where an empty zonegroup selects
+// the zonegroup of the backend.
+func LocationConstraint(p v1alpha1.BucketParameters) string {
+	if p.PlacementTarget == "" {
+		return p.LocationConstraint
+	}
+
+	zonegroup, _, _ := strings.Cut(p.LocationConstraint, ":")
+
+	return zonegroup + ":" + PlacementRule(p)
+}
+
+// PlacementRule returns the placement rule of a bucket with the supplied
+// parameters, as "placement-id" or "placement-id/storage-class".
+func PlacementRule(p v1alpha1.BucketParameters) string {
+	if p.DefaultStorageClass == "" {
+		return p.PlacementTarget
+	}
+
+	return p.PlacementTarget + "/" + p.DefaultStorageClass
+}
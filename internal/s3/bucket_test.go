@@ -0,0 +1,52 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/provider-ceph/apis/provider-ceph/v1alpha1"
+)
+
+func TestLocationConstraint(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		reason string
+		p      v1alpha1.BucketParameters
+		want   string
+	}{
+		"NoPlacement": {
+			reason: "The location constraint should be used as is without a placement target.",
+			p:      v1alpha1.BucketParameters{LocationConstraint: "eu"},
+			want:   "eu",
+		},
+		"PlacementTarget": {
+			reason: "The placement target should be selected in the zonegroup of the backend.",
+			p:      v1alpha1.BucketParameters{PlacementTarget: "fast-placement"},
+			want:   ":fast-placement",
+		},
+		"Zonegroup": {
+			reason: "The placement target should be combined with the zonegroup of the location constraint.",
+			p:      v1alpha1.BucketParameters{LocationConstraint: "eu:default-placement", PlacementTarget: "fast-placement"},
+			want:   "eu:fast-placement",
+		},
+		"StorageClass": {
+			reason: "The default storage class should be appended to the placement target.",
+			p:      v1alpha1.BucketParameters{LocationConstraint: "eu", PlacementTarget: "fast-placement", DefaultStorageClass: "COLD"},
+			want:   "eu:fast-placement/COLD",
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := LocationConstraint(tc.p)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nLocationConstraint(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
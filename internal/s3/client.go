@@ -2,12 +2,18 @@ package s3
 
 import (
 	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
 )
@@ -19,8 +25,48 @@ const (
 	secretKey = "secret_key"
 )
 
-func NewClient(ctx context.Context, data map[string][]byte, pcSpec *apisv1alpha1.ProviderConfigSpec) (*s3.Client, error) {
-	hostBase := resolveHostBase(pcSpec.HostBase, pcSpec.UseHTTPS)
+// A ClientOption configures the S3 client built by NewClient.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	tlsConfig  *tls.Config
+	proxyURL   *url.URL
+	httpClient *http.Client
+}
+
+// WithTLSConfig sets the TLS configuration of the client's HTTP transport.
+func WithTLSConfig(c *tls.Config) ClientOption {
+	return func(o *clientOptions) {
+		o.tlsConfig = c
+	}
+}
+
+// WithProxyURL sets the proxy requests to the backend are sent through.
+func WithProxyURL(u *url.URL) ClientOption {
+	return func(o *clientOptions) {
+		o.proxyURL = u
+	}
+}
+
+// WithHTTPClient sets the HTTP client requests to the backend are sent
+// through, so that the clients of a backend share one connection pool. The
+// TLS configuration and proxy are then taken from the HTTP client.
+func WithHTTPClient(c *http.Client) ClientOption {
+	return func(o *clientOptions) {
+		o.httpClient = c
+	}
+}
+
+// NewClient returns an S3 client for the backend described by the supplied
+// ProviderConfigSpec. A nil credentials provider means the default credential
+// chain of the SDK is used.
+func NewClient(ctx context.Context, creds aws.CredentialsProvider, pcSpec *apisv1alpha1.ProviderConfigSpec, o ...ClientOption) (*s3.Client, error) {
+	opts := &clientOptions{}
+	for _, opt := range o {
+		opt(opts)
+	}
+
+	hostBase := resolveHostBase(pcSpec.HostBase, aws.ToBool(pcSpec.UseHTTPS))
 
 	endpointResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
 		return aws.Endpoint{
@@ -28,22 +74,127 @@ func NewClient(ctx context.Context, data map[string][]byte, pcSpec *apisv1alpha1
 		}, nil
 	})
 
-	sessionConfig, err := config.LoadDefaultConfig(ctx, config.WithEndpointResolverWithOptions(endpointResolver))
+	sessionConfig, err := config.LoadDefaultConfig(ctx,
+		config.WithEndpointResolverWithOptions(endpointResolver),
+		config.WithRetryer(newRetryer(pcSpec.Retry)),
+	)
 	if err != nil {
 		return nil, err
 	}
 
+	// The HTTP client is set after loading the config, as loading it may
+	// require the buildable client of the SDK, whose transport cannot be
+	// reached to close its idle connections.
+	sessionConfig.HTTPClient = newHTTPClient(pcSpec, opts)
+
 	// By default make sure a region is specified, this is required for S3 operations
 	region := defaultRegion
+	if pcSpec.Region != "" {
+		region = pcSpec.Region
+	}
 	sessionConfig.Region = aws.ToString(&region)
 
-	sessionConfig.Credentials = aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(string(data[accessKey]), string(data[secretKey]), ""))
+	if creds != nil {
+		sessionConfig.Credentials = cachedCredentials(creds)
+	}
+
+	// Use virtual-hosted style requests if host_bucket is a template like
+	// "%(bucket)s.rgw.example.com", otherwise fall back to path style.
+	bucketDomain, virtualHosted := virtualHostDomain(pcSpec.HostBucket)
 
 	return s3.NewFromConfig(sessionConfig, func(o *s3.Options) {
-		o.UsePathStyle = true
+		o.UsePathStyle = !virtualHosted
+		if virtualHosted {
+			if mw := addHostBucketMiddleware(hostBase, bucketDomain); mw != nil {
+				o.APIOptions = append(o.APIOptions, mw)
+			}
+		}
+		if pcSpec.DisablePayloadSigning {
+			o.APIOptions = append(o.APIOptions, v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware)
+		}
 	}), nil
 }
 
+// NewHTTPClient returns an HTTP client for the backend described by the
+// supplied ProviderConfigSpec. Unlike the buildable client of the SDK, it
+// keeps the transport it sends requests through, so that CloseIdleConnections
+// closes the connections of clients that are replaced.
+func NewHTTPClient(pcSpec *apisv1alpha1.ProviderConfigSpec, o ...ClientOption) *http.Client {
+	opts := &clientOptions{}
+	for _, opt := range o {
+		opt(opts)
+	}
+
+	return newHTTPClient(pcSpec, opts)
+}
+
+// newHTTPClient returns the HTTP client used to talk to the backend.
+func newHTTPClient(pcSpec *apisv1alpha1.ProviderConfigSpec, opts *clientOptions) *http.Client {
+	if opts.httpClient != nil {
+		return opts.httpClient
+	}
+
+	httpConfig := pcSpec.HTTP
+	if httpConfig == nil {
+		httpConfig = &apisv1alpha1.HTTPConfig{}
+	}
+
+	client := awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+		if opts.tlsConfig != nil {
+			tr.TLSClientConfig = opts.tlsConfig
+		}
+		if opts.proxyURL != nil {
+			tr.Proxy = http.ProxyURL(opts.proxyURL)
+		}
+		if httpConfig.MaxIdleConnsPerHost != nil {
+			tr.MaxIdleConnsPerHost = *httpConfig.MaxIdleConnsPerHost
+		}
+		if httpConfig.IdleConnTimeout != nil {
+			tr.IdleConnTimeout = httpConfig.IdleConnTimeout.Duration
+		}
+	}).WithDialerOptions(func(d *net.Dialer) {
+		if httpConfig.DialTimeout != nil {
+			d.Timeout = httpConfig.DialTimeout.Duration
+		}
+		if httpConfig.KeepAlive != nil {
+			d.KeepAlive = httpConfig.KeepAlive.Duration
+		}
+	})
+
+	c := &http.Client{
+		Transport: client.GetTransport(),
+		// Redirects are returned to the SDK rather than followed, as S3
+		// reports them as errors.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	if pcSpec.RequestTimeout != nil {
+		c.Timeout = pcSpec.RequestTimeout.Duration
+	}
+
+	return c
+}
+
+// newRetryer returns a function creating the retryer of the client,
+// defaulting to the standard retryer of the SDK.
+func newRetryer(cfg *apisv1alpha1.RetryConfig) func() aws.Retryer {
+	return func() aws.Retryer {
+		return retry.NewStandard(func(o *retry.StandardOptions) {
+			if cfg == nil {
+				return
+			}
+			if cfg.MaxAttempts != nil {
+				o.MaxAttempts = *cfg.MaxAttempts
+			}
+			if cfg.MaxBackoff != nil {
+				o.MaxBackoff = cfg.MaxBackoff.Duration
+				o.Backoff = retry.NewExponentialJitterBackoff(cfg.MaxBackoff.Duration)
+			}
+		})
+	}
+}
+
 func resolveHostBase(hostBase string, useHTTPS bool) string {
 	if !strings.HasPrefix(hostBase, "http") {
 		if useHTTPS {
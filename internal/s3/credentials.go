@@ -0,0 +1,111 @@
+package s3
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+)
+
+const (
+	errNoSecretRef      = "no secretRef specified for credentials source Secret"
+	errGetSecret        = "cannot get credentials Secret"
+	errExtractCreds     = "cannot extract credentials"
+	errParseCreds       = "cannot parse credentials"
+	errMissingCredsKeys = "credentials must contain both access_key and secret_key"
+	errUnknownSource    = "unsupported credentials source %q"
+)
+
+// GetCredentialsProvider returns a credentials provider for the supplied
+// ProviderCredentials. A nil provider is returned for the InjectedIdentity
+// source, meaning the default credential chain of the SDK is used.
+func GetCredentialsProvider(ctx context.Context, kube client.Client, creds apisv1alpha1.ProviderCredentials) (aws.CredentialsProvider, error) {
+	switch creds.Source { //nolint:exhaustive // Remaining sources are unsupported.
+	case xpv1.CredentialsSourceNone:
+		return aws.AnonymousCredentials{}, nil
+	case xpv1.CredentialsSourceInjectedIdentity:
+		return nil, nil
+	case xpv1.CredentialsSourceSecret:
+		return secretCredentials(ctx, kube, creds.SecretRef)
+	case apisv1alpha1.CredentialsSourceS3Cfg:
+		cfg, err := ReadS3Cfg(ctx, kube, creds.SecretRef)
+		if err != nil {
+			return nil, err
+		}
+
+		return S3CfgCredentialsProvider(cfg)
+	case xpv1.CredentialsSourceEnvironment, xpv1.CredentialsSourceFilesystem:
+		data, err := resource.CommonCredentialExtractor(ctx, creds.Source, kube, creds.CommonCredentialSelectors)
+		if err != nil {
+			return nil, errors.Wrap(err, errExtractCreds)
+		}
+
+		return payloadCredentials(data)
+	}
+
+	return nil, errors.Errorf(errUnknownSource, creds.Source)
+}
+
+// S3CfgCredentialsProvider returns a credentials provider for the keys of an
+// already read s3cfg file.
+func S3CfgCredentialsProvider(cfg *S3Cfg) (aws.CredentialsProvider, error) {
+	return staticCredentials(cfg.AccessKey, cfg.SecretKey)
+}
+
+// secretCredentials reads credentials from a Secret. If the selected key is
+// present it is parsed as an s3cfg/INI file, otherwise the access_key and
+// secret_key keys of the Secret are used.
+func secretCredentials(ctx context.Context, kube client.Client, ref *xpv1.SecretKeySelector) (aws.CredentialsProvider, error) {
+	if ref == nil {
+		return nil, errors.New(errNoSecretRef)
+	}
+
+	secret := &corev1.Secret{}
+	if err := kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+		return nil, errors.Wrap(err, errGetSecret)
+	}
+
+	if payload, ok := secret.Data[ref.Key]; ok && ref.Key != "" {
+		return payloadCredentials(payload)
+	}
+
+	return staticCredentials(string(secret.Data[accessKey]), string(secret.Data[secretKey]))
+}
+
+// payloadCredentials parses credentials from an s3cfg/INI payload.
+func payloadCredentials(data []byte) (aws.CredentialsProvider, error) {
+	cfg, err := ParseS3Cfg(data)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseCreds)
+	}
+
+	return staticCredentials(cfg.AccessKey, cfg.SecretKey)
+}
+
+func staticCredentials(access, secret string) (aws.CredentialsProvider, error) {
+	if access == "" || secret == "" {
+		return nil, errors.New(errMissingCredsKeys)
+	}
+
+	return credentials.NewStaticCredentialsProvider(access, secret, ""), nil
+}
+
+// cachedCredentials wraps the supplied credentials provider in a cache,
+// unless it is already cached, so that cached credentials shared by several
+// clients are not cached a second time by each of them.
+func cachedCredentials(creds aws.CredentialsProvider) aws.CredentialsProvider {
+	if cache, ok := creds.(*aws.CredentialsCache); ok {
+		return cache
+	}
+
+	return aws.NewCredentialsCache(creds)
+}
@@ -0,0 +1,49 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+	"github.com/crossplane/provider-ceph/internal/backendstore"
+)
+
+// Backends describes the backends of a test: their modes, which are Active
+// unless supplied, and which of them cannot be reached.
+type Backends struct {
+	Modes map[string]apisv1alpha1.BackendMode
+	Down  map[string]bool
+}
+
+// NewIAMServers returns an IAM server per supplied backend name, which is
+// closed when the test ends.
+func NewIAMServers(t testing.TB, names ...string) map[string]*IAMServer {
+	t.Helper()
+
+	servers := make(map[string]*IAMServer, len(names))
+	for _, name := range names {
+		srv := NewIAMServer()
+		t.Cleanup(srv.Close)
+		servers[name] = srv
+	}
+
+	return servers
+}
+
+// NewBackendStore returns a BackendStore with a backend per supplied server,
+// whose IAM client talks to the server. The servers of backends that are down
+// are closed.
+func NewBackendStore(servers map[string]*IAMServer, b Backends) *backendstore.BackendStore {
+	s := backendstore.NewBackendStore()
+	for name, srv := range servers {
+		s.AddOrUpdateBackend(name, s3.New(s3.Options{}))
+		s.SetIAMClient(name, srv.NewClient())
+		s.SetBackendMode(name, b.Modes[name])
+		if b.Down[name] {
+			srv.Close()
+		}
+	}
+
+	return s
+}
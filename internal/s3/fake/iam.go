@@ -0,0 +1,570 @@
+// Package fake implements in-memory servers of the APIs RGW serves on its S3
+// endpoint, for tests.
+package fake
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+const (
+	iamNamespace = "https://iam.amazonaws.com/doc/2010-05-08/"
+	createDate   = "2023-01-01T00:00:00Z"
+
+	errCodeAccessDenied     = "AccessDenied"
+	errCodeInvalidAction    = "InvalidAction"
+	errCodeValidation       = "ValidationError"
+	errCodeNoSuchEntity     = "NoSuchEntity"
+	errCodeEntityExists     = "EntityAlreadyExists"
+	errCodeDeleteConflict   = "DeleteConflict"
+	errCodeMethodNotAllowed = "MethodNotAllowed"
+	defaultMaxSession       = 3600
+)
+
+// IAMServer is an in-memory IAM API server, serving the subset of the API RGW
+// implements. Requests must be SigV4 signed but signatures are not verified.
+type IAMServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	roles  map[string]*role
+	roleID int
+	// users maps the uids of users, which are managed through the Admin
+	// Ops API, to their inline policies.
+	users map[string]map[string]string
+	// providers maps the URLs of OIDC providers, without their scheme, to
+	// the providers.
+	providers map[string]*OIDCProvider
+	rejected  map[string]bool
+}
+
+// An OIDCProvider is an OpenID Connect identity provider stored by the
+// server.
+type OIDCProvider struct {
+	ClientIDs   []string
+	Thumbprints []string
+}
+
+type role struct {
+	iamtypes.Role
+	policies map[string]string
+}
+
+// NewIAMServer starts and returns a new IAMServer. It must be closed by the
+// caller.
+func NewIAMServer() *IAMServer {
+	s := &IAMServer{
+		roles:     map[string]*role{},
+		users:     map[string]map[string]string{},
+		providers: map[string]*OIDCProvider{},
+		rejected:  map[string]bool{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// NewClient returns a client for the server. Failed requests are not retried.
+func (s *IAMServer) NewClient() *iam.Client {
+	return iam.New(iam.Options{
+		Region:           "us-east-1",
+		Credentials:      credentials.NewStaticCredentialsProvider("admin", "secret", ""),
+		EndpointResolver: iam.EndpointResolverFromURL(s.URL),
+		HTTPClient:       s.Client(),
+		Retryer:          aws.NopRetryer{},
+	})
+}
+
+// GetRole returns a copy of the stored role with the supplied name.
+func (s *IAMServer) GetRole(name string) (iamtypes.Role, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.roles[name]
+	if !ok {
+		return iamtypes.Role{}, false
+	}
+
+	return r.Role, true
+}
+
+// GetRolePolicy returns the document of the stored inline policy with the
+// supplied name of the role with the supplied name.
+func (s *IAMServer) GetRolePolicy(roleName, policyName string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.roles[roleName]
+	if !ok {
+		return "", false
+	}
+	doc, ok := r.policies[policyName]
+
+	return doc, ok
+}
+
+// AddUser adds a user without inline policies to the server, as though it had
+// been created through the Admin Ops API.
+func (s *IAMServer) AddUser(uid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[uid] = map[string]string{}
+}
+
+// GetUserPolicy returns the document of the stored inline policy with the
+// supplied name of the user with the supplied uid.
+func (s *IAMServer) GetUserPolicy(uid, policyName string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.users[uid][policyName]
+
+	return doc, ok
+}
+
+// GetOIDCProvider returns a copy of the stored OIDC provider with the supplied
+// URL.
+func (s *IAMServer) GetOIDCProvider(providerURL string) (OIDCProvider, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.providers[stripScheme(providerURL)]
+	if !ok {
+		return OIDCProvider{}, false
+	}
+
+	return OIDCProvider{
+		ClientIDs:   append([]string{}, p.ClientIDs...),
+		Thumbprints: append([]string{}, p.Thumbprints...),
+	}, true
+}
+
+// Reject makes the server answer the supplied actions like RGW answers the
+// actions it does not implement, e.g. those added by later releases.
+func (s *IAMServer) Reject(actions ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range actions {
+		s.rejected[a] = true
+	}
+}
+
+func (s *IAMServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
+		writeIAMError(w, http.StatusForbidden, errCodeAccessDenied)
+
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeIAMError(w, http.StatusBadRequest, errCodeValidation)
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	action := r.Form.Get("Action")
+	if s.rejected[action] {
+		writeIAMError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed)
+
+		return
+	}
+
+	switch action {
+	case "CreateRole", "GetRole", "UpdateRole", "UpdateAssumeRolePolicy", "DeleteRole":
+		s.serveRole(w, action, r.Form)
+	case "PutRolePolicy", "GetRolePolicy", "DeleteRolePolicy":
+		s.serveRolePolicy(w, action, r.Form)
+	case "PutUserPolicy", "GetUserPolicy", "DeleteUserPolicy":
+		s.serveUserPolicy(w, action, r.Form)
+	case "ListOpenIDConnectProviders":
+		s.listOIDCProviders(w, action)
+	case "CreateOpenIDConnectProvider", "GetOpenIDConnectProvider", "DeleteOpenIDConnectProvider",
+		"UpdateOpenIDConnectProviderThumbprint", "AddClientIDToOpenIDConnectProvider", "RemoveClientIDFromOpenIDConnectProvider":
+		s.serveOIDCProvider(w, action, r.Form)
+	default:
+		writeIAMError(w, http.StatusBadRequest, errCodeInvalidAction)
+	}
+}
+
+func (s *IAMServer) serveRole(w http.ResponseWriter, action string, q url.Values) {
+	name := q.Get("RoleName")
+	if name == "" {
+		writeIAMError(w, http.StatusBadRequest, errCodeValidation)
+
+		return
+	}
+
+	r, exists := s.roles[name]
+	if action == "CreateRole" {
+		if exists {
+			writeIAMError(w, http.StatusConflict, errCodeEntityExists)
+
+			return
+		}
+		r, ok := s.newRole(name, q)
+		if !ok {
+			writeIAMError(w, http.StatusBadRequest, errCodeValidation)
+
+			return
+		}
+		s.roles[name] = r
+		writeIAMResult(w, action, roleResult{Role: newXMLRole(r.Role)})
+
+		return
+	}
+	if !exists {
+		writeIAMError(w, http.StatusNotFound, errCodeNoSuchEntity)
+
+		return
+	}
+
+	switch action {
+	case "GetRole":
+		writeIAMResult(w, action, roleResult{Role: newXMLRole(r.Role)})
+	case "UpdateRole":
+		if v := q.Get("MaxSessionDuration"); v != "" {
+			d, ok := maxSessionDuration(v)
+			if !ok {
+				writeIAMError(w, http.StatusBadRequest, errCodeValidation)
+
+				return
+			}
+			r.MaxSessionDuration = aws.Int32(d)
+		}
+		writeIAMResult(w, action, struct{}{})
+	case "UpdateAssumeRolePolicy":
+		r.AssumeRolePolicyDocument = aws.String(q.Get("PolicyDocument"))
+		writeIAMResult(w, action, struct{}{})
+	case "DeleteRole":
+		// Like IAM, RGW refuses to delete roles with inline policies.
+		if len(r.policies) > 0 {
+			writeIAMError(w, http.StatusConflict, errCodeDeleteConflict)
+
+			return
+		}
+		delete(s.roles, name)
+		writeIAMResult(w, action, struct{}{})
+	}
+}
+
+func (s *IAMServer) serveRolePolicy(w http.ResponseWriter, action string, q url.Values) {
+	r, ok := s.roles[q.Get("RoleName")]
+	if !ok {
+		writeIAMError(w, http.StatusNotFound, errCodeNoSuchEntity)
+
+		return
+	}
+
+	s.servePolicy(w, action, q, r.policies, rolePolicyResult{RoleName: q.Get("RoleName")})
+}
+
+func (s *IAMServer) serveUserPolicy(w http.ResponseWriter, action string, q url.Values) {
+	policies, ok := s.users[q.Get("UserName")]
+	if !ok {
+		writeIAMError(w, http.StatusNotFound, errCodeNoSuchEntity)
+
+		return
+	}
+
+	s.servePolicy(w, action, q, policies, userPolicyResult{UserName: q.Get("UserName")})
+}
+
+// servePolicy serves the put, get and delete actions of the supplied inline
+// policies of an entity. The result of get actions is the supplied result
+// with the policy set.
+func (s *IAMServer) servePolicy(w http.ResponseWriter, action string, q url.Values, policies map[string]string, result policyResult) {
+	name := q.Get("PolicyName")
+	if name == "" {
+		writeIAMError(w, http.StatusBadRequest, errCodeValidation)
+
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(action, "Put"):
+		policies[name] = q.Get("PolicyDocument")
+		writeIAMResult(w, action, struct{}{})
+	case strings.HasPrefix(action, "Get"):
+		doc, ok := policies[name]
+		if !ok {
+			writeIAMError(w, http.StatusNotFound, errCodeNoSuchEntity)
+
+			return
+		}
+		writeIAMResult(w, action, result.withPolicy(name, doc))
+	case strings.HasPrefix(action, "Delete"):
+		if _, ok := policies[name]; !ok {
+			writeIAMError(w, http.StatusNotFound, errCodeNoSuchEntity)
+
+			return
+		}
+		delete(policies, name)
+		writeIAMResult(w, action, struct{}{})
+	}
+}
+
+func (s *IAMServer) listOIDCProviders(w http.ResponseWriter, action string) {
+	urls := make([]string, 0, len(s.providers))
+	for u := range s.providers {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+
+	result := oidcProviderListResult{}
+	for _, u := range urls {
+		result.ARNs = append(result.ARNs, oidcProviderARN(u))
+	}
+	writeIAMResult(w, action, result)
+}
+
+func (s *IAMServer) serveOIDCProvider(w http.ResponseWriter, action string, q url.Values) {
+	if action == "CreateOpenIDConnectProvider" {
+		providerURL := stripScheme(q.Get("Url"))
+		if providerURL == "" {
+			writeIAMError(w, http.StatusBadRequest, errCodeValidation)
+
+			return
+		}
+		if _, ok := s.providers[providerURL]; ok {
+			writeIAMError(w, http.StatusConflict, errCodeEntityExists)
+
+			return
+		}
+		s.providers[providerURL] = &OIDCProvider{ClientIDs: members(q, "ClientIDList"), Thumbprints: members(q, "ThumbprintList")}
+		writeIAMResult(w, action, oidcProviderARNResult{ARN: oidcProviderARN(providerURL)})
+
+		return
+	}
+
+	providerURL := strings.TrimPrefix(q.Get("OpenIDConnectProviderArn"), oidcProviderARN(""))
+	p, ok := s.providers[providerURL]
+	if !ok {
+		writeIAMError(w, http.StatusNotFound, errCodeNoSuchEntity)
+
+		return
+	}
+
+	switch action {
+	case "GetOpenIDConnectProvider":
+		writeIAMResult(w, action, oidcProviderResult{URL: providerURL, ClientIDs: p.ClientIDs, Thumbprints: p.Thumbprints, CreateDate: createDate})
+	case "DeleteOpenIDConnectProvider":
+		delete(s.providers, providerURL)
+		writeIAMResult(w, action, struct{}{})
+	case "UpdateOpenIDConnectProviderThumbprint":
+		p.Thumbprints = members(q, "ThumbprintList")
+		writeIAMResult(w, action, struct{}{})
+	case "AddClientIDToOpenIDConnectProvider":
+		id := q.Get("ClientID")
+		for _, c := range p.ClientIDs {
+			if c == id {
+				writeIAMResult(w, action, struct{}{})
+
+				return
+			}
+		}
+		p.ClientIDs = append(p.ClientIDs, id)
+		writeIAMResult(w, action, struct{}{})
+	case "RemoveClientIDFromOpenIDConnectProvider":
+		ids := []string{}
+		for _, c := range p.ClientIDs {
+			if c != q.Get("ClientID") {
+				ids = append(ids, c)
+			}
+		}
+		p.ClientIDs = ids
+		writeIAMResult(w, action, struct{}{})
+	}
+}
+
+// oidcProviderARN returns the ARN of the provider with the supplied URL
+// without its scheme, which RGW strips.
+func oidcProviderARN(providerURL string) string {
+	return "arn:aws:iam:::oidc-provider/" + providerURL
+}
+
+func stripScheme(providerURL string) string {
+	if i := strings.Index(providerURL, "://"); i >= 0 {
+		return providerURL[i+len("://"):]
+	}
+
+	return providerURL
+}
+
+// members returns the members of the supplied list parameter.
+func members(q url.Values, list string) []string {
+	out := []string{}
+	for i := 1; q.Has(list + ".member." + strconv.Itoa(i)); i++ {
+		out = append(out, q.Get(list+".member."+strconv.Itoa(i)))
+	}
+
+	return out
+}
+
+func (s *IAMServer) newRole(name string, q url.Values) (*role, bool) {
+	path := q.Get("Path")
+	if path == "" {
+		path = "/"
+	}
+	d := int32(defaultMaxSession)
+	if v := q.Get("MaxSessionDuration"); v != "" {
+		var ok bool
+		if d, ok = maxSessionDuration(v); !ok {
+			return nil, false
+		}
+	}
+
+	s.roleID++
+
+	return &role{
+		Role: iamtypes.Role{
+			RoleName:                 aws.String(name),
+			RoleId:                   aws.String(fmt.Sprintf("role-%d", s.roleID)),
+			Path:                     aws.String(path),
+			Arn:                      aws.String("arn:aws:iam:::role" + path + name),
+			AssumeRolePolicyDocument: aws.String(q.Get("AssumeRolePolicyDocument")),
+			MaxSessionDuration:       aws.Int32(d),
+		},
+		policies: map[string]string{},
+	}, true
+}
+
+func maxSessionDuration(v string) (int32, bool) {
+	d, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || d < 3600 || d > 43200 {
+		return 0, false
+	}
+
+	return int32(d), true
+}
+
+type xmlRole struct {
+	Path                     string `xml:"Path"`
+	RoleName                 string `xml:"RoleName"`
+	RoleID                   string `xml:"RoleId"`
+	Arn                      string `xml:"Arn"`
+	CreateDate               string `xml:"CreateDate"`
+	AssumeRolePolicyDocument string `xml:"AssumeRolePolicyDocument"`
+	MaxSessionDuration       int32  `xml:"MaxSessionDuration"`
+}
+
+func newXMLRole(r iamtypes.Role) xmlRole {
+	return xmlRole{
+		Path:                     aws.ToString(r.Path),
+		RoleName:                 aws.ToString(r.RoleName),
+		RoleID:                   aws.ToString(r.RoleId),
+		Arn:                      aws.ToString(r.Arn),
+		CreateDate:               createDate,
+		AssumeRolePolicyDocument: aws.ToString(r.AssumeRolePolicyDocument),
+		MaxSessionDuration:       aws.ToInt32(r.MaxSessionDuration),
+	}
+}
+
+type roleResult struct {
+	Role xmlRole `xml:"Role"`
+}
+
+type oidcProviderARNResult struct {
+	ARN string `xml:"OpenIDConnectProviderArn"`
+}
+
+type oidcProviderListResult struct {
+	ARNs []string `xml:"OpenIDConnectProviderList>member>Arn"`
+}
+
+type oidcProviderResult struct {
+	URL         string   `xml:"Url"`
+	ClientIDs   []string `xml:"ClientIDList>member"`
+	Thumbprints []string `xml:"ThumbprintList>member"`
+	CreateDate  string   `xml:"CreateDate"`
+}
+
+type policyResult interface {
+	withPolicy(name, doc string) interface{}
+}
+
+type rolePolicyResult struct {
+	RoleName       string `xml:"RoleName"`
+	PolicyName     string `xml:"PolicyName"`
+	PolicyDocument string `xml:"PolicyDocument"`
+}
+
+func (r rolePolicyResult) withPolicy(name, doc string) interface{} {
+	r.PolicyName, r.PolicyDocument = name, doc
+
+	return r
+}
+
+type userPolicyResult struct {
+	UserName       string `xml:"UserName"`
+	PolicyName     string `xml:"PolicyName"`
+	PolicyDocument string `xml:"PolicyDocument"`
+}
+
+func (r userPolicyResult) withPolicy(name, doc string) interface{} {
+	r.PolicyName, r.PolicyDocument = name, doc
+
+	return r
+}
+
+type iamError struct {
+	XMLName xml.Name `xml:"ErrorResponse"`
+	Error   struct {
+		Type    string `xml:"Type"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Error"`
+	RequestID string `xml:"RequestId"`
+}
+
+// writeIAMResult writes the supplied result of the supplied action, wrapped
+// like the AWS query protocol expects.
+func writeIAMResult(w http.ResponseWriter, action string, result interface{}) {
+	w.Header().Set("Content-Type", "text/xml")
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name
+		Result  interface{} `xml:",omitempty"`
+		Meta    struct {
+			RequestID string `xml:"RequestId"`
+		} `xml:"ResponseMetadata"`
+	}{
+		XMLName: xml.Name{Space: iamNamespace, Local: action + "Response"},
+		Result:  resultElement{name: action + "Result", v: result},
+	})
+}
+
+// resultElement encodes v as an element with the supplied name.
+type resultElement struct {
+	name string
+	v    interface{}
+}
+
+func (e resultElement) MarshalXML(enc *xml.Encoder, _ xml.StartElement) error {
+	return enc.EncodeElement(e.v, xml.StartElement{Name: xml.Name{Local: e.name}})
+}
+
+func writeIAMError(w http.ResponseWriter, status int, code string) {
+	e := iamError{}
+	e.Error.Type = "Sender"
+	e.Error.Code = code
+	e.Error.Message = code
+
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	_ = xml.NewEncoder(w).Encode(e)
+}
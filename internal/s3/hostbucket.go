@@ -0,0 +1,73 @@
+package s3
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// bucketPlaceholder is the s3cfg host_bucket template placeholder for the
+// bucket name.
+const bucketPlaceholder = "%(bucket)s"
+
+// virtualHostDomain parses an s3cfg style host_bucket template such as
+// "%(bucket)s.rgw.example.com" and returns the domain buckets are hosted
+// under. It returns false if the template does not describe virtual-hosted
+// style addressing, in which case path style requests are used.
+func virtualHostDomain(hostBucket string) (string, bool) {
+	for _, scheme := range []string{"https://", "http://"} {
+		hostBucket = strings.TrimPrefix(hostBucket, scheme)
+	}
+
+	domain := strings.TrimPrefix(hostBucket, bucketPlaceholder+".")
+	if domain == hostBucket || domain == "" || strings.Contains(domain, bucketPlaceholder) {
+		return "", false
+	}
+
+	return strings.TrimSuffix(domain, "/"), true
+}
+
+// hostBucketMiddleware moves virtual-hosted style requests from the host_base
+// domain to the host_bucket domain, for backends where the two differ.
+// Requests without a bucket in the host name keep using host_base. The port
+// of host_base is kept, unless the host_bucket domain has its own.
+type hostBucketMiddleware struct {
+	// baseHost is the host name of host_base, without its port.
+	baseHost     string
+	bucketDomain string
+}
+
+func (*hostBucketMiddleware) ID() string {
+	return "HostBucket"
+}
+
+func (m *hostBucketMiddleware) HandleBuild(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (middleware.BuildOutput, middleware.Metadata, error) {
+	if req, ok := in.Request.(*smithyhttp.Request); ok {
+		host, port := req.URL.Hostname(), req.URL.Port()
+		if bucket := strings.TrimSuffix(host, "."+m.baseHost); bucket != host {
+			req.URL.Host = bucket + "." + m.bucketDomain
+			if _, _, err := net.SplitHostPort(m.bucketDomain); err != nil && port != "" {
+				req.URL.Host = net.JoinHostPort(req.URL.Host, port)
+			}
+		}
+	}
+
+	return next.HandleBuild(ctx, in)
+}
+
+// addHostBucketMiddleware returns an API option that registers the
+// hostBucketMiddleware, or nil if host_base already serves the buckets.
+func addHostBucketMiddleware(hostBase, bucketDomain string) func(*middleware.Stack) error {
+	u, err := url.Parse(hostBase)
+	if err != nil || u.Host == bucketDomain || u.Hostname() == bucketDomain {
+		return nil
+	}
+
+	return func(stack *middleware.Stack) error {
+		return stack.Build.Add(&hostBucketMiddleware{baseHost: u.Hostname(), bucketDomain: bucketDomain}, middleware.After)
+	}
+}
@@ -0,0 +1,112 @@
+package s3
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVirtualHostDomain(t *testing.T) {
+	t.Parallel()
+
+	type want struct {
+		domain        string
+		virtualHosted bool
+	}
+
+	cases := map[string]struct {
+		reason     string
+		hostBucket string
+		want       want
+	}{
+		"Empty": {
+			reason: "An empty host_bucket should use path style requests.",
+		},
+		"Template": {
+			reason:     "A bucket subdomain template should use virtual-hosted style requests.",
+			hostBucket: "%(bucket)s.rgw.example.com",
+			want:       want{domain: "rgw.example.com", virtualHosted: true},
+		},
+		"TemplateWithSchemeAndPort": {
+			reason:     "A scheme should be ignored and a port kept.",
+			hostBucket: "https://%(bucket)s.rgw.example.com:7480",
+			want:       want{domain: "rgw.example.com:7480", virtualHosted: true},
+		},
+		"NoPlaceholder": {
+			reason:     "A host_bucket equal to host_base should use path style requests.",
+			hostBucket: "rgw.example.com",
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			domain, virtualHosted := virtualHostDomain(tc.hostBucket)
+			if diff := cmp.Diff(tc.want, want{domain: domain, virtualHosted: virtualHosted}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nvirtualHostDomain(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestHostBucketMiddleware(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		reason       string
+		host         string
+		bucketDomain string
+		want         string
+	}{
+		"BucketRequest": {
+			reason:       "Requests for a bucket should be sent to the host_bucket domain.",
+			host:         "my-bucket.rgw.example.com",
+			bucketDomain: "buckets.example.com",
+			want:         "my-bucket.buckets.example.com",
+		},
+		"BucketRequestWithPort": {
+			reason:       "Requests for a bucket should keep the port of host_base if the host_bucket domain has none.",
+			host:         "my-bucket.rgw.example.com:8080",
+			bucketDomain: "buckets.example.com",
+			want:         "my-bucket.buckets.example.com:8080",
+		},
+		"BucketDomainWithPort": {
+			reason:       "Requests for a bucket should use the port of the host_bucket domain if it has one.",
+			host:         "my-bucket.rgw.example.com:8080",
+			bucketDomain: "buckets.example.com:7480",
+			want:         "my-bucket.buckets.example.com:7480",
+		},
+		"ServiceRequest": {
+			reason:       "Requests without a bucket should be sent to host_base.",
+			host:         "rgw.example.com:8080",
+			bucketDomain: "buckets.example.com",
+			want:         "rgw.example.com:8080",
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := smithyhttp.NewStackRequest().(*smithyhttp.Request)
+			req.URL = &url.URL{Scheme: "http", Host: tc.host}
+
+			m := &hostBucketMiddleware{baseHost: "rgw.example.com", bucketDomain: tc.bucketDomain}
+			_, _, err := m.HandleBuild(context.Background(), middleware.BuildInput{Request: req}, middleware.BuildHandlerFunc(
+				func(ctx context.Context, in middleware.BuildInput) (middleware.BuildOutput, middleware.Metadata, error) {
+					return middleware.BuildOutput{}, middleware.Metadata{}, nil
+				}))
+			if err != nil {
+				t.Fatalf("HandleBuild(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, req.URL.Host); diff != "" {
+				t.Errorf("\n%s\nHandleBuild(...): -want host, +got host:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
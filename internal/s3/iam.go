@@ -0,0 +1,87 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/pkg/errors"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+)
+
+const errLoadIAMConfig = "cannot load IAM client config"
+
+// NewIAMClient returns a client of the IAM API of the backend described by
+// the supplied ProviderConfigSpec. RGW serves the IAM API on the same
+// endpoint as the S3 API. Managing roles and policies requires the roles and
+// user-policy caps, so the client is usually given the admin credentials. A
+// nil credentials provider means the default credential chain of the SDK is
+// used.
+func NewIAMClient(ctx context.Context, creds aws.CredentialsProvider, pcSpec *apisv1alpha1.ProviderConfigSpec, o ...ClientOption) (*iam.Client, error) {
+	opts := &clientOptions{}
+	for _, opt := range o {
+		opt(opts)
+	}
+
+	endpoint := strings.TrimSuffix(resolveHostBase(pcSpec.HostBase, aws.ToBool(pcSpec.UseHTTPS)), "/")
+	endpointResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+		return aws.Endpoint{
+			URL:               endpoint,
+			HostnameImmutable: true,
+		}, nil
+	})
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithEndpointResolverWithOptions(endpointResolver),
+		config.WithRetryer(newRetryer(pcSpec.Retry)),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, errLoadIAMConfig)
+	}
+
+	// See NewClient.
+	cfg.HTTPClient = newHTTPClient(pcSpec, opts)
+
+	cfg.Region = defaultRegion
+	if pcSpec.Region != "" {
+		cfg.Region = pcSpec.Region
+	}
+
+	if creds != nil {
+		cfg.Credentials = cachedCredentials(creds)
+	}
+
+	return iam.NewFromConfig(cfg), nil
+}
+
+// IsNoSuchEntity returns true if the error reports that the requested IAM
+// entity, e.g. a role or a policy, does not exist.
+func IsNoSuchEntity(err error) bool {
+	var noSuchEntity *iamtypes.NoSuchEntityException
+
+	return errors.As(err, &noSuchEntity)
+}
+
+// IsNotImplemented returns true if the error reports that the backend does not
+// implement the requested IAM action. RGW answers actions added by later
+// releases with MethodNotAllowed.
+func IsNotImplemented(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "MethodNotAllowed", "NotImplemented", "InvalidAction":
+			return true
+		}
+	}
+
+	var respErr *smithyhttp.ResponseError
+
+	return errors.As(err, &respErr) && (respErr.HTTPStatusCode() == http.StatusMethodNotAllowed || respErr.HTTPStatusCode() == http.StatusNotImplemented)
+}
@@ -0,0 +1,45 @@
+package s3
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+)
+
+const (
+	proxyUsernameKey = "username"
+	proxyPasswordKey = "password"
+
+	errParseProxyURL       = "cannot parse proxy URL"
+	errGetProxyCredsSecret = "cannot get proxy credentials Secret"
+)
+
+// GetProxyURL returns the URL of the proxy configured for a backend,
+// including the credentials of any referenced Secret. A nil URL is returned
+// if no proxy is configured.
+func GetProxyURL(ctx context.Context, kube client.Client, cfg *apisv1alpha1.HTTPConfig) (*url.URL, error) {
+	if cfg == nil || cfg.ProxyURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(cfg.ProxyURL)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseProxyURL)
+	}
+
+	if ref := cfg.ProxyCredentialsSecretRef; ref != nil {
+		secret := &corev1.Secret{}
+		if err := kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+			return nil, errors.Wrap(err, errGetProxyCredsSecret)
+		}
+		u.User = url.UserPassword(string(secret.Data[proxyUsernameKey]), string(secret.Data[proxyPasswordKey]))
+	}
+
+	return u, nil
+}
@@ -0,0 +1,155 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+)
+
+const (
+	s3cfgDefaultSection = "default"
+
+	hostBaseKey       = "host_base"
+	hostBucketKey     = "host_bucket"
+	useHTTPSKey       = "use_https"
+	bucketLocationKey = "bucket_location"
+
+	// s3cmdDefaultLocation is the bucket_location written by s3cmd
+	// --configure when none is chosen. It is not a region.
+	s3cmdDefaultLocation = "US"
+
+	errMalformedS3CfgLine = "malformed s3cfg line %d"
+	errParseUseHTTPS      = "cannot parse use_https"
+	errNoS3CfgKey         = "no secretRef key specified for credentials source S3Cfg"
+	errNoS3CfgInSecret    = "Secret has no key %q"
+	errNoHostBase         = "no hostBase specified and none found in s3cfg"
+)
+
+// S3Cfg holds the settings of an s3cfg (s3cmd INI) file that are relevant to
+// the provider.
+type S3Cfg struct {
+	AccessKey  string
+	SecretKey  string
+	HostBase   string
+	HostBucket string
+	UseHTTPS   *bool
+	Region     string
+}
+
+// ReadS3Cfg reads and parses the s3cfg file selected by the supplied Secret
+// key selector.
+func ReadS3Cfg(ctx context.Context, kube client.Client, ref *xpv1.SecretKeySelector) (*S3Cfg, error) {
+	if ref == nil {
+		return nil, errors.New(errNoSecretRef)
+	}
+	if ref.Key == "" {
+		return nil, errors.New(errNoS3CfgKey)
+	}
+
+	secret := &corev1.Secret{}
+	if err := kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+		return nil, errors.Wrap(err, errGetSecret)
+	}
+
+	data, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, errors.Errorf(errNoS3CfgInSecret, ref.Key)
+	}
+
+	return ParseS3Cfg(data)
+}
+
+// ApplyS3Cfg returns a copy of the supplied ProviderConfigSpec with the host
+// settings it leaves unset taken from the s3cfg file.
+func ApplyS3Cfg(spec *apisv1alpha1.ProviderConfigSpec, cfg *S3Cfg) (*apisv1alpha1.ProviderConfigSpec, error) {
+	out := spec.DeepCopy()
+	if out.HostBase == "" {
+		out.HostBase = cfg.HostBase
+	}
+	if out.HostBucket == "" {
+		out.HostBucket = cfg.HostBucket
+	}
+	if out.Region == "" && cfg.Region != s3cmdDefaultLocation {
+		out.Region = cfg.Region
+	}
+	if out.UseHTTPS == nil {
+		out.UseHTTPS = cfg.UseHTTPS
+	}
+
+	if out.HostBase == "" {
+		return nil, errors.New(errNoHostBase)
+	}
+
+	return out, nil
+}
+
+// ParseS3Cfg parses an s3cfg or any INI formatted file. Keys are read from
+// the [default] section, or from outside of any section, so a plain list of
+// "access_key = ..." and "secret_key = ..." lines is accepted as well.
+func ParseS3Cfg(data []byte) (*S3Cfg, error) {
+	values, err := parseINI(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &S3Cfg{
+		AccessKey:  values[accessKey],
+		SecretKey:  values[secretKey],
+		HostBase:   values[hostBaseKey],
+		HostBucket: values[hostBucketKey],
+		Region:     values[bucketLocationKey],
+	}
+
+	if v, ok := values[useHTTPSKey]; ok {
+		useHTTPS, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.Wrap(err, errParseUseHTTPS)
+		}
+		cfg.UseHTTPS = &useHTTPS
+	}
+
+	return cfg, nil
+}
+
+// parseINI returns the key/value pairs of the default section of INI
+// formatted data.
+func parseINI(data []byte) (map[string]string, error) {
+	values := map[string]string{}
+	section := s3cfgDefaultSection
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case text == "", strings.HasPrefix(text, "#"), strings.HasPrefix(text, ";"):
+			continue
+		case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+			section = strings.TrimSpace(text[1 : len(text)-1])
+
+			continue
+		}
+
+		if section != s3cfgDefaultSection {
+			continue
+		}
+
+		key, value, found := strings.Cut(text, "=")
+		if !found {
+			return nil, errors.Errorf(errMalformedS3CfgLine, line)
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return values, errors.Wrap(scanner.Err(), "cannot read s3cfg")
+}
@@ -0,0 +1,139 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/utils/pointer"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+)
+
+func TestParseS3Cfg(t *testing.T) {
+	t.Parallel()
+
+	type want struct {
+		cfg *S3Cfg
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"S3CmdFile": {
+			reason: "Keys of the default section of an s3cmd file should be parsed.",
+			data: `[default]
+# Credentials of the admin user.
+access_key = AKIAEXAMPLE
+secret_key = c2VjcmV0=
+host_base = rgw.example.com:7480
+host_bucket = %(bucket)s.rgw.example.com:7480
+bucket_location = zonegroup-a
+use_https = True
+
+[other]
+access_key = ignored
+`,
+			want: want{
+				cfg: &S3Cfg{
+					AccessKey:  "AKIAEXAMPLE",
+					SecretKey:  "c2VjcmV0=",
+					HostBase:   "rgw.example.com:7480",
+					HostBucket: "%(bucket)s.rgw.example.com:7480",
+					Region:     "zonegroup-a",
+					UseHTTPS:   pointer.Bool(true),
+				},
+			},
+		},
+		"NoSection": {
+			reason: "Keys outside of any section should be parsed.",
+			data:   "access_key=AKIAEXAMPLE\nsecret_key=secret\n",
+			want: want{
+				cfg: &S3Cfg{AccessKey: "AKIAEXAMPLE", SecretKey: "secret"},
+			},
+		},
+		"MalformedLine": {
+			reason: "A line that is not a key/value pair should be rejected.",
+			data:   "access_key AKIAEXAMPLE\n",
+			want: want{
+				err: errors.Errorf(errMalformedS3CfgLine, 1),
+			},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseS3Cfg([]byte(tc.data))
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseS3Cfg(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.cfg, got); diff != "" {
+				t.Errorf("\n%s\nParseS3Cfg(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestApplyS3Cfg(t *testing.T) {
+	t.Parallel()
+
+	cfg := &S3Cfg{HostBase: "rgw.example.com", HostBucket: "%(bucket)s.rgw.example.com", UseHTTPS: pointer.Bool(true), Region: "zonegroup-a"}
+
+	type want struct {
+		spec *apisv1alpha1.ProviderConfigSpec
+		err  error
+	}
+
+	cases := map[string]struct {
+		reason string
+		spec   *apisv1alpha1.ProviderConfigSpec
+		cfg    *S3Cfg
+		want   want
+	}{
+		"Unset": {
+			reason: "Settings left unset should be taken from the s3cfg file.",
+			spec:   &apisv1alpha1.ProviderConfigSpec{},
+			cfg:    cfg,
+			want: want{
+				spec: &apisv1alpha1.ProviderConfigSpec{HostBase: "rgw.example.com", HostBucket: "%(bucket)s.rgw.example.com", UseHTTPS: pointer.Bool(true), Region: "zonegroup-a"},
+			},
+		},
+		"Set": {
+			reason: "Settings of the ProviderConfig should override the s3cfg file, including useHttps: false.",
+			spec:   &apisv1alpha1.ProviderConfigSpec{HostBase: "rgw.internal", HostBucket: "rgw.internal", UseHTTPS: pointer.Bool(false), Region: "zonegroup-b"},
+			cfg:    cfg,
+			want: want{
+				spec: &apisv1alpha1.ProviderConfigSpec{HostBase: "rgw.internal", HostBucket: "rgw.internal", UseHTTPS: pointer.Bool(false), Region: "zonegroup-b"},
+			},
+		},
+		"NoHostBase": {
+			reason: "A hostBase should be required from either the ProviderConfig or the s3cfg file.",
+			spec:   &apisv1alpha1.ProviderConfigSpec{},
+			cfg:    &S3Cfg{},
+			want: want{
+				err: errors.New(errNoHostBase),
+			},
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ApplyS3Cfg(tc.spec, tc.cfg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nApplyS3Cfg(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.spec, got); diff != "" {
+				t.Errorf("\n%s\nApplyS3Cfg(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package s3
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/pkg/errors"
+
+	apisv1alpha1 "github.com/crossplane/provider-ceph/apis/v1alpha1"
+)
+
+const (
+	errLoadSTSConfig = "cannot load STS client config"
+
+	defaultRoleSessionName = "provider-ceph"
+)
+
+// NewAssumeRoleCredentialsProvider returns a provider of the temporary
+// credentials of the role configured by the AssumeRole config of the supplied
+// ProviderConfigSpec, obtained from the STS API RGW serves on the same
+// endpoint as the S3 API. The role is assumed with the supplied credentials,
+// or with the web identity token if one is configured. The credentials are
+// cached and refreshed before they expire, so the returned provider should be
+// reused for as long as the config is unchanged. The supplied credentials are
+// returned as is if no role is configured.
+func NewAssumeRoleCredentialsProvider(ctx context.Context, creds aws.CredentialsProvider, pcSpec *apisv1alpha1.ProviderConfigSpec, o ...ClientOption) (aws.CredentialsProvider, error) {
+	ar := pcSpec.AssumeRole
+	if ar == nil {
+		return creds, nil
+	}
+
+	if ar.WebIdentityTokenFile != "" {
+		// AssumeRoleWithWebIdentity is authenticated by the token alone.
+		creds = aws.AnonymousCredentials{}
+	}
+
+	client, err := newSTSClient(ctx, creds, pcSpec, o...)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionName := defaultRoleSessionName
+	if ar.RoleSessionName != "" {
+		sessionName = ar.RoleSessionName
+	}
+
+	if ar.WebIdentityTokenFile != "" {
+		return aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(client, ar.RoleARN, stscreds.IdentityTokenFile(ar.WebIdentityTokenFile),
+			func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = sessionName
+				if ar.Duration != nil {
+					o.Duration = ar.Duration.Duration
+				}
+			})), nil
+	}
+
+	return aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(client, ar.RoleARN,
+		func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = sessionName
+			if ar.Duration != nil {
+				o.Duration = ar.Duration.Duration
+			}
+		})), nil
+}
+
+// newSTSClient returns a client of the STS API of the backend described by
+// the supplied ProviderConfigSpec. A nil credentials provider means the
+// default credential chain of the SDK is used.
+func newSTSClient(ctx context.Context, creds aws.CredentialsProvider, pcSpec *apisv1alpha1.ProviderConfigSpec, o ...ClientOption) (*sts.Client, error) {
+	opts := &clientOptions{}
+	for _, opt := range o {
+		opt(opts)
+	}
+
+	endpoint := strings.TrimSuffix(resolveHostBase(pcSpec.HostBase, aws.ToBool(pcSpec.UseHTTPS)), "/")
+	endpointResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+		return aws.Endpoint{
+			URL:               endpoint,
+			HostnameImmutable: true,
+		}, nil
+	})
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithEndpointResolverWithOptions(endpointResolver),
+		config.WithRetryer(newRetryer(pcSpec.Retry)),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, errLoadSTSConfig)
+	}
+
+	// See NewClient.
+	cfg.HTTPClient = newHTTPClient(pcSpec, opts)
+
+	cfg.Region = defaultRegion
+	if pcSpec.Region != "" {
+		cfg.Region = pcSpec.Region
+	}
+
+	if creds != nil {
+		cfg.Credentials = cachedCredentials(creds)
+	}
+
+	return sts.NewFromConfig(cfg), nil
+}